@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/setup"
+	"github.com/kamranahmedse/slim/internal/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	certExportFormat   string
+	certExportOut      string
+	certExportPassword string
+)
+
+var (
+	certWriteBundleFn = cert.WriteBundle
+	certRotateCAFn    = setup.RotateCA
+
+	certIsInteractiveFn  = term.IsInteractive
+	certPasswordPromptFn = term.PasswordPrompt
+	certDaemonRunningFn  = daemon.IsRunning
+	certDaemonSendIPCFn  = daemon.SendIPC
+)
+
+// certEnvVars are read by Node, OpenSSL-based tools, Python requests and
+// curl respectively.
+var certEnvVars = []string{"NODE_EXTRA_CA_CERTS", "SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE"}
+
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Manage slim's certificates and use them with other tools",
+	Long: `Export the slim root CA or a domain's certificate for toolchains that don't
+read the system trust store.
+
+  slim cert export                          # CA as slim-ca.pem
+  slim cert export --format jks             # Java truststore, asks for a password
+  slim cert export myapp --format pkcs12    # myapp.test cert and key
+  eval "$(slim cert env)"                   # point Node, Python and curl at the CA
+  slim cert rotate-ca                       # replace the CA and reissue certs
+  slim cert encrypt-key                     # protect the CA key with a passphrase`,
+}
+
+var certExportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Export the CA, or a domain's certificate and key",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cert.CAExists() {
+			return fmt.Errorf("no CA yet; run 'slim start' first")
+		}
+
+		format := strings.ToLower(certExportFormat)
+		password, err := exportPassword(cmd, format)
+		if err != nil {
+			return err
+		}
+		name := ""
+		var data []byte
+		if len(args) > 0 {
+			name = normalizeName(args[0])
+			data, err = cert.ExportLeaf(name, format, password)
+		} else {
+			data, err = cert.ExportCA(format, password)
+		}
+		if err != nil {
+			return err
+		}
+
+		out := certExportOut
+		if out == "" {
+			out = cert.ExportFileName(name, format)
+		}
+		if out == "-" {
+			_, err := os.Stdout.Write(data)
+			return err
+		}
+		// Everything but the CA and a bare DER certificate carries a key.
+		perm := os.FileMode(0600)
+		if name == "" || format == cert.FormatDER {
+			perm = 0644
+		}
+		if err := os.WriteFile(out, data, perm); err != nil {
+			return fmt.Errorf("writing %s: %w", out, err)
+		}
+
+		what := "slim root CA"
+		if name != "" {
+			what = name
+		}
+		fmt.Printf("Exported %s to %s (%s)\n", what, out, format)
+		return nil
+	},
+}
+
+var certEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print environment variables that make tools trust slim",
+	Long: `Print shell exports pointing common toolchains at a CA bundle holding the
+system roots plus the slim CA. slim rebuilds the bundle every time this runs
+and whenever the CA changes.
+
+  eval "$(slim cert env)"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cert.CAExists() {
+			return fmt.Errorf("no CA yet; run 'slim start' first")
+		}
+		path, err := certWriteBundleFn()
+		if err != nil {
+			return err
+		}
+		for _, name := range certEnvVars {
+			fmt.Printf("export %s=%s\n", name, shellQuote(path))
+		}
+		return nil
+	},
+}
+
+var certRotateCACmd = &cobra.Command{
+	Use:   "rotate-ca",
+	Short: "Replace the root CA and reissue every certificate",
+	Long: `Generate a new root CA, trust it alongside the current one, reissue every
+certificate with it and have the daemon serve them. The old CA is then removed
+from the trust stores and deleted.
+
+If the rotation is interrupted, run the command again to finish it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cert.CAExists() {
+			return fmt.Errorf("no CA yet; run 'slim start' first")
+		}
+		stage, err := cert.RotationStage()
+		if err != nil {
+			return err
+		}
+		if stage != "" {
+			fmt.Println("Resuming the interrupted CA rotation...")
+		}
+		if _, err := unlockCA(false, nil); err != nil {
+			return err
+		}
+		if err := certRotateCAFn(); err != nil {
+			return fmt.Errorf("%w (run 'slim cert rotate-ca' again to resume)", err)
+		}
+		fmt.Println("Root CA rotated.")
+		return nil
+	},
+}
+
+var certEncryptKeyCmd = &cobra.Command{
+	Use:   "encrypt-key",
+	Short: "Encrypt the root CA key with a passphrase",
+	Long: `Encrypt the root CA key (PKCS#8 with PBKDF2 and AES-256) so it no longer
+sits on disk in plaintext. Run it again to change the passphrase.
+
+The key is unlocked once per daemon run. slim reads the passphrase from
+$SLIM_CA_PASSPHRASE, or from the file $SLIM_CA_PASSPHRASE_FILE names, which
+must not be readable by other users. Without either, it asks when a
+certificate has to be issued.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cert.CAExists() {
+			return fmt.Errorf("no CA yet; run 'slim start' first")
+		}
+		encrypted, err := cert.CAKeyEncrypted()
+		if err != nil {
+			return err
+		}
+		if encrypted {
+			current, err := caPassphrase("Current passphrase")
+			if err != nil {
+				return err
+			}
+			if err := cert.UnlockCA(current); err != nil {
+				return err
+			}
+		}
+
+		passphrase, err := newCAPassphrase(encrypted)
+		if err != nil {
+			return err
+		}
+		if err := cert.EncryptCAKey(passphrase); err != nil {
+			return err
+		}
+		if certDaemonRunningFn() {
+			if err := sendUnlockCA(certDaemonSendIPCFn, passphrase); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Encrypted %s\n", cert.CAKeyPath())
+		return nil
+	},
+}
+
+var certDecryptKeyCmd = &cobra.Command{
+	Use:   "decrypt-key",
+	Short: "Store the root CA key without a passphrase again",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cert.CAExists() {
+			return fmt.Errorf("no CA yet; run 'slim start' first")
+		}
+		encrypted, err := cert.CAKeyEncrypted()
+		if err != nil {
+			return err
+		}
+		if !encrypted {
+			fmt.Println("The CA key is not encrypted.")
+			return nil
+		}
+		if _, err := unlockCA(false, nil); err != nil {
+			return err
+		}
+		if err := cert.DecryptCAKey(); err != nil {
+			return err
+		}
+		fmt.Printf("Decrypted %s\n", cert.CAKeyPath())
+		return nil
+	},
+}
+
+var certUnlockCmd = &cobra.Command{
+	Use:   "unlock",
+	Short: "Unlock an encrypted CA key for the running daemon",
+	Long: `Hand the daemon the passphrase of an encrypted CA key, so it can renew
+certificates until it stops. slim start and slim up do this themselves when
+they issue a certificate.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cert.CAExists() {
+			return fmt.Errorf("no CA yet; run 'slim start' first")
+		}
+		if !certDaemonRunningFn() {
+			return fmt.Errorf("slim is not running")
+		}
+		passphrase, err := unlockCA(true, certDaemonSendIPCFn)
+		if err != nil {
+			return err
+		}
+		if passphrase == "" {
+			fmt.Println("The CA key is not encrypted.")
+			return nil
+		}
+		fmt.Println("CA key unlocked until the daemon stops.")
+		return nil
+	},
+}
+
+// unlockCA unlocks an encrypted CA key in this process and, when running,
+// in the daemon. It returns the passphrase, or "" when the key is not
+// encrypted.
+func unlockCA(running bool, sendIPC func(daemon.Request) (*daemon.Response, error)) (string, error) {
+	if !cert.CAExists() {
+		return "", nil
+	}
+	encrypted, err := cert.CAKeyEncrypted()
+	if err != nil || !encrypted {
+		return "", err
+	}
+	passphrase, err := caPassphrase("CA key passphrase")
+	if err != nil {
+		return "", err
+	}
+	if err := cert.UnlockCA(passphrase); err != nil {
+		return "", err
+	}
+	if running {
+		if err := sendUnlockCA(sendIPC, passphrase); err != nil {
+			return "", err
+		}
+	}
+	return passphrase, nil
+}
+
+func sendUnlockCA(sendIPC func(daemon.Request) (*daemon.Response, error), passphrase string) error {
+	data, err := json.Marshal(daemon.UnlockCARequest{Passphrase: passphrase})
+	if err != nil {
+		return err
+	}
+	resp, err := sendIPC(daemon.Request{Type: daemon.MsgUnlockCA, Data: data})
+	if err != nil {
+		return fmt.Errorf("unlocking the CA key in the daemon: %w", err)
+	}
+	// A daemon from before encrypted keys is restarted by the next change.
+	if !resp.OK && resp.Protocol != daemon.ProtocolVersion {
+		return nil
+	}
+	if !resp.OK {
+		return fmt.Errorf("unlocking the CA key in the daemon: %s", resp.Error)
+	}
+	return nil
+}
+
+// exportPassword returns the password for a pkcs12 or jks export: the one
+// in the environment, or else one typed at a prompt. An empty password
+// leaves the export unprotected. --password still works but warns, since
+// other users can read it from the process list.
+func exportPassword(cmd *cobra.Command, format string) (string, error) {
+	if cmd.Flags().Changed("password") {
+		fmt.Fprintf(os.Stderr, "%s --password is visible to other users on this machine; set %s or use the prompt instead\n",
+			term.Yellow.Render("Warning:"), cert.ExportPasswordEnv)
+		return certExportPassword, nil
+	}
+	if format != cert.FormatPKCS12 && format != cert.FormatJKS {
+		return "", nil
+	}
+	password, err := cert.ExportPassword()
+	if err != nil || password != "" || !certIsInteractiveFn() {
+		return password, err
+	}
+	return certPasswordPromptFn("Export password (empty for none)")
+}
+
+// caPassphrase returns the passphrase from the environment, or asks for it.
+func caPassphrase(prompt string) (string, error) {
+	passphrase, err := cert.CAPassphrase()
+	if err != nil || passphrase != "" {
+		return passphrase, err
+	}
+	if !certIsInteractiveFn() {
+		return "", cert.ErrCALocked
+	}
+	return certPasswordPromptFn(prompt)
+}
+
+// newCAPassphrase asks for a passphrase twice. Without a terminal it takes
+// the one in the environment, unless that is the one being replaced.
+func newCAPassphrase(replacing bool) (string, error) {
+	if !certIsInteractiveFn() {
+		passphrase, err := cert.CAPassphrase()
+		if err != nil {
+			return "", err
+		}
+		if passphrase == "" || replacing {
+			return "", fmt.Errorf("run this in a terminal, or set %s for a key that isn't encrypted yet", cert.CAPassphraseEnv)
+		}
+		return passphrase, nil
+	}
+	passphrase, err := certPasswordPromptFn("New passphrase")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase cannot be empty")
+	}
+	again, err := certPasswordPromptFn("Repeat passphrase")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New("passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	certExportCmd.Flags().StringVar(&certExportFormat, "format", cert.FormatPEM, "Output format: pem, der, pkcs12 or jks")
+	certExportCmd.Flags().StringVarP(&certExportOut, "out", "o", "", "File to write, or - for stdout (default: slim-ca.<ext> or <name>.<ext>)")
+	certExportCmd.Flags().StringVar(&certExportPassword, "password", "", "Password protecting a pkcs12 or jks export (insecure: visible in the process list; prefer "+cert.ExportPasswordEnv+" or the prompt)")
+	certCmd.AddCommand(certExportCmd)
+	certCmd.AddCommand(certEnvCmd)
+	certCmd.AddCommand(certRotateCACmd)
+	certCmd.AddCommand(certEncryptKeyCmd)
+	certCmd.AddCommand(certDecryptKeyCmd)
+	certCmd.AddCommand(certUnlockCmd)
+	rootCmd.AddCommand(certCmd)
+}
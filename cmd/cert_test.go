@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"/home/u/.slim/ca/bundle.pem", "'/home/u/.slim/ca/bundle.pem'"},
+		{"/home/o'neil/bundle.pem", `'/home/o'\''neil/bundle.pem'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Fatalf("shellQuote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCertEnvPrintsExports(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.Init(); err != nil {
+		t.Fatalf("config.Init: %v", err)
+	}
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	prev := certWriteBundleFn
+	defer func() { certWriteBundleFn = prev }()
+	bundle := filepath.Join(config.Dir(), "ca", "bundle.pem")
+	certWriteBundleFn = func() (string, error) { return bundle, nil }
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = certEnvCmd.RunE(certEnvCmd, nil)
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("cert env: %v", err)
+	}
+	want := ""
+	for _, name := range []string{"NODE_EXTRA_CA_CERTS", "SSL_CERT_FILE", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE"} {
+		want += "export " + name + "='" + bundle + "'\n"
+	}
+	if string(out) != want {
+		t.Fatalf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestCertRotateCA(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.Init(); err != nil {
+		t.Fatalf("config.Init: %v", err)
+	}
+	prev := certRotateCAFn
+	defer func() { certRotateCAFn = prev }()
+	calls := 0
+	certRotateCAFn = func() error { calls++; return errors.New("trusting CA: cancelled") }
+
+	if err := certRotateCACmd.RunE(certRotateCACmd, nil); err == nil || !strings.Contains(err.Error(), "no CA yet") {
+		t.Fatalf("expected a missing CA error, got %v", err)
+	}
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	err := certRotateCACmd.RunE(certRotateCACmd, nil)
+	if calls != 1 || err == nil || !strings.Contains(err.Error(), "run 'slim cert rotate-ca' again to resume") {
+		t.Fatalf("expected a failed rotation to point at resuming, got %v (%d calls)", err, calls)
+	}
+}
+
+func TestExportPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		flag        string
+		env         string
+		interactive bool
+		answers     []string
+		want        string
+	}{
+		{"pem takes none", cert.FormatPEM, "", "s3cret", true, nil, ""},
+		{"environment", cert.FormatPKCS12, "", "s3cret", true, nil, "s3cret"},
+		{"prompt", cert.FormatJKS, "", "", true, []string{"changeit"}, "changeit"},
+		{"no terminal", cert.FormatJKS, "", "", false, nil, ""},
+		{"insecure flag", cert.FormatPKCS12, "pw", "s3cret", true, nil, "pw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubCAPrompt(t, tt.interactive, tt.answers...)
+			t.Setenv(cert.ExportPasswordEnv, tt.env)
+			t.Setenv(cert.ExportPasswordFileEnv, "")
+			flag := certExportCmd.Flags().Lookup("password")
+			t.Cleanup(func() {
+				certExportPassword = ""
+				flag.Changed = false
+			})
+			if tt.flag != "" {
+				if err := certExportCmd.Flags().Set("password", tt.flag); err != nil {
+					t.Fatalf("Set: %v", err)
+				}
+			}
+
+			got, err := exportPassword(certExportCmd, tt.format)
+			if err != nil || got != tt.want {
+				t.Fatalf("exportPassword = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func stubCAPrompt(t *testing.T, interactive bool, answers ...string) *[]daemon.Request {
+	t.Helper()
+	t.Setenv(cert.CAPassphraseEnv, "")
+	t.Setenv(cert.CAPassphraseFileEnv, "")
+	prevInteractive, prevPrompt := certIsInteractiveFn, certPasswordPromptFn
+	prevRunning, prevSend := certDaemonRunningFn, certDaemonSendIPCFn
+	t.Cleanup(func() {
+		certIsInteractiveFn, certPasswordPromptFn = prevInteractive, prevPrompt
+		certDaemonRunningFn, certDaemonSendIPCFn = prevRunning, prevSend
+	})
+
+	var sent []daemon.Request
+	certIsInteractiveFn = func() bool { return interactive }
+	certPasswordPromptFn = func(string) (string, error) {
+		if len(answers) == 0 {
+			t.Fatal("unexpected passphrase prompt")
+		}
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}
+	certDaemonRunningFn = func() bool { return true }
+	certDaemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
+		sent = append(sent, req)
+		return &daemon.Response{OK: true, Protocol: daemon.ProtocolVersion}, nil
+	}
+	return &sent
+}
+
+func TestCertEncryptAndDecryptKey(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.Init(); err != nil {
+		t.Fatalf("config.Init: %v", err)
+	}
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+
+	stubCAPrompt(t, true, "hunter2", "hunter3")
+	if err := certEncryptKeyCmd.RunE(certEncryptKeyCmd, nil); err == nil || !strings.Contains(err.Error(), "don't match") {
+		t.Fatalf("expected mismatched passphrases to be refused, got %v", err)
+	}
+
+	sent := stubCAPrompt(t, true, "hunter2", "hunter2")
+	if err := certEncryptKeyCmd.RunE(certEncryptKeyCmd, nil); err != nil {
+		t.Fatalf("encrypt-key: %v", err)
+	}
+	if encrypted, err := cert.CAKeyEncrypted(); err != nil || !encrypted {
+		t.Fatalf("CAKeyEncrypted = %v, %v", encrypted, err)
+	}
+	if len(*sent) != 1 || (*sent)[0].Type != daemon.MsgUnlockCA {
+		t.Fatalf("expected the daemon to be unlocked with the new passphrase, got %+v", *sent)
+	}
+	var req daemon.UnlockCARequest
+	if err := json.Unmarshal((*sent)[0].Data, &req); err != nil || req.Passphrase != "hunter2" {
+		t.Fatalf("unexpected unlock request %s (%v)", (*sent)[0].Data, err)
+	}
+
+	stubCAPrompt(t, false)
+	if _, err := unlockCA(true, certDaemonSendIPCFn); !errors.Is(err, cert.ErrCALocked) {
+		t.Fatalf("expected a locked CA without a terminal or passphrase, got %v", err)
+	}
+
+	stubCAPrompt(t, true, "wrong")
+	if err := certDecryptKeyCmd.RunE(certDecryptKeyCmd, nil); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("expected a wrong passphrase to be refused, got %v", err)
+	}
+	stubCAPrompt(t, true, "hunter2")
+	if err := certDecryptKeyCmd.RunE(certDecryptKeyCmd, nil); err != nil {
+		t.Fatalf("decrypt-key: %v", err)
+	}
+	if encrypted, err := cert.CAKeyEncrypted(); err != nil || encrypted {
+		t.Fatalf("CAKeyEncrypted = %v, %v", encrypted, err)
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:    "daemon",
+	Short:  "Run the daemon in the foreground",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// A forked daemon comes back here and finishes detaching.
+		if daemon.IsChild() {
+			return daemon.RunDetached()
+		}
+		return daemon.RunForeground()
+	},
+}
+
+// changeConfig has the daemon apply a change to the config, which it saves
+// and reloads in one step. Without a running daemon there is nothing to
+// race, so the change is made to the config file directly.
+func changeConfig(running bool, sendIPC func(daemon.Request) (*daemon.Response, error), msg daemon.MessageType, payload any) (*daemon.ConfigDiff, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req := daemon.Request{Type: msg, Data: data}
+	if !running {
+		return daemon.Apply(req)
+	}
+	if err := ensureCurrentDaemon(sendIPC); err != nil {
+		return nil, err
+	}
+
+	resp, err := sendIPC(req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, errors.New(resp.Error)
+	}
+	var diff daemon.ConfigDiff
+	if err := json.Unmarshal(resp.Data, &diff); err != nil {
+		return nil, fmt.Errorf("reading daemon response: %w", err)
+	}
+	return &diff, nil
+}
+
+// ensureCurrentDaemon restarts a daemon started by another build of slim,
+// such as the one running before an upgrade, so it understands this CLI.
+// Domains survive because the new daemon loads them from the config file.
+func ensureCurrentDaemon(sendIPC func(daemon.Request) (*daemon.Response, error)) error {
+	info, err := daemon.Handshake(sendIPC)
+	if err != nil {
+		return err
+	}
+	if info.Current() {
+		return nil
+	}
+	fmt.Printf("The daemon is running %s; restarting it with %s\n", info, Version)
+	return restartDaemon(sendIPC)
+}
+
+func restartDaemon(sendIPC func(daemon.Request) (*daemon.Response, error)) error {
+	if err := stopDaemon(sendIPC); err != nil {
+		return fmt.Errorf("stopping daemon: %w", err)
+	}
+	waitForDaemonExit()
+	if err := startDaemon(); err != nil {
+		return fmt.Errorf("starting daemon: %w", err)
+	}
+	return serviceDaemonWaitFn()
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
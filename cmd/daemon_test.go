@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
+)
+
+// TestMain serves a daemon forked by a test the way the slim binary would,
+// since the fork re-executes the test binary.
+func TestMain(m *testing.M) {
+	if daemon.IsChild() {
+		if err := Execute(); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestStopRestartsOlderDaemon(t *testing.T) {
+	restore := setupStopTestHooks(t)
+	defer restore()
+	cfg := &config.Config{
+		Domains:   []config.Domain{{Name: "myapp.test", Port: 3000}},
+		HTTPPort:  freePort(t),
+		HTTPSPort: freePort(t),
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	systemRemoveHostFn = func(string) error { return nil }
+	t.Cleanup(func() {
+		if daemon.IsRunning() {
+			_, _ = daemon.SendIPC(daemon.Request{Type: daemon.MsgShutdown})
+		}
+	})
+
+	// The older daemon is faked; once it is shut down, requests go to the
+	// daemon the restart forks.
+	stale := true
+	var served []daemon.MessageType
+	daemonIsRunningFn = func() bool { return true }
+	daemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
+		if stale {
+			if req.Type == daemon.MsgShutdown {
+				stale = false
+			}
+			return &daemon.Response{OK: true, Protocol: daemon.ProtocolVersion, Version: "0.0.0-old"}, nil
+		}
+		served = append(served, req.Type)
+		return daemon.SendIPC(req)
+	}
+
+	if err := stopAll(); err != nil {
+		t.Fatalf("stopAll: %v", err)
+	}
+	if want := []daemon.MessageType{daemon.MsgRemoveDomain, daemon.MsgShutdown}; !reflect.DeepEqual(served, want) {
+		t.Fatalf("expected the restarted daemon to serve %v, got %v", want, served)
+	}
+	for i := 0; i < 50 && daemon.IsRunning(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if daemon.IsRunning() {
+		t.Fatal("expected the restarted daemon to shut down")
+	}
+}
+
+func TestEnsureCurrentDaemon(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    daemon.Response
+		wantEvents []string
+	}{
+		{"same build", daemon.Response{OK: true, Protocol: daemon.ProtocolVersion, Version: daemon.Version}, []string{"ipc:version"}},
+		{"older build", daemon.Response{OK: true, Protocol: daemon.ProtocolVersion, Version: "0.0.0-old"}, []string{"ipc:version", "ipc:shutdown", "fork"}},
+		{"before versioning", daemon.Response{OK: false, Error: "unknown message type: version"}, []string{"ipc:version", "ipc:shutdown", "fork"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := setupServiceTestHooks(t, nil)
+			send := func(req daemon.Request) (*daemon.Response, error) {
+				*events = append(*events, "ipc:"+string(req.Type))
+				if req.Type == daemon.MsgVersion {
+					resp := tt.version
+					return &resp, nil
+				}
+				return &daemon.Response{OK: true}, nil
+			}
+
+			if err := ensureCurrentDaemon(send); err != nil {
+				t.Fatalf("ensureCurrentDaemon: %v", err)
+			}
+			if !reflect.DeepEqual(*events, tt.wantEvents) {
+				t.Fatalf("events = %v, want %v", *events, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/system"
+	"github.com/kamranahmedse/slim/internal/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newResolverFn      = system.NewResolver
+	dnsAddHostFn       = system.AddHost
+	dnsDaemonRunningFn = daemon.IsRunning
+	dnsDaemonSendIPCFn = daemon.SendIPC
+	dnsLookupHostFn    = net.DefaultResolver.LookupHost
+)
+
+var dnsCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Resolve domains with slim's built-in DNS resolver",
+	Long: `Resolve domains through a DNS resolver built into the daemon instead of
+/etc/hosts entries. Every name under a configured domain resolves to this
+machine, so wildcard domains work without listing each subdomain.
+
+  slim dns enable     # route slim's zones to the resolver
+  slim dns status     # show what is routed
+  slim dns disable    # go back to /etc/hosts`,
+}
+
+var dnsEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Route slim's zones to the built-in resolver",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDNSEnabled(true)
+	},
+}
+
+var dnsDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Stop using the built-in resolver and go back to /etc/hosts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setDNSEnabled(false)
+	},
+}
+
+var dnsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the built-in resolver status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if !cfg.DNS {
+			fmt.Println("Built-in resolver is disabled; domains resolve through /etc/hosts.")
+			return nil
+		}
+		r, err := newResolverFn()
+		if err != nil {
+			return err
+		}
+		installed := r.InstalledZones()
+		fmt.Printf("Built-in resolver is enabled via %s\n", r.Name())
+		for _, z := range cfg.DNSZones() {
+			if slices.Contains(installed, z) {
+				fmt.Printf("%s .%s\n", term.CheckMark, z)
+			} else {
+				fmt.Printf("%s .%s is not routed (run: slim dns enable)\n", term.CrossMark, z)
+			}
+		}
+		return nil
+	},
+}
+
+func setDNSEnabled(enabled bool) error {
+	r, err := newResolverFn()
+	if err != nil {
+		return err
+	}
+
+	diff, err := changeConfig(dnsDaemonRunningFn(), dnsDaemonSendIPCFn, daemon.MsgSetOptions, daemon.SetOptionsRequest{DNS: &enabled})
+	if err != nil {
+		return err
+	}
+	cfg := diff.Config
+
+	if enabled {
+		zones := cfg.DNSZones()
+		if err := r.Install(zones); err != nil {
+			return err
+		}
+		fmt.Printf("Routing %s to slim's resolver via %s\n", formatZones(zones), r.Name())
+	} else {
+		if err := r.Uninstall(); err != nil {
+			return err
+		}
+		hosts := []string{config.InspectorDomain}
+		for _, d := range cfg.Domains {
+			hosts = append(hosts, d.HostNames()...)
+		}
+		for _, host := range hosts {
+			if err := dnsAddHostFn(host); err != nil {
+				return fmt.Errorf("updating /etc/hosts: %w", err)
+			}
+		}
+		fmt.Println("Built-in resolver disabled; domains resolve through /etc/hosts again.")
+	}
+	return nil
+}
+
+// registerHosts makes names resolve to this machine. With the built-in
+// resolver on, that means routing their zones to it; otherwise each name
+// gets an /etc/hosts entry. Rootless mode only edits /etc/hosts for names
+// that don't already resolve locally, and carries on if it can't.
+func registerHosts(cfg *config.Config, names []string, addHost func(string) error) error {
+	if !cfg.DNS {
+		for _, name := range names {
+			if cfg.NoPortForward && resolvesLocally(name) {
+				continue
+			}
+			if err := addHost(name); err != nil {
+				if cfg.NoPortForward {
+					fmt.Fprintf(os.Stderr, "%s could not add %s to /etc/hosts (%v); add it yourself or use a .localhost name\n", term.Yellow.Render("Warning:"), name, err)
+					continue
+				}
+				return fmt.Errorf("updating /etc/hosts: %w", err)
+			}
+		}
+		return nil
+	}
+
+	r, err := newResolverFn()
+	if err != nil {
+		return err
+	}
+	zones := cfg.DNSZones()
+	installed := r.InstalledZones()
+	for _, z := range zones {
+		if !slices.Contains(installed, z) {
+			if err := r.Install(zones); err != nil {
+				return fmt.Errorf("routing %s to slim's resolver: %w", formatZones(zones), err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func resolvesLocally(name string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addrs, err := dnsLookupHostFn(ctx, name)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip == nil || !ip.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+func formatZones(zones []string) string {
+	dotted := make([]string, len(zones))
+	for i, z := range zones {
+		dotted[i] = "." + z
+	}
+	return strings.Join(dotted, ", ")
+}
+
+func init() {
+	dnsCmd.AddCommand(dnsEnableCmd)
+	dnsCmd.AddCommand(dnsDisableCmd)
+	dnsCmd.AddCommand(dnsStatusCmd)
+	rootCmd.AddCommand(dnsCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/system"
+)
+
+type fakeResolver struct {
+	zones    []string
+	installs int
+}
+
+func (f *fakeResolver) Name() string                 { return "fake" }
+func (f *fakeResolver) Install(zones []string) error { f.zones = zones; f.installs++; return nil }
+func (f *fakeResolver) Uninstall() error             { f.zones = nil; return nil }
+func (f *fakeResolver) InstalledZones() []string     { return f.zones }
+
+func TestRegisterHosts(t *testing.T) {
+	prev := newResolverFn
+	defer func() { newResolverFn = prev }()
+
+	tests := []struct {
+		name         string
+		dns          bool
+		installed    []string
+		wantHosts    []string
+		wantInstalls int
+	}{
+		{"hosts file", false, nil, []string{"a.myapp.test"}, 0},
+		{"resolver missing zone", true, []string{"test"}, nil, 1},
+		{"resolver up to date", true, []string{"loc", "test"}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fakeResolver{zones: tt.installed}
+			newResolverFn = func() (system.Resolver, error) { return r, nil }
+			cfg := &config.Config{DNS: tt.dns, Domains: []config.Domain{{Name: "*.myapp.test"}, {Name: "app.loc"}}}
+
+			var added []string
+			err := registerHosts(cfg, []string{"a.myapp.test"}, func(name string) error {
+				added = append(added, name)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("registerHosts: %v", err)
+			}
+			if !reflect.DeepEqual(added, tt.wantHosts) {
+				t.Fatalf("expected hosts %v, got %v", tt.wantHosts, added)
+			}
+			if r.installs != tt.wantInstalls {
+				t.Fatalf("expected %d installs, got %d", tt.wantInstalls, r.installs)
+			}
+			if tt.wantInstalls > 0 && !reflect.DeepEqual(r.zones, []string{"loc", "test"}) {
+				t.Fatalf("unexpected installed zones %v", r.zones)
+			}
+		})
+	}
+}
+
+func TestRegisterHostsRootless(t *testing.T) {
+	prev := dnsLookupHostFn
+	defer func() { dnsLookupHostFn = prev }()
+
+	dnsLookupHostFn = func(_ context.Context, host string) ([]string, error) {
+		switch host {
+		case "app.localhost":
+			return []string{"127.0.0.1", "::1"}, nil
+		case "remote.test":
+			return []string{"10.0.0.5"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	cfg := &config.Config{NoPortForward: true}
+	var added []string
+	err := registerHosts(cfg, []string{"app.localhost", "myapp.test", "remote.test"}, func(name string) error {
+		added = append(added, name)
+		if name == "remote.test" {
+			return errors.New("sudo: a password is required")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected failed hosts edits to be skipped in rootless mode, got %v", err)
+	}
+	if want := []string{"myapp.test", "remote.test"}; !reflect.DeepEqual(added, want) {
+		t.Fatalf("expected hosts edits for %v, got %v", want, added)
+	}
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/kamranahmedse/slim/internal/config"
 	"github.com/kamranahmedse/slim/internal/daemon"
 	"github.com/kamranahmedse/slim/internal/project"
 	"github.com/kamranahmedse/slim/internal/system"
@@ -12,8 +11,6 @@ import (
 
 var (
 	downDiscoverFn      = project.Discover
-	downWithLockFn      = config.WithLock
-	downLoadFn          = config.Load
 	downRemoveHostFn    = system.RemoveHost
 	downDaemonRunningFn = daemon.IsRunning
 	downDaemonSendIPCFn = daemon.SendIPC
@@ -47,44 +44,28 @@ Other domains not in the project config are left running.
 			return err
 		}
 
-		var remainingDomains int
-		if err := downWithLockFn(func() error {
-			cfg, err := downLoadFn()
-			if err != nil {
-				return err
-			}
-			remove := make(map[string]bool, len(pc.Services))
-			for _, svc := range pc.Services {
-				remove[svc.Domain] = true
-			}
-			filtered := cfg.Domains[:0]
-			for _, d := range cfg.Domains {
-				if !remove[d.Name] {
-					filtered = append(filtered, d)
-				}
-			}
-			cfg.Domains = filtered
-			remainingDomains = len(cfg.Domains)
-			return cfg.Save()
-		}); err != nil {
+		names := make([]string, len(pc.Services))
+		for i, svc := range pc.Services {
+			names[i] = svc.Domain
+		}
+		running := downDaemonRunningFn()
+		diff, err := changeConfig(running, downDaemonSendIPCFn, daemon.MsgRemoveDomain, daemon.RemoveDomainRequest{Names: names, IgnoreMissing: true})
+		if err != nil {
 			return err
 		}
 
 		for _, svc := range pc.Services {
-			if err := downRemoveHostFn(svc.Domain); err != nil {
-				fmt.Printf("Warning: failed to remove %s from /etc/hosts: %v\n", svc.Domain, err)
+			d := svc.ConfigDomain()
+			for _, host := range d.HostNames() {
+				if err := downRemoveHostFn(host); err != nil {
+					fmt.Printf("Warning: failed to remove %s from /etc/hosts: %v\n", host, err)
+				}
 			}
 		}
 
-		if downDaemonRunningFn() {
-			if remainingDomains == 0 {
-				if _, err := downDaemonSendIPCFn(daemon.Request{Type: daemon.MsgShutdown}); err != nil {
-					return fmt.Errorf("stopping daemon: %w", err)
-				}
-			} else {
-				if _, err := downDaemonSendIPCFn(daemon.Request{Type: daemon.MsgReload}); err != nil {
-					return fmt.Errorf("reloading daemon: %w", err)
-				}
+		if running && len(diff.Config.Domains) == 0 {
+			if err := stopDaemon(downDaemonSendIPCFn); err != nil {
+				return fmt.Errorf("stopping daemon: %w", err)
 			}
 		}
 
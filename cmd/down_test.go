@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/kamranahmedse/slim/internal/config"
@@ -17,19 +18,12 @@ func setupDownTestHooks(t *testing.T) func() {
 	}
 
 	prevDiscover := downDiscoverFn
-	prevWithLock := downWithLockFn
-	prevLoad := downLoadFn
 	prevRemove := downRemoveHostFn
 	prevRunning := downDaemonRunningFn
 	prevIPC := downDaemonSendIPCFn
 
-	downWithLockFn = config.WithLock
-	downLoadFn = config.Load
-
 	return func() {
 		downDiscoverFn = prevDiscover
-		downWithLockFn = prevWithLock
-		downLoadFn = prevLoad
 		downRemoveHostFn = prevRemove
 		downDaemonRunningFn = prevRunning
 		downDaemonSendIPCFn = prevIPC
@@ -61,19 +55,16 @@ func TestDownRemovesProjectServices(t *testing.T) {
 	downRemoveHostFn = func(string) error { return nil }
 	downDaemonRunningFn = func() bool { return true }
 
-	var gotType daemon.MessageType
-	downDaemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		gotType = req.Type
-		return &daemon.Response{OK: true}, nil
-	}
+	var types []daemon.MessageType
+	downDaemonSendIPCFn = fakeDaemonIPC(&types)
 
 	err := downCmd.RunE(downCmd, nil)
 	if err != nil {
 		t.Fatalf("down: %v", err)
 	}
 
-	if gotType != daemon.MsgReload {
-		t.Fatalf("expected reload IPC (other domain remains), got %q", gotType)
+	if !slices.Equal(types, []daemon.MessageType{daemon.MsgRemoveDomain}) {
+		t.Fatalf("expected only a remove_domain IPC (other domain remains), got %v", types)
 	}
 
 	cfg, err := config.Load()
@@ -107,18 +98,15 @@ func TestDownShutdownsWhenNoDomains(t *testing.T) {
 	downRemoveHostFn = func(string) error { return nil }
 	downDaemonRunningFn = func() bool { return true }
 
-	var gotType daemon.MessageType
-	downDaemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		gotType = req.Type
-		return &daemon.Response{OK: true}, nil
-	}
+	var types []daemon.MessageType
+	downDaemonSendIPCFn = fakeDaemonIPC(&types)
 
 	err := downCmd.RunE(downCmd, nil)
 	if err != nil {
 		t.Fatalf("down: %v", err)
 	}
 
-	if gotType != daemon.MsgShutdown {
-		t.Fatalf("expected shutdown IPC, got %q", gotType)
+	if !slices.Equal(types, []daemon.MessageType{daemon.MsgRemoveDomain, daemon.MsgShutdown}) {
+		t.Fatalf("expected remove_domain then shutdown IPC, got %v", types)
 	}
 }
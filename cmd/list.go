@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"charm.land/lipgloss/v2"
@@ -72,54 +73,89 @@ var listCmd = &cobra.Command{
 		var pfReloadErr error
 		if running {
 			pf := system.NewPortForwarder()
-			if shouldReloadPortForwarding(pf, true) {
+			if shouldReloadPortForwarding(cfg, pf, true) {
 				if err := pf.EnsureLoaded(); err != nil {
 					pfReloadErr = err
 				}
 			}
-			ingressOK = ingressPortsReachable()
+			ingressOK = ingressPortsReachable(cfg)
+		}
+
+		type targetEntry struct {
+			Target  string `json:"target"`
+			Healthy bool   `json:"healthy"`
 		}
 
 		type routeEntry struct {
-			Path    string `json:"path"`
-			Port    int    `json:"port"`
-			Healthy *bool  `json:"healthy,omitempty"`
+			Path     string        `json:"path"`
+			Port     int           `json:"port,omitempty"`
+			Ports    []int         `json:"ports,omitempty"`
+			Upstream string        `json:"upstream,omitempty"`
+			Socket   string        `json:"socket,omitempty"`
+			Dir      string        `json:"dir,omitempty"`
+			Scheme   string        `json:"scheme,omitempty"`
+			Healthy  *bool         `json:"healthy,omitempty"`
+			Targets  []targetEntry `json:"targets,omitempty"`
 		}
 
 		type domainEntry struct {
-			Domain  string       `json:"domain"`
-			Port    int          `json:"port"`
-			Healthy *bool        `json:"healthy,omitempty"`
-			Routes  []routeEntry `json:"routes,omitempty"`
+			Domain   string        `json:"domain"`
+			URL      string        `json:"url"`
+			Port     int           `json:"port,omitempty"`
+			Ports    []int         `json:"ports,omitempty"`
+			Upstream string        `json:"upstream,omitempty"`
+			Socket   string        `json:"socket,omitempty"`
+			Dir      string        `json:"dir,omitempty"`
+			Scheme   string        `json:"scheme,omitempty"`
+			Healthy  *bool         `json:"healthy,omitempty"`
+			Targets  []targetEntry `json:"targets,omitempty"`
+			Routes   []routeEntry  `json:"routes,omitempty"`
 		}
 
 		var domains []domainEntry
 		for _, d := range cfg.Domains {
 			entry := domainEntry{
-				Domain: d.Name,
-				Port:   d.Port,
+				Domain:   d.Name,
+				URL:      cfg.URL(d.Name),
+				Port:     d.Port,
+				Ports:    d.Ports,
+				Upstream: d.Upstream,
+				Socket:   d.Socket,
+				Dir:      d.Dir,
+				Scheme:   d.Scheme,
 			}
 			for _, r := range d.Routes {
-				entry.Routes = append(entry.Routes, routeEntry{Path: r.Path, Port: r.Port})
+				entry.Routes = append(entry.Routes, routeEntry{Path: r.Path, Port: r.Port, Ports: r.Ports, Upstream: r.Upstream, Socket: r.Socket, Dir: r.Dir, Scheme: r.Scheme})
 			}
 			domains = append(domains, entry)
 		}
 
+		// targetHealth folds per-upstream results into the overall status,
+		// listing each upstream separately for balanced targets.
+		targetHealth := func(t config.Target, health []bool) (*bool, []targetEntry) {
+			healthy := false
+			var entries []targetEntry
+			for i, e := range t.Expand() {
+				healthy = healthy || health[i]
+				if len(t.Ports) > 0 {
+					entries = append(entries, targetEntry{Target: listTarget(e), Healthy: health[i]})
+				}
+			}
+			return &healthy, entries
+		}
+
 		if running && len(domains) > 0 {
-			var allPorts []int
+			var targets []config.Target
 			for _, d := range cfg.Domains {
-				allPorts = append(allPorts, d.Port)
-				for _, r := range d.Routes {
-					allPorts = append(allPorts, r.Port)
-				}
+				targets = append(targets, d.Targets()...)
 			}
-			health := proxy.CheckUpstreams(allPorts)
+			health := proxy.CheckTargets(targets)
 			idx := 0
-			for i := range domains {
-				domains[i].Healthy = &health[idx]
+			for i, d := range cfg.Domains {
+				domains[i].Healthy, domains[i].Targets = targetHealth(d.Target(), health[idx])
 				idx++
-				for j := range domains[i].Routes {
-					domains[i].Routes[j].Healthy = &health[idx]
+				for j, r := range d.Routes {
+					domains[i].Routes[j].Healthy, domains[i].Routes[j].Targets = targetHealth(r.Target(), health[idx])
 					idx++
 				}
 			}
@@ -159,35 +195,25 @@ var listCmd = &cobra.Command{
 
 		if len(domains) > 0 {
 			var rows [][]string
-			for _, e := range domains {
-				status := term.Dim.Render("-")
-				if e.Healthy != nil {
-					if running && !ingressOK {
-						status = term.Red.Render("● ingress down")
-					} else if *e.Healthy {
-						status = term.Green.Render("● reachable")
-					} else {
-						status = term.Red.Render("● unreachable")
-					}
+			addTargetRows := func(targets []targetEntry) {
+				for _, t := range targets {
+					healthy := t.Healthy
+					rows = append(rows, []string{"", "  " + t.Target, listStatus(&healthy, running, ingressOK)})
 				}
-				rows = append(rows, []string{e.Domain, fmt.Sprintf("%d", e.Port), status})
+			}
+			for _, e := range domains {
+				target := config.Target{Port: e.Port, Ports: e.Ports, Upstream: e.Upstream, Socket: e.Socket, Dir: e.Dir, Scheme: e.Scheme}
+				rows = append(rows, []string{cfg.HostPort(e.Domain), listTarget(target), listStatus(e.Healthy, running, ingressOK)})
+				addTargetRows(e.Targets)
 				for _, r := range e.Routes {
-					rStatus := term.Dim.Render("-")
-					if r.Healthy != nil {
-						if running && !ingressOK {
-							rStatus = term.Red.Render("● ingress down")
-						} else if *r.Healthy {
-							rStatus = term.Green.Render("● reachable")
-						} else {
-							rStatus = term.Red.Render("● unreachable")
-						}
-					}
-					rows = append(rows, []string{"  " + r.Path, fmt.Sprintf("%d", r.Port), rStatus})
+					target := config.Target{Port: r.Port, Ports: r.Ports, Upstream: r.Upstream, Socket: r.Socket, Dir: r.Dir, Scheme: r.Scheme}
+					rows = append(rows, []string{"  " + r.Path, listTarget(target), listStatus(r.Healthy, running, ingressOK)})
+					addTargetRows(r.Targets)
 				}
 			}
 
 			t := table.New().
-				Headers("DOMAIN", "PORT", "STATUS").
+				Headers("DOMAIN", "UPSTREAM", "STATUS").
 				Rows(rows...).
 				BorderTop(false).
 				BorderBottom(false).
@@ -245,6 +271,32 @@ var listCmd = &cobra.Command{
 	},
 }
 
+func listTarget(t config.Target) string {
+	if t.Scheme == "https" {
+		return t.String()
+	}
+	if t.Port != 0 {
+		return fmt.Sprintf("%d", t.Port)
+	}
+	if len(t.Ports) > 0 {
+		return strings.TrimPrefix(t.String(), "localhost:")
+	}
+	return t.String()
+}
+
+func listStatus(healthy *bool, running bool, ingressOK bool) string {
+	switch {
+	case healthy == nil:
+		return term.Dim.Render("-")
+	case running && !ingressOK:
+		return term.Red.Render("● ingress down")
+	case *healthy:
+		return term.Green.Render("● reachable")
+	default:
+		return term.Red.Render("● unreachable")
+	}
+}
+
 func init() {
 	listCmd.Flags().BoolVar(&listJSON, "json", false, "Output as JSON")
 	rootCmd.AddCommand(listCmd)
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,12 +11,26 @@ import (
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/event"
+	"github.com/kamranahmedse/slim/internal/log"
 	"github.com/kamranahmedse/slim/internal/term"
 	"github.com/spf13/cobra"
 )
 
 var logsFollow bool
 var logsFlush bool
+var logsDaemon bool
+
+var (
+	logsDaemonRunningFn = daemon.IsRunning
+	logsSubscribeFn     = func(types ...event.Type) (eventStream, error) { return daemon.Subscribe(types...) }
+)
+
+type eventStream interface {
+	Next() (event.Event, error)
+	Close() error
+}
 
 var logsCmd = &cobra.Command{
 	Use:   "logs [name]",
@@ -24,10 +40,14 @@ var logsCmd = &cobra.Command{
   slim logs             # all domains
   slim logs myapp       # only myapp.test
   slim logs -f          # follow (like tail -f)
-  slim logs --flush     # clear log file`,
+  slim logs --flush     # clear log file
+  slim logs --daemon    # daemon messages: reloads, cert and listener errors`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		logPath := config.LogPath()
+		if logsDaemon {
+			logPath = config.DaemonLogPath()
+		}
 		if logsFlush {
 			if err := validateLogsFlags(logsFlush, logsFollow, len(args)); err != nil {
 				return err
@@ -40,25 +60,36 @@ var logsCmd = &cobra.Command{
 				}
 				return fmt.Errorf("clearing logs: %w", err)
 			}
-			fmt.Println("Cleared access logs.")
+			if logsDaemon {
+				fmt.Println("Cleared the daemon log.")
+			} else {
+				fmt.Println("Cleared access logs.")
+			}
 			return nil
 		}
 
+		filter := ""
+		if len(args) > 0 {
+			filter = normalizeName(args[0])
+		}
+		if logsFollow && !logsDaemon && logsDaemonRunningFn() {
+			return followRequestEvents(filter)
+		}
+
 		f, err := os.Open(logPath)
 		if err != nil {
 			if os.IsNotExist(err) {
-				fmt.Println("No logs yet. Start a domain first with 'slim start'.")
+				if logsDaemon {
+					fmt.Println("No daemon log yet. It is written once the daemon starts.")
+				} else {
+					fmt.Println("No logs yet. Start a domain first with 'slim start'.")
+				}
 				return nil
 			}
 			return err
 		}
 		defer f.Close()
 
-		filter := ""
-		if len(args) > 0 {
-			filter = normalizeName(args[0])
-		}
-
 		if logsFollow {
 			_, _ = f.Seek(0, io.SeekEnd)
 		}
@@ -82,13 +113,47 @@ var logsCmd = &cobra.Command{
 				continue
 			}
 
-			fmt.Println(formatLogLine(line))
+			if logsDaemon {
+				fmt.Println(formatDaemonLogLine(line))
+			} else {
+				fmt.Println(formatLogLine(line))
+			}
 		}
 
 		return nil
 	},
 }
 
+// followRequestEvents prints requests as the daemon reports them, which
+// also works when the access log is turned off.
+func followRequestEvents(filter string) error {
+	stream, err := logsSubscribeFn(event.RequestCompleted)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for {
+		e, err := stream.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				fmt.Println(term.Dim.Render("Daemon stopped."))
+				return nil
+			}
+			return err
+		}
+		var r event.Request
+		if err := json.Unmarshal(e.Data, &r); err != nil {
+			continue
+		}
+		line := log.FormatRequest(e.Time, r.Domain, r.Method, r.Path, r.Upstream, r.Status, r.Duration)
+		if filter != "" && !strings.Contains(line, filter) {
+			continue
+		}
+		fmt.Println(formatLogLine(line))
+	}
+}
+
 func validateLogsFlags(flush bool, follow bool, argCount int) error {
 	if !flush {
 		return nil
@@ -102,6 +167,22 @@ func validateLogsFlags(flush bool, follow bool, argCount int) error {
 	return nil
 }
 
+func formatDaemonLogLine(line string) string {
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) < 3 {
+		return line
+	}
+
+	levelStyle := term.Cyan
+	switch parts[1] {
+	case log.LevelWarn:
+		levelStyle = term.Yellow
+	case log.LevelError:
+		levelStyle = term.Red
+	}
+	return fmt.Sprintf("%s %s %s", term.Dim.Render(parts[0]), levelStyle.Render(fmt.Sprintf("%-5s", parts[1])), parts[2])
+}
+
 func formatLogLine(line string) string {
 	parts := strings.Split(line, "\t")
 	if len(parts) == 4 {
@@ -168,5 +249,6 @@ func formatLogLine(line string) string {
 func init() {
 	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow log output")
 	logsCmd.Flags().BoolVar(&logsFlush, "flush", false, "Clear the access log file")
+	logsCmd.Flags().BoolVar(&logsDaemon, "daemon", false, "Show the daemon log instead of requests")
 	rootCmd.AddCommand(logsCmd)
 }
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"io"
+	"os"
 	"strings"
 	"testing"
+
+	"github.com/kamranahmedse/slim/internal/event"
 )
 
 func TestValidateLogsFlags(t *testing.T) {
@@ -58,3 +62,84 @@ func TestValidateLogsFlags(t *testing.T) {
 		})
 	}
 }
+
+type fakeEventStream struct {
+	events []event.Event
+	closed bool
+}
+
+func (s *fakeEventStream) Next() (event.Event, error) {
+	if len(s.events) == 0 {
+		return event.Event{}, io.EOF
+	}
+	e := s.events[0]
+	s.events = s.events[1:]
+	return e, nil
+}
+
+func (s *fakeEventStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestFollowRequestEventsPrintsMatchingRequests(t *testing.T) {
+	prev := logsSubscribeFn
+	defer func() { logsSubscribeFn = prev }()
+
+	requestEvent := func(domain string) event.Event {
+		e, err := event.New(event.RequestCompleted, event.Request{Domain: domain, Method: "GET", Path: "/", Upstream: "3000", Status: 200})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return e
+	}
+	stream := &fakeEventStream{events: []event.Event{requestEvent("myapp.test"), requestEvent("api.test")}}
+	var subscribed []event.Type
+	logsSubscribeFn = func(types ...event.Type) (eventStream, error) {
+		subscribed = types
+		return stream, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = followRequestEvents("myapp.test")
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("followRequestEvents: %v", err)
+	}
+	if len(subscribed) != 1 || subscribed[0] != event.RequestCompleted {
+		t.Fatalf("expected a request_completed subscription, got %v", subscribed)
+	}
+	if !stream.closed {
+		t.Fatal("expected the stream to be closed")
+	}
+	if !strings.Contains(string(out), "myapp.test") || strings.Contains(string(out), "api.test") {
+		t.Fatalf("expected only myapp.test requests, got %q", out)
+	}
+}
+
+func TestFormatDaemonLogLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"2026-10-17 10:00:00.000\tERROR\tReloading config failed: bad yaml", []string{"2026-10-17 10:00:00.000", "ERROR", "Reloading config failed: bad yaml"}},
+		{"2026-10-17 10:00:00.000\tINFO\ttabs\tin message", []string{"INFO", "tabs\tin message"}},
+		{"not a daemon line", []string{"not a daemon line"}},
+	}
+	for _, tt := range tests {
+		got := formatDaemonLogLine(tt.line)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Fatalf("formatDaemonLogLine(%q) = %q, want it to contain %q", tt.line, got, want)
+			}
+		}
+	}
+}
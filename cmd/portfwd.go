@@ -5,13 +5,14 @@ import (
 	"net"
 	"time"
 
+	"github.com/kamranahmedse/slim/internal/config"
 	"github.com/kamranahmedse/slim/internal/system"
 )
 
 var cmdDialTimeoutFn = net.DialTimeout
 
-func ingressPortsReachable() bool {
-	for _, port := range []int{80, 443} {
+func ingressPortsReachable(cfg *config.Config) bool {
+	for _, port := range []int{cfg.PublicHTTPPort(), cfg.PublicHTTPSPort()} {
 		conn, err := cmdDialTimeoutFn("tcp", fmt.Sprintf("127.0.0.1:%d", port), 500*time.Millisecond)
 		if err != nil {
 			return false
@@ -21,12 +22,12 @@ func ingressPortsReachable() bool {
 	return true
 }
 
-func shouldReloadPortForwarding(pf system.PortForwarder, daemonRunning bool) bool {
-	if !pf.IsEnabled() {
+func shouldReloadPortForwarding(cfg *config.Config, pf system.PortForwarder, daemonRunning bool) bool {
+	if cfg.NoPortForward || !pf.IsEnabled() {
 		return false
 	}
 	if !pf.IsLoaded() {
 		return true
 	}
-	return daemonRunning && !ingressPortsReachable()
+	return daemonRunning && !ingressPortsReachable(cfg)
 }
@@ -3,8 +3,11 @@ package cmd
 import (
 	"errors"
 	"net"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
 )
 
 type mockCmdPortFwd struct {
@@ -27,14 +30,14 @@ func TestIngressPortsReachable(t *testing.T) {
 		_ = server.Close()
 		return client, nil
 	}
-	if !ingressPortsReachable() {
+	if !ingressPortsReachable(&config.Config{}) {
 		t.Fatal("expected ingress ports to be reachable")
 	}
 
 	cmdDialTimeoutFn = func(network, address string, timeout time.Duration) (net.Conn, error) {
 		return nil, errors.New("refused")
 	}
-	if ingressPortsReachable() {
+	if ingressPortsReachable(&config.Config{}) {
 		t.Fatal("expected ingress ports to be unreachable")
 	}
 }
@@ -49,23 +52,45 @@ func TestShouldReloadPortForwarding(t *testing.T) {
 		return client, nil
 	}
 
-	if shouldReloadPortForwarding(&mockCmdPortFwd{enabled: false, loaded: false}, true) {
+	cfg := &config.Config{}
+	if shouldReloadPortForwarding(cfg, &mockCmdPortFwd{enabled: false, loaded: false}, true) {
 		t.Fatal("expected no reload when forwarding is not configured")
 	}
-	if !shouldReloadPortForwarding(&mockCmdPortFwd{enabled: true, loaded: false}, false) {
+	if !shouldReloadPortForwarding(cfg, &mockCmdPortFwd{enabled: true, loaded: false}, false) {
 		t.Fatal("expected reload when configured but not loaded")
 	}
-	if shouldReloadPortForwarding(&mockCmdPortFwd{enabled: true, loaded: true}, true) {
+	if shouldReloadPortForwarding(cfg, &mockCmdPortFwd{enabled: true, loaded: true}, true) {
 		t.Fatal("expected no reload when configured, loaded, and ingress is healthy")
 	}
 
 	cmdDialTimeoutFn = func(network, address string, timeout time.Duration) (net.Conn, error) {
 		return nil, errors.New("refused")
 	}
-	if !shouldReloadPortForwarding(&mockCmdPortFwd{enabled: true, loaded: true}, true) {
+	if !shouldReloadPortForwarding(cfg, &mockCmdPortFwd{enabled: true, loaded: true}, true) {
 		t.Fatal("expected reload when ingress is down while daemon is running")
 	}
-	if shouldReloadPortForwarding(&mockCmdPortFwd{enabled: true, loaded: true}, false) {
+	if shouldReloadPortForwarding(cfg, &mockCmdPortFwd{enabled: true, loaded: true}, false) {
 		t.Fatal("expected no reload when daemon is not running")
 	}
+	if shouldReloadPortForwarding(&config.Config{NoPortForward: true}, &mockCmdPortFwd{enabled: true, loaded: false}, true) {
+		t.Fatal("expected no reload in rootless mode")
+	}
+}
+
+func TestIngressPortsReachableDialsPublicPorts(t *testing.T) {
+	prev := cmdDialTimeoutFn
+	defer func() { cmdDialTimeoutFn = prev }()
+
+	var dialed []string
+	cmdDialTimeoutFn = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		dialed = append(dialed, address)
+		client, server := net.Pipe()
+		_ = server.Close()
+		return client, nil
+	}
+
+	ingressPortsReachable(&config.Config{NoPortForward: true, HTTPSPort: 8443})
+	if want := []string{"127.0.0.1:10080", "127.0.0.1:8443"}; !slices.Equal(dialed, want) {
+		t.Fatalf("expected dials to %v, got %v", want, dialed)
+	}
 }
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/log"
+	"github.com/kamranahmedse/slim/internal/proxy"
+	"github.com/kamranahmedse/slim/internal/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayHeaders       []string
+	replayRemoveHeaders []string
+	replayBody          string
+	replayBodyFile      string
+
+	replaySendIPCFn = daemon.SendIPC
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <request-id>",
+	Short: "Replay a captured request against its upstream",
+	Long: `Send a request captured by the inspector again, through the same route,
+and show how the response differs from the original. Request ids are shown
+in the inspector at https://slim.test.
+
+  slim replay 42                                 # replay as captured
+  slim replay 42 --header "Authorization: Bearer x"
+  slim replay 42 --remove-header Cookie
+  slim replay 42 --body '{"event":"push"}'
+  slim replay 42 --body-file payload.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid request id %q", args[0])
+		}
+		opts, err := replayOptions(cmd.Flags().Changed("body"))
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(daemon.ReplayRequest{ID: id, Options: opts})
+		if err != nil {
+			return err
+		}
+		resp, err := replaySendIPCFn(daemon.Request{Type: daemon.MsgReplay, Data: data})
+		if err != nil {
+			return err
+		}
+		if !resp.OK {
+			return errors.New(resp.Error)
+		}
+
+		var result proxy.ReplayResult
+		if err := json.Unmarshal(resp.Data, &result); err != nil {
+			return fmt.Errorf("reading replay result: %w", err)
+		}
+		printReplay(&result)
+		return nil
+	},
+}
+
+func replayOptions(bodySet bool) (proxy.ReplayOptions, error) {
+	var opts proxy.ReplayOptions
+	for _, h := range replayHeaders {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return opts, fmt.Errorf("invalid header %q (expected \"Name: value\")", h)
+		}
+		if opts.Set == nil {
+			opts.Set = map[string]string{}
+		}
+		opts.Set[name] = strings.TrimSpace(value)
+	}
+	opts.Remove = replayRemoveHeaders
+
+	if bodySet && replayBodyFile != "" {
+		return opts, fmt.Errorf("--body and --body-file cannot be used together")
+	}
+	switch {
+	case bodySet:
+		body := []byte(replayBody)
+		opts.Body = &body
+	case replayBodyFile != "":
+		body, err := os.ReadFile(replayBodyFile)
+		if err != nil {
+			return opts, fmt.Errorf("reading body file: %w", err)
+		}
+		opts.Body = &body
+	}
+	return opts, nil
+}
+
+func printReplay(result *proxy.ReplayResult) {
+	r := result.Replay
+	status := term.StyleForStatus(r.Status).Render(fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)))
+	fmt.Printf("Replayed #%d as #%d: %s %s%s → %s in %s\n\n",
+		result.Original.ID, r.ID, r.Method, r.Domain, r.URI, status, log.FormatDuration(r.Duration))
+
+	if !result.Changed {
+		fmt.Printf("%s Response matches the original.\n", term.CheckMark)
+		return
+	}
+	for _, line := range result.Diff {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(term.Green.Render(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(term.Red.Render(line))
+		default:
+			fmt.Println(term.Dim.Render(line))
+		}
+	}
+}
+
+func init() {
+	replayCmd.Flags().StringArrayVarP(&replayHeaders, "header", "H", nil, "Set a request header (\"Name: value\"), repeatable")
+	replayCmd.Flags().StringArrayVar(&replayRemoveHeaders, "remove-header", nil, "Remove a request header, repeatable")
+	replayCmd.Flags().StringVar(&replayBody, "body", "", "Replace the request body")
+	replayCmd.Flags().StringVar(&replayBodyFile, "body-file", "", "Replace the request body with the contents of a file")
+	rootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReplayOptions(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(bodyFile, []byte(`{"a":1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		headers  []string
+		body     string
+		bodySet  bool
+		bodyFile string
+		wantSet  map[string]string
+		wantBody string
+		wantErr  string
+	}{
+		{name: "no edits"},
+		{name: "headers", headers: []string{"Authorization: Bearer x", "X-Empty:"}, wantSet: map[string]string{"Authorization": "Bearer x", "X-Empty": ""}},
+		{name: "inline body", body: "hello", bodySet: true, wantBody: "hello"},
+		{name: "empty inline body", bodySet: true, wantBody: ""},
+		{name: "body file", bodyFile: bodyFile, wantBody: `{"a":1}`},
+		{name: "bad header", headers: []string{"nocolon"}, wantErr: "invalid header"},
+		{name: "both bodies", bodySet: true, bodyFile: bodyFile, wantErr: "cannot be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayHeaders, replayBody, replayBodyFile = tt.headers, tt.body, tt.bodyFile
+			defer func() { replayHeaders, replayBody, replayBodyFile = nil, "", "" }()
+
+			opts, err := replayOptions(tt.bodySet)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("replayOptions: %v", err)
+			}
+			if !reflect.DeepEqual(opts.Set, tt.wantSet) {
+				t.Fatalf("expected headers %v, got %v", tt.wantSet, opts.Set)
+			}
+			if tt.bodySet || tt.bodyFile != "" {
+				if opts.Body == nil || string(*opts.Body) != tt.wantBody {
+					t.Fatalf("expected body %q, got %v", tt.wantBody, opts.Body)
+				}
+			} else if opts.Body != nil {
+				t.Fatalf("expected captured body to be kept, got %q", *opts.Body)
+			}
+		})
+	}
+}
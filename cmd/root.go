@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
 	"github.com/kamranahmedse/slim/internal/term"
 	"github.com/spf13/cobra"
 )
@@ -43,6 +44,7 @@ func Execute() error {
 	if err := config.Init(); err != nil {
 		return err
 	}
+	daemon.Version = Version
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.SilenceErrors = true
 
@@ -64,10 +66,10 @@ func normalizeName(input string) string {
 	return config.NormalizeDomain(input)
 }
 
-func printServices(domains []config.Domain) {
+func printServices(cfg *config.Config, domains []config.Domain) {
 	maxLen := 0
 	for _, d := range domains {
-		u := len("https://") + len(d.Name)
+		u := len(cfg.URL(d.Name))
 		if u > maxLen {
 			maxLen = u
 		}
@@ -81,14 +83,16 @@ func printServices(domains []config.Domain) {
 	arrow := term.Dim.Render("→")
 
 	for _, d := range domains {
-		url := "https://" + d.Name
+		url := cfg.URL(d.Name)
 		fmt.Printf("%s %s  %s  %s\n",
 			term.CheckMark, term.Green.Render(fmt.Sprintf("%-*s", maxLen, url)),
-			arrow, term.Dim.Render(fmt.Sprintf("localhost:%d", d.Port)))
+			arrow, term.Dim.Render(d.Target().String()))
 		for _, r := range d.Routes {
 			fmt.Printf("  %s  %s  %s\n",
 				term.Green.Render(fmt.Sprintf("%-*s", maxLen, url+r.Path)),
-				arrow, term.Dim.Render(fmt.Sprintf("localhost:%d", r.Port)))
+				arrow, term.Dim.Render(r.Target().String()))
 		}
 	}
+
+	fmt.Println(term.Dim.Render(fmt.Sprintf("\nInspect requests at %s", cfg.URL(config.InspectorDomain))))
 }
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/system"
+	"github.com/kamranahmedse/slim/internal/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newServiceFn            = system.NewService
+	serviceExecutableFn     = os.Executable
+	serviceDaemonRunningFn  = daemon.IsRunning
+	serviceDaemonSendIPCFn  = daemon.SendIPC
+	serviceDaemonDetachedFn = daemon.RunDetached
+	serviceDaemonWaitFn     = daemon.WaitForDaemon
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run the daemon as a systemd user service",
+	Long: `Let systemd supervise the daemon instead of slim forking it. The service
+restarts the daemon when it fails, starts it again after a reboot and sends
+its output to the journal (journalctl --user -u slim).
+
+  slim service install     # generate, enable and start the unit
+  slim service status      # show whether it is installed and running
+  slim service uninstall   # go back to forking the daemon`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install and start the systemd user service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newServiceFn()
+		if err != nil {
+			return err
+		}
+		exe, err := serviceExecutableFn()
+		if err != nil {
+			return fmt.Errorf("finding slim binary: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		if err := svc.Install(exe); err != nil {
+			return err
+		}
+
+		// A forked daemon holds the listener ports; hand them over.
+		if serviceDaemonRunningFn() && !svc.Active() {
+			if _, err := serviceDaemonSendIPCFn(daemon.Request{Type: daemon.MsgShutdown}); err != nil {
+				return fmt.Errorf("stopping daemon: %w", err)
+			}
+			waitForDaemonExit()
+		}
+		if err := svc.Start(); err != nil {
+			return err
+		}
+		if err := serviceDaemonWaitFn(); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s Installed %s; the daemon now runs under systemd\n", term.CheckMark, svc.Name())
+		fmt.Println(term.Dim.Render("  Logs: journalctl --user -u slim"))
+		fmt.Println(term.Dim.Render("  To start it at boot without logging in: loginctl enable-linger $USER"))
+		return nil
+	},
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the systemd user service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newServiceFn()
+		if err != nil {
+			return err
+		}
+		if !svc.Installed() {
+			fmt.Println("The systemd user service is not installed.")
+			return nil
+		}
+		if err := svc.Uninstall(); err != nil {
+			return err
+		}
+		fmt.Printf("%s Removed %s; the daemon is stopped (run 'slim start' to start it again)\n", term.CheckMark, svc.Name())
+		return nil
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the systemd user service status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newServiceFn()
+		if err != nil {
+			return err
+		}
+		if !svc.Installed() {
+			fmt.Printf("%s %s is not installed (run: slim service install)\n", term.CrossMark, svc.Name())
+			return nil
+		}
+		fmt.Printf("%s %s is installed\n", term.CheckMark, svc.Name())
+		if svc.Enabled() {
+			fmt.Printf("%s enabled, starts at login\n", term.CheckMark)
+		} else {
+			fmt.Printf("%s not enabled (run: slim service install)\n", term.CrossMark)
+		}
+		if svc.Active() {
+			fmt.Printf("%s running\n", term.CheckMark)
+		} else {
+			fmt.Printf("%s not running (see: journalctl --user -u slim)\n", term.CrossMark)
+		}
+		return nil
+	},
+}
+
+// installedService returns the systemd user service when it is installed.
+func installedService() (system.Service, bool) {
+	svc, err := newServiceFn()
+	if err != nil || !svc.Installed() {
+		return nil, false
+	}
+	return svc, true
+}
+
+// startDaemon asks systemd to start the daemon when the user service is
+// installed, and forks it otherwise.
+func startDaemon() error {
+	if svc, ok := installedService(); ok {
+		return svc.Start()
+	}
+	return serviceDaemonDetachedFn()
+}
+
+// stopDaemon shuts the daemon down, through systemd when the user service
+// runs it.
+func stopDaemon(sendIPC func(daemon.Request) (*daemon.Response, error)) error {
+	if svc, ok := installedService(); ok && svc.Active() {
+		return svc.Stop()
+	}
+	_, err := sendIPC(daemon.Request{Type: daemon.MsgShutdown})
+	return err
+}
+
+func waitForDaemonExit() {
+	for i := 0; i < 50 && serviceDaemonRunningFn(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	serviceCmd.AddCommand(serviceStatusCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
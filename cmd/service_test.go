@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/system"
+)
+
+type fakeService struct {
+	installed bool
+	active    bool
+	exe       string
+	calls     []string
+}
+
+func (f *fakeService) Name() string { return "slim.service" }
+func (f *fakeService) Install(exe string) error {
+	f.calls = append(f.calls, "install")
+	f.installed, f.exe = true, exe
+	return nil
+}
+func (f *fakeService) Uninstall() error {
+	f.calls = append(f.calls, "uninstall")
+	f.installed, f.active = false, false
+	return nil
+}
+func (f *fakeService) Start() error    { f.calls = append(f.calls, "start"); f.active = true; return nil }
+func (f *fakeService) Stop() error     { f.calls = append(f.calls, "stop"); f.active = false; return nil }
+func (f *fakeService) Installed() bool { return f.installed }
+func (f *fakeService) Enabled() bool   { return f.installed }
+func (f *fakeService) Active() bool    { return f.active }
+
+func setupServiceTestHooks(t *testing.T, svc *fakeService) *[]string {
+	t.Helper()
+	prevService := newServiceFn
+	prevExe := serviceExecutableFn
+	prevRunning := serviceDaemonRunningFn
+	prevIPC := serviceDaemonSendIPCFn
+	prevDetached := serviceDaemonDetachedFn
+	prevWait := serviceDaemonWaitFn
+	t.Cleanup(func() {
+		newServiceFn = prevService
+		serviceExecutableFn = prevExe
+		serviceDaemonRunningFn = prevRunning
+		serviceDaemonSendIPCFn = prevIPC
+		serviceDaemonDetachedFn = prevDetached
+		serviceDaemonWaitFn = prevWait
+	})
+
+	var events []string
+	if svc == nil {
+		newServiceFn = func() (system.Service, error) { return nil, errors.New("no systemd") }
+	} else {
+		newServiceFn = func() (system.Service, error) { return svc, nil }
+	}
+	serviceExecutableFn = func() (string, error) { return "/usr/local/bin/slim", nil }
+	serviceDaemonRunningFn = func() bool { return false }
+	serviceDaemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
+		events = append(events, "ipc:"+string(req.Type))
+		return &daemon.Response{OK: true}, nil
+	}
+	serviceDaemonDetachedFn = func() error { events = append(events, "fork"); return nil }
+	serviceDaemonWaitFn = func() error { return nil }
+	return &events
+}
+
+func TestStartDaemon(t *testing.T) {
+	tests := []struct {
+		name       string
+		svc        *fakeService
+		wantEvents []string
+		wantCalls  []string
+	}{
+		{"no systemd", nil, []string{"fork"}, nil},
+		{"service not installed", &fakeService{}, []string{"fork"}, nil},
+		{"service installed", &fakeService{installed: true}, nil, []string{"start"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := setupServiceTestHooks(t, tt.svc)
+			if err := startDaemon(); err != nil {
+				t.Fatalf("startDaemon: %v", err)
+			}
+			if !reflect.DeepEqual(*events, tt.wantEvents) {
+				t.Fatalf("expected events %v, got %v", tt.wantEvents, *events)
+			}
+			if tt.svc != nil && !reflect.DeepEqual(tt.svc.calls, tt.wantCalls) {
+				t.Fatalf("expected service calls %v, got %v", tt.wantCalls, tt.svc.calls)
+			}
+		})
+	}
+}
+
+func TestStopDaemon(t *testing.T) {
+	tests := []struct {
+		name      string
+		svc       *fakeService
+		wantIPC   bool
+		wantCalls []string
+	}{
+		{"no systemd", nil, true, nil},
+		{"installed but not running under systemd", &fakeService{installed: true}, true, nil},
+		{"running under systemd", &fakeService{installed: true, active: true}, false, []string{"stop"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupServiceTestHooks(t, tt.svc)
+			sent := false
+			err := stopDaemon(func(req daemon.Request) (*daemon.Response, error) {
+				sent = req.Type == daemon.MsgShutdown
+				return &daemon.Response{OK: true}, nil
+			})
+			if err != nil {
+				t.Fatalf("stopDaemon: %v", err)
+			}
+			if sent != tt.wantIPC {
+				t.Fatalf("expected shutdown over IPC %v, got %v", tt.wantIPC, sent)
+			}
+			if tt.svc != nil && !reflect.DeepEqual(tt.svc.calls, tt.wantCalls) {
+				t.Fatalf("expected service calls %v, got %v", tt.wantCalls, tt.svc.calls)
+			}
+		})
+	}
+}
+
+func TestServiceInstallHandsOverFromForkedDaemon(t *testing.T) {
+	svc := &fakeService{}
+	events := setupServiceTestHooks(t, svc)
+	running := true
+	serviceDaemonRunningFn = func() bool { return running }
+	serviceDaemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
+		*events = append(*events, "ipc:"+string(req.Type))
+		running = false
+		return &daemon.Response{OK: true}, nil
+	}
+
+	if err := serviceInstallCmd.RunE(serviceInstallCmd, nil); err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if svc.exe != "/usr/local/bin/slim" {
+		t.Fatalf("expected the unit to run /usr/local/bin/slim, got %q", svc.exe)
+	}
+	if want := []string{"ipc:shutdown"}; !reflect.DeepEqual(*events, want) {
+		t.Fatalf("expected the forked daemon to be shut down, got %v", *events)
+	}
+	if want := []string{"install", "start"}; !reflect.DeepEqual(svc.calls, want) {
+		t.Fatalf("expected service calls %v, got %v", want, svc.calls)
+	}
+}
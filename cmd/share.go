@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/signal"
 	"strings"
@@ -10,6 +11,8 @@ import (
 
 	"github.com/kamranahmedse/slim/internal/auth"
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/event"
 	"github.com/kamranahmedse/slim/internal/log"
 	"github.com/kamranahmedse/slim/internal/term"
 	"github.com/kamranahmedse/slim/internal/tunnel"
@@ -22,6 +25,8 @@ var sharePassword string
 var shareTTL time.Duration
 var shareDomain string
 
+var shareSendIPCFn = daemon.SendIPC
+
 var shareCmd = &cobra.Command{
 	Use:   "share",
 	Short: "Share a local port via tunnel",
@@ -80,6 +85,9 @@ var shareCmd = &cobra.Command{
 					term.Dim.Render(log.FormatDuration(e.Duration)),
 				)
 			},
+			OnState: func(s tunnel.StateChange) {
+				publishTunnelState(port, s)
+			},
 		})
 
 		url, err := client.Connect(ctx)
@@ -132,6 +140,24 @@ var shareCmd = &cobra.Command{
 	},
 }
 
+// publishTunnelState passes tunnel state changes on to daemon subscribers.
+// Sharing does not need the daemon, so failing to reach it is fine.
+func publishTunnelState(port int, s tunnel.StateChange) {
+	data := event.Tunnel{State: s.State, URL: s.URL, LocalPort: port}
+	if s.Err != nil {
+		data.Error = s.Err.Error()
+	}
+	e, err := event.New(event.TunnelState, data)
+	if err != nil {
+		return
+	}
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_, _ = shareSendIPCFn(daemon.Request{Type: daemon.MsgPublish, Data: raw})
+}
+
 func init() {
 	shareCmd.Flags().IntVarP(&sharePort, "port", "p", 0, "Local port to expose")
 	_ = shareCmd.MarkFlagRequired("port")
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -17,26 +18,78 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var startPort int
+var startPorts []int
+var startBalance string
+var startSticky bool
+var startUpstream string
+var startSocket string
+var startDir string
+var startSPA bool
+var startListing bool
+var startScheme string
+var startTLSInsecure bool
+var startTLSCA string
+var startTLSSlimCA bool
 var startLogMode string
 var startCors bool
 var startWait bool
 var startWaitTimeout time.Duration
 var startRoutes []string
+var startHosts []string
+var startNoPortForward bool
 
 var startCmd = &cobra.Command{
 	Use:   "start [name] --port [port]",
 	Short: "Start proxying a domain",
-	Long: `Map a local domain to a port and start proxying.
+	Long: `Map a local domain to a port or upstream URL and start proxying.
 Runs first-time setup automatically if needed.
 
   slim start myapp --port 3000        # https://myapp.test → localhost:3000
-  slim start app.loc --port 3000      # https://app.loc → localhost:3000`,
+  slim start myapp --port 3000,3001   # balance across both ports
+  slim start app.loc --port 3000      # https://app.loc → localhost:3000
+  slim start api --upstream http://10.0.0.5:8080
+  slim start myapp --socket /tmp/app.sock
+  slim start docs --dir ./dist --spa
+  slim start '*.myapp.test' --port 3000 --host tenant1 --host tenant2
+  slim start myapp --port 8443 --scheme https --tls-insecure
+  slim start myapp --port 3000 --no-port-forward  # https://myapp.test:10443, no sudo`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := normalizeName(args[0])
 
-		if err := config.ValidateDomain(name, startPort); err != nil {
+		if err := config.ValidateDomainName(name); err != nil {
+			return err
+		}
+		socket, err := absSocketPath(startSocket)
+		if err != nil {
+			return err
+		}
+		dir, err := absDirPath(startDir)
+		if err != nil {
+			return err
+		}
+		upstreamTLS, err := startUpstreamTLS()
+		if err != nil {
+			return err
+		}
+		port, ports := splitStartPorts(startPorts)
+		target := config.Target{
+			Port:     port,
+			Ports:    ports,
+			Balance:  startBalance,
+			Sticky:   startSticky,
+			Upstream: startUpstream,
+			Socket:   socket,
+			Dir:      dir,
+			Scheme:   startScheme,
+		}
+		if dir != "" {
+			target.SPA, target.Listing = startSPA, startListing
+		}
+		if target.IsHTTPS() {
+			target.TLS = upstreamTLS
+		}
+		if err := target.Validate(); err != nil {
 			return err
 		}
 		if strings.HasSuffix(name, ".local") {
@@ -55,29 +108,109 @@ Runs first-time setup automatically if needed.
 		if err != nil {
 			return err
 		}
+		if startSPA || startListing {
+			applied := dir != ""
+			for i := range routes {
+				if routes[i].Dir != "" {
+					routes[i].SPA, routes[i].Listing = startSPA, startListing
+					applied = true
+				}
+			}
+			if !applied {
+				return fmt.Errorf("--spa and --listing require a static dir")
+			}
+		}
+		if upstreamTLS != nil {
+			applied := target.TLS != nil
+			for i := range routes {
+				if routes[i].Target().IsHTTPS() {
+					routes[i].TLS = upstreamTLS
+					applied = true
+				}
+			}
+			if !applied {
+				return fmt.Errorf("--tls-* flags require an https upstream")
+			}
+		}
+		var hosts []string
+		if len(startHosts) > 0 {
+			if !config.IsWildcardDomain(name) {
+				return fmt.Errorf("--host requires a wildcard domain like *.myapp.test")
+			}
+			for _, h := range startHosts {
+				hosts = append(hosts, config.QualifyHost(name, h))
+			}
+		}
+		domain := config.Domain{
+			Name:     name,
+			Port:     port,
+			Ports:    ports,
+			Balance:  startBalance,
+			Sticky:   startSticky,
+			Upstream: startUpstream,
+			Socket:   socket,
+			Dir:      dir,
+			SPA:      target.SPA,
+			Listing:  target.Listing,
+			Scheme:   startScheme,
+			TLS:      target.TLS,
+			Hosts:    hosts,
+			Routes:   routes,
+		}
+		if err := domain.Validate(); err != nil {
+			return err
+		}
 
-		if err := setup.EnsureFirstRun(); err != nil {
+		current, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("no-port-forward") {
+			current.NoPortForward = startNoPortForward
+		}
+		if err := setup.EnsureFirstRun(current); err != nil {
 			return err
 		}
 
-		if err := config.WithLock(func() error {
-			cfg, err := config.Load()
-			if err != nil {
+		opts := daemon.SetOptionsRequest{}
+		if cmd.Flags().Changed("cors") {
+			opts.Cors = &startCors
+		}
+		if cmd.Flags().Changed("no-port-forward") {
+			opts.NoPortForward = &startNoPortForward
+		}
+		if startLogMode != "" {
+			opts.LogMode = &startLogMode
+		}
+		running := daemon.IsRunning()
+		var passphrase string
+		if cert.NeedsLeafCert(name) {
+			if passphrase, err = unlockCA(running, daemon.SendIPC); err != nil {
 				return err
 			}
-			if cmd.Flags().Changed("cors") {
-				cfg.Cors = startCors
-			}
-			if startLogMode != "" {
-				cfg.LogMode = strings.ToLower(strings.TrimSpace(startLogMode))
-			}
-			return cfg.SetDomain(name, startPort, routes)
-		}); err != nil {
+		}
+		diff, err := changeConfig(running, daemon.SendIPC, daemon.MsgAddDomain, daemon.AddDomainRequest{
+			Domains:   []config.Domain{domain},
+			KeepHosts: true,
+			Options:   opts,
+		})
+		if err != nil {
 			return err
 		}
+		cfg := diff.Config
+		if d, _ := cfg.FindDomain(name); d != nil {
+			domain = *d
+		}
 
-		if err := system.AddHost(name); err != nil {
-			return fmt.Errorf("updating /etc/hosts: %w", err)
+		for _, c := range diff.Updated {
+			for _, host := range c.StaleHosts {
+				if err := system.RemoveHost(host); err != nil {
+					return fmt.Errorf("updating /etc/hosts: %w", err)
+				}
+			}
+		}
+		if err := registerHosts(cfg, domain.HostNames(), system.AddHost); err != nil {
+			return err
 		}
 
 		if err := cert.EnsureLeafCert(name); err != nil {
@@ -86,32 +219,33 @@ Runs first-time setup automatically if needed.
 
 		if !daemon.IsChild() {
 			pf := system.NewPortForwarder()
-			if shouldReloadPortForwarding(pf, daemon.IsRunning()) {
+			if shouldReloadPortForwarding(cfg, pf, running) {
 				if err := pf.EnsureLoaded(); err != nil {
 					return fmt.Errorf("loading port forwarding rules: %w", err)
 				}
 			}
 		}
 
-		if !daemon.IsRunning() {
-			if err := setup.EnsureProxyPortsAvailable(); err != nil {
+		if !running {
+			if err := setup.EnsureProxyPortsAvailable(cfg); err != nil {
 				return err
 			}
-			if err := daemon.RunDetached(); err != nil {
+			if err := startDaemon(); err != nil {
 				return fmt.Errorf("starting daemon: %w", err)
 			}
 			if err := daemon.WaitForDaemon(); err != nil {
 				return err
 			}
-		} else {
-			if _, err := daemon.SendIPC(daemon.Request{Type: daemon.MsgReload}); err != nil {
-				return fmt.Errorf("reloading daemon: %w", err)
+			if passphrase != "" {
+				if err := sendUnlockCA(daemon.SendIPC, passphrase); err != nil {
+					return err
+				}
 			}
 		}
 
 		if !daemon.IsChild() {
 			pf := system.NewPortForwarder()
-			if shouldReloadPortForwarding(pf, true) {
+			if shouldReloadPortForwarding(cfg, pf, true) {
 				if err := pf.EnsureLoaded(); err != nil {
 					return fmt.Errorf("loading port forwarding rules: %w", err)
 				}
@@ -119,25 +253,33 @@ Runs first-time setup automatically if needed.
 		}
 
 		if startWait {
-			waitPorts := []int{startPort}
-			for _, r := range routes {
-				waitPorts = append(waitPorts, r.Port)
-			}
-			for _, p := range waitPorts {
-				fmt.Printf("Waiting for localhost:%d (timeout %s)... ", p, startWaitTimeout)
-				if err := proxy.WaitForUpstream(p, startWaitTimeout); err != nil {
-					fmt.Println("timed out")
-					return err
+			for _, t := range domain.Targets() {
+				for _, e := range t.Expand() {
+					u, err := e.URL()
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Waiting for %s (timeout %s)... ", e, startWaitTimeout)
+					if err := proxy.WaitForUpstream(u, startWaitTimeout); err != nil {
+						fmt.Println("timed out")
+						return err
+					}
+					fmt.Println("ready")
 				}
-				fmt.Println("ready")
 			}
 		}
 
-		printServices([]config.Domain{{Name: name, Port: startPort, Routes: routes}})
+		printServices(cfg, []config.Domain{domain})
+		if config.IsWildcardDomain(name) && len(domain.Hosts) == 0 && !cfg.DNS {
+			fmt.Println(term.Dim.Render("  /etc/hosts cannot hold wildcards; register names with --host <subdomain> or run 'slim dns enable'"))
+		}
 		return nil
 	},
 }
 
+// parseRouteFlags parses --route values of the form
+// path=target[,strip_prefix=false][,rewrite=<regex>=><replacement>]. The
+// rewrite option takes the rest of the value, so it must come last.
 func parseRouteFlags(flags []string) ([]config.Route, error) {
 	if len(flags) == 0 {
 		return nil, nil
@@ -148,18 +290,109 @@ func parseRouteFlags(flags []string) ([]config.Route, error) {
 		if len(parts) != 2 {
 			return nil, fmt.Errorf("invalid route %q: expected path=port (e.g. /api=8080)", f)
 		}
-		port, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return nil, fmt.Errorf("invalid route port %q: %w", parts[1], err)
+		route := config.Route{Path: parts[0]}
+
+		rest, rewrite, hasRewrite := strings.Cut(parts[1], ",rewrite=")
+		options := strings.Split(rest, ",")
+		target := options[0]
+		for _, opt := range options[1:] {
+			key, value, _ := strings.Cut(opt, "=")
+			if key != "strip_prefix" {
+				return nil, fmt.Errorf("invalid route %q: unknown option %q", f, key)
+			}
+			strip, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid route %q: strip_prefix must be true or false", f)
+			}
+			route.StripPrefix = &strip
+		}
+		if hasRewrite {
+			pattern, replace, ok := strings.Cut(rewrite, "=>")
+			if !ok {
+				return nil, fmt.Errorf("invalid route %q: expected rewrite=<regex>=><replacement>", f)
+			}
+			route.Rewrite = &config.PathRewrite{Pattern: pattern, Replace: replace}
+		}
+
+		if socket, ok := strings.CutPrefix(target, "unix:"); ok {
+			abs, err := absSocketPath(socket)
+			if err != nil {
+				return nil, err
+			}
+			route.Socket = abs
+		} else if dir, ok := strings.CutPrefix(target, "dir:"); ok {
+			abs, err := absDirPath(dir)
+			if err != nil {
+				return nil, err
+			}
+			route.Dir = abs
+		} else if strings.Contains(target, "://") {
+			route.Upstream = target
+		} else {
+			port, err := strconv.Atoi(target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid route port %q: %w", target, err)
+			}
+			route.Port = port
 		}
-		if err := config.ValidateRoute(parts[0], port); err != nil {
+		if err := route.Validate(); err != nil {
 			return nil, err
 		}
-		routes = append(routes, config.Route{Path: parts[0], Port: port})
+		routes = append(routes, route)
 	}
 	return routes, nil
 }
 
+// splitStartPorts maps --port values onto a single port or, when repeated, a
+// set of ports to balance across.
+func splitStartPorts(values []int) (int, []int) {
+	switch len(values) {
+	case 0:
+		return 0, nil
+	case 1:
+		return values[0], nil
+	default:
+		return 0, values
+	}
+}
+
+func startUpstreamTLS() (*config.UpstreamTLS, error) {
+	if !startTLSInsecure && startTLSCA == "" && !startTLSSlimCA {
+		return nil, nil
+	}
+	caFile := ""
+	if startTLSCA != "" {
+		abs, err := filepath.Abs(startTLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("resolving CA file path %q: %w", startTLSCA, err)
+		}
+		caFile = abs
+	}
+	return &config.UpstreamTLS{Insecure: startTLSInsecure, CAFile: caFile, SlimCA: startTLSSlimCA}, nil
+}
+
+func absSocketPath(socket string) (string, error) {
+	if socket == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(socket)
+	if err != nil {
+		return "", fmt.Errorf("resolving socket path %q: %w", socket, err)
+	}
+	return abs, nil
+}
+
+func absDirPath(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving dir path %q: %w", dir, err)
+	}
+	return abs, nil
+}
+
 func validateStartWaitFlags(timeoutChanged bool, wait bool, timeout time.Duration) error {
 	if timeoutChanged && !wait {
 		return fmt.Errorf("--timeout requires --wait")
@@ -171,12 +404,26 @@ func validateStartWaitFlags(timeoutChanged bool, wait bool, timeout time.Duratio
 }
 
 func init() {
-	startCmd.Flags().IntVarP(&startPort, "port", "p", 0, "Local port to proxy to (required)")
-	startCmd.Flags().StringArrayVar(&startRoutes, "route", nil, "Route a path to a different port (e.g. /api=8080), repeatable")
+	startCmd.Flags().IntSliceVarP(&startPorts, "port", "p", nil, "Local port to proxy to; repeat or comma-separate to balance across several")
+	startCmd.Flags().StringVar(&startBalance, "balance", "", "Balancing across multiple ports: round_robin|least_conn|random")
+	startCmd.Flags().BoolVar(&startSticky, "sticky", false, "Pin each browser to one port with a cookie when balancing")
+	startCmd.Flags().StringVar(&startUpstream, "upstream", "", "Upstream URL to proxy to instead of a local port (e.g. http://10.0.0.5:8080)")
+	startCmd.Flags().StringVar(&startSocket, "socket", "", "Unix domain socket to proxy to instead of a local port")
+	startCmd.Flags().StringVar(&startDir, "dir", "", "Static directory to serve instead of proxying")
+	startCmd.Flags().BoolVar(&startSPA, "spa", false, "Serve index.html for unknown pages of static dirs")
+	startCmd.Flags().BoolVar(&startListing, "listing", false, "List static directories that have no index.html")
+	startCmd.Flags().StringVar(&startScheme, "scheme", "", "Scheme to use for the local port: http|https")
+	startCmd.Flags().BoolVar(&startTLSInsecure, "tls-insecure", false, "Skip certificate verification for https upstreams")
+	startCmd.Flags().StringVar(&startTLSCA, "tls-ca", "", "PEM CA bundle to trust for https upstreams")
+	startCmd.Flags().BoolVar(&startTLSSlimCA, "tls-slim-ca", false, "Trust the slim root CA for https upstreams")
+	startCmd.Flags().StringArrayVar(&startRoutes, "route", nil, "Route a path to a different port, URL, unix:<socket> or dir:<path> (e.g. /api=8080), repeatable. Append ,strip_prefix=false or ,rewrite=<regex>=><replacement> to control the forwarded path")
+	startCmd.Flags().StringSliceVar(&startHosts, "host", nil, "Name under a wildcard domain to add to /etc/hosts (e.g. tenant1), repeatable")
 	startCmd.Flags().StringVar(&startLogMode, "log-mode", "", "Access log mode: full|minimal|off")
 	startCmd.Flags().BoolVar(&startCors, "cors", false, "Enable CORS headers on proxied responses")
+	startCmd.Flags().BoolVar(&startNoPortForward, "no-port-forward", false, "Rootless mode: serve on the listener ports instead of forwarding 80/443")
 	startCmd.Flags().BoolVar(&startWait, "wait", false, "Wait for the upstream app to become reachable before returning")
 	startCmd.Flags().DurationVar(&startWaitTimeout, "timeout", 30*time.Second, "Maximum time to wait for upstream with --wait")
-	_ = startCmd.MarkFlagRequired("port")
+	startCmd.MarkFlagsOneRequired("port", "upstream", "socket", "dir")
+	startCmd.MarkFlagsMutuallyExclusive("port", "upstream", "socket", "dir")
 	rootCmd.AddCommand(startCmd)
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -9,6 +10,7 @@ import (
 )
 
 func TestParseRouteFlags(t *testing.T) {
+	keepPrefix := false
 	tests := []struct {
 		name    string
 		flags   []string
@@ -30,6 +32,61 @@ func TestParseRouteFlags(t *testing.T) {
 			flags: []string{"/api=8080", "/ws=9000"},
 			want:  []config.Route{{Path: "/api", Port: 8080}, {Path: "/ws", Port: 9000}},
 		},
+		{
+			name:  "upstream url route",
+			flags: []string{"/api=http://10.0.0.5:8080"},
+			want:  []config.Route{{Path: "/api", Upstream: "http://10.0.0.5:8080"}},
+		},
+		{
+			name:  "unix socket route",
+			flags: []string{"/api=unix:/tmp/api.sock"},
+			want:  []config.Route{{Path: "/api", Socket: "/tmp/api.sock"}},
+		},
+		{
+			name:  "static dir route",
+			flags: []string{"/docs=dir:/srv/docs"},
+			want:  []config.Route{{Path: "/docs", Dir: "/srv/docs"}},
+		},
+		{
+			name:  "keep prefix",
+			flags: []string{"/api=8080,strip_prefix=false"},
+			want:  []config.Route{{Path: "/api", Port: 8080, StripPrefix: &keepPrefix}},
+		},
+		{
+			name:  "rewrite",
+			flags: []string{"/api=8080,strip_prefix=false,rewrite=^/api/(.*)=>/v2/$1"},
+			want:  []config.Route{{Path: "/api", Port: 8080, StripPrefix: &keepPrefix, Rewrite: &config.PathRewrite{Pattern: "^/api/(.*)", Replace: "/v2/$1"}}},
+		},
+		{
+			name:  "rewrite with commas in pattern",
+			flags: []string{"/v=http://10.0.0.5:8080,rewrite=^/v[0-9]{1,2}=>/"},
+			want:  []config.Route{{Path: "/v", Upstream: "http://10.0.0.5:8080", Rewrite: &config.PathRewrite{Pattern: "^/v[0-9]{1,2}", Replace: "/"}}},
+		},
+		{
+			name:    "unknown option",
+			flags:   []string{"/api=8080,foo=bar"},
+			wantErr: "unknown option",
+		},
+		{
+			name:    "invalid strip_prefix",
+			flags:   []string{"/api=8080,strip_prefix=maybe"},
+			wantErr: "strip_prefix must be true or false",
+		},
+		{
+			name:    "rewrite without replacement",
+			flags:   []string{"/api=8080,rewrite=^/api"},
+			wantErr: "expected rewrite",
+		},
+		{
+			name:    "invalid rewrite pattern",
+			flags:   []string{"/api=8080,rewrite=(=>/"},
+			wantErr: "invalid rewrite pattern",
+		},
+		{
+			name:    "invalid upstream url",
+			flags:   []string{"/api=ftp://10.0.0.5"},
+			wantErr: "scheme must be http or https",
+		},
 		{
 			name:    "missing equals",
 			flags:   []string{"/api8080"},
@@ -66,7 +123,7 @@ func TestParseRouteFlags(t *testing.T) {
 				t.Fatalf("expected %d routes, got %d", len(tt.want), len(got))
 			}
 			for i := range got {
-				if got[i] != tt.want[i] {
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
 					t.Fatalf("route[%d]: got %+v, want %+v", i, got[i], tt.want[i])
 				}
 			}
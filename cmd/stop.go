@@ -3,18 +3,15 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/kamranahmedse/slim/internal/config"
 	"github.com/kamranahmedse/slim/internal/daemon"
 	"github.com/kamranahmedse/slim/internal/system"
 	"github.com/spf13/cobra"
 )
 
 var (
-	configWithLockStopFn = config.WithLock
-	configLoadStopFn     = config.Load
-	systemRemoveHostFn   = system.RemoveHost
-	daemonIsRunningFn    = daemon.IsRunning
-	daemonSendIPCFn      = daemon.SendIPC
+	systemRemoveHostFn = system.RemoveHost
+	daemonIsRunningFn  = daemon.IsRunning
+	daemonSendIPCFn    = daemon.SendIPC
 )
 
 var stopCmd = &cobra.Command{
@@ -34,43 +31,25 @@ var stopCmd = &cobra.Command{
 }
 
 func stopOne(name string) error {
-	var remainingDomains int
-
-	if err := configWithLockStopFn(func() error {
-		cfg, err := configLoadStopFn()
-		if err != nil {
-			return err
-		}
-
-		if _, idx := cfg.FindDomain(name); idx == -1 {
-			return fmt.Errorf("%s is not running", name)
-		}
-
-		if err := cfg.RemoveDomain(name); err != nil {
-			return err
-		}
-		remainingDomains = len(cfg.Domains)
-		return nil
-	}); err != nil {
+	running := daemonIsRunningFn()
+	diff, err := changeConfig(running, daemonSendIPCFn, daemon.MsgRemoveDomain, daemon.RemoveDomainRequest{Names: []string{name}})
+	if err != nil {
 		return err
 	}
 
-	if err := systemRemoveHostFn(name); err != nil {
-		return fmt.Errorf("updating /etc/hosts: %w", err)
+	for _, d := range diff.Removed {
+		for _, host := range d.Hosts {
+			if err := systemRemoveHostFn(host); err != nil {
+				return fmt.Errorf("updating /etc/hosts: %w", err)
+			}
+		}
 	}
 
-	if daemonIsRunningFn() {
-		if remainingDomains == 0 {
-			if _, err := daemonSendIPCFn(daemon.Request{Type: daemon.MsgShutdown}); err != nil {
-				return fmt.Errorf("stopping daemon: %w", err)
-			}
-			fmt.Printf("Stopped %s (daemon shut down)\n", name)
-		} else {
-			if _, err := daemonSendIPCFn(daemon.Request{Type: daemon.MsgReload}); err != nil {
-				return fmt.Errorf("reloading daemon: %w", err)
-			}
-			fmt.Printf("Stopped %s\n", name)
+	if running && len(diff.Config.Domains) == 0 {
+		if err := stopDaemon(daemonSendIPCFn); err != nil {
+			return fmt.Errorf("stopping daemon: %w", err)
 		}
+		fmt.Printf("Stopped %s (daemon shut down)\n", name)
 	} else {
 		fmt.Printf("Stopped %s\n", name)
 	}
@@ -79,36 +58,27 @@ func stopOne(name string) error {
 }
 
 func stopAll() error {
-	var domains []config.Domain
-
-	if err := configWithLockStopFn(func() error {
-		cfg, err := configLoadStopFn()
-		if err != nil {
-			return err
-		}
-		domains = cfg.Domains
-		if len(domains) > 0 {
-			cfg.Domains = nil
-			return cfg.Save()
-		}
-		return nil
-	}); err != nil {
+	running := daemonIsRunningFn()
+	diff, err := changeConfig(running, daemonSendIPCFn, daemon.MsgRemoveDomain, daemon.RemoveDomainRequest{All: true})
+	if err != nil {
 		return err
 	}
 
-	if len(domains) == 0 && !daemonIsRunningFn() {
+	if len(diff.Removed) == 0 && !running {
 		fmt.Println("Nothing is running.")
 		return nil
 	}
 
-	for _, d := range domains {
-		if err := systemRemoveHostFn(d.Name); err != nil {
-			fmt.Printf("Warning: failed to remove %s from /etc/hosts: %v\n", d.Name, err)
+	for _, d := range diff.Removed {
+		for _, host := range d.Hosts {
+			if err := systemRemoveHostFn(host); err != nil {
+				fmt.Printf("Warning: failed to remove %s from /etc/hosts: %v\n", host, err)
+			}
 		}
 	}
 
-	if daemonIsRunningFn() {
-		if _, err := daemonSendIPCFn(daemon.Request{Type: daemon.MsgShutdown}); err != nil {
+	if running {
+		if err := stopDaemon(daemonSendIPCFn); err != nil {
 			return fmt.Errorf("stopping daemon: %w", err)
 		}
 	}
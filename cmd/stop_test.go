@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
+	"slices"
 	"strings"
 	"testing"
 
@@ -38,18 +40,15 @@ func TestStopOneSendsShutdownWhenLastDomain(t *testing.T) {
 	systemRemoveHostFn = func(string) error { return nil }
 	daemonIsRunningFn = func() bool { return true }
 
-	var gotType daemon.MessageType
-	daemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		gotType = req.Type
-		return &daemon.Response{OK: true}, nil
-	}
+	var types []daemon.MessageType
+	daemonSendIPCFn = fakeDaemonIPC(&types)
 
 	if err := stopOne("myapp.test"); err != nil {
 		t.Fatalf("stopOne: %v", err)
 	}
 
-	if gotType != daemon.MsgShutdown {
-		t.Fatalf("expected IPC type %q, got %q", daemon.MsgShutdown, gotType)
+	if !slices.Equal(types, []daemon.MessageType{daemon.MsgRemoveDomain, daemon.MsgShutdown}) {
+		t.Fatalf("expected remove_domain then shutdown IPC, got %v", types)
 	}
 
 	cfg, err := config.Load()
@@ -61,7 +60,30 @@ func TestStopOneSendsShutdownWhenLastDomain(t *testing.T) {
 	}
 }
 
-func TestStopOneSendsReloadWhenDomainsRemain(t *testing.T) {
+func TestStopOneRemovesWildcardHosts(t *testing.T) {
+	restore := setupStopTestHooks(t)
+	defer restore()
+
+	if err := seedDomains([]config.Domain{{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test", "b.myapp.test"}}}); err != nil {
+		t.Fatalf("seedDomains: %v", err)
+	}
+
+	var removed []string
+	systemRemoveHostFn = func(name string) error {
+		removed = append(removed, name)
+		return nil
+	}
+	daemonIsRunningFn = func() bool { return false }
+
+	if err := stopOne("*.myapp.test"); err != nil {
+		t.Fatalf("stopOne: %v", err)
+	}
+	if strings.Join(removed, ",") != "a.myapp.test,b.myapp.test" {
+		t.Fatalf("expected wildcard hosts to be removed, got %v", removed)
+	}
+}
+
+func TestStopOneKeepsDaemonWhenDomainsRemain(t *testing.T) {
 	restore := setupStopTestHooks(t)
 	defer restore()
 
@@ -75,17 +97,14 @@ func TestStopOneSendsReloadWhenDomainsRemain(t *testing.T) {
 	systemRemoveHostFn = func(string) error { return nil }
 	daemonIsRunningFn = func() bool { return true }
 
-	var gotType daemon.MessageType
-	daemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		gotType = req.Type
-		return &daemon.Response{OK: true}, nil
-	}
+	var types []daemon.MessageType
+	daemonSendIPCFn = fakeDaemonIPC(&types)
 
 	if err := stopOne("myapp.test"); err != nil {
 		t.Fatalf("stopOne: %v", err)
 	}
-	if gotType != daemon.MsgReload {
-		t.Fatalf("expected IPC type %q, got %q", daemon.MsgReload, gotType)
+	if !slices.Equal(types, []daemon.MessageType{daemon.MsgRemoveDomain}) {
+		t.Fatalf("expected only a remove_domain IPC, got %v", types)
 	}
 
 	cfg, err := config.Load()
@@ -132,20 +151,14 @@ func TestStopAllRemovesHostsAndSendsShutdown(t *testing.T) {
 	}
 
 	daemonIsRunningFn = func() bool { return true }
-	var sendCalls int
-	daemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		sendCalls++
-		if req.Type != daemon.MsgShutdown {
-			t.Fatalf("expected shutdown IPC type, got %q", req.Type)
-		}
-		return &daemon.Response{OK: true}, nil
-	}
+	var types []daemon.MessageType
+	daemonSendIPCFn = fakeDaemonIPC(&types)
 
 	if err := stopAll(); err != nil {
 		t.Fatalf("stopAll: %v", err)
 	}
-	if sendCalls != 1 {
-		t.Fatalf("expected one shutdown IPC call, got %d", sendCalls)
+	if !slices.Equal(types, []daemon.MessageType{daemon.MsgRemoveDomain, daemon.MsgShutdown}) {
+		t.Fatalf("expected remove_domain then shutdown IPC, got %v", types)
 	}
 	if len(removed) != 2 {
 		t.Fatalf("expected host removals for all domains, got %v", removed)
@@ -171,7 +184,10 @@ func TestStopAllDaemonShutdownError(t *testing.T) {
 	systemRemoveHostFn = func(string) error { return nil }
 	daemonIsRunningFn = func() bool { return true }
 	daemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		return nil, errors.New("ipc down")
+		if req.Type == daemon.MsgShutdown {
+			return nil, errors.New("ipc down")
+		}
+		return fakeDaemonIPC(new([]daemon.MessageType))(req)
 	}
 
 	err := stopAll()
@@ -191,21 +207,15 @@ func setupStopTestHooks(t *testing.T) func() {
 		t.Fatalf("config.Init: %v", err)
 	}
 
-	prevWithLock := configWithLockStopFn
-	prevLoad := configLoadStopFn
 	prevRemoveHost := systemRemoveHostFn
 	prevIsRunning := daemonIsRunningFn
 	prevSendIPC := daemonSendIPCFn
 
-	configWithLockStopFn = config.WithLock
-	configLoadStopFn = config.Load
 	systemRemoveHostFn = system.RemoveHost
 	daemonIsRunningFn = daemon.IsRunning
 	daemonSendIPCFn = daemon.SendIPC
 
 	return func() {
-		configWithLockStopFn = prevWithLock
-		configLoadStopFn = prevLoad
 		systemRemoveHostFn = prevRemoveHost
 		daemonIsRunningFn = prevIsRunning
 		daemonSendIPCFn = prevSendIPC
@@ -218,3 +228,27 @@ func seedDomains(domains []config.Domain) error {
 	}
 	return cfg.Save()
 }
+
+// fakeDaemonIPC answers like a daemon of the same build: it applies change
+// messages to the config file and records the type of every message except
+// the version handshake.
+func fakeDaemonIPC(types *[]daemon.MessageType) func(daemon.Request) (*daemon.Response, error) {
+	return func(req daemon.Request) (*daemon.Response, error) {
+		resp := &daemon.Response{OK: true, Protocol: daemon.ProtocolVersion, Version: daemon.Version}
+		if req.Type == daemon.MsgVersion {
+			return resp, nil
+		}
+		*types = append(*types, req.Type)
+		switch req.Type {
+		case daemon.MsgAddDomain, daemon.MsgRemoveDomain, daemon.MsgSetOptions:
+			diff, err := daemon.Apply(req)
+			if err != nil {
+				return &daemon.Response{OK: false, Error: err.Error()}, nil
+			}
+			if resp.Data, err = json.Marshal(diff); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
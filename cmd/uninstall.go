@@ -19,6 +19,13 @@ var uninstallCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if os.Geteuid() != 0 {
+			// systemctl --user has to run as the user who owns the service.
+			if svc, ok := installedService(); ok {
+				fmt.Printf("Removing %s...\n", svc.Name())
+				if err := svc.Uninstall(); err != nil {
+					return err
+				}
+			}
 			exe, err := os.Executable()
 			if err != nil {
 				return fmt.Errorf("failed to find slim binary: %w", err)
@@ -64,6 +71,19 @@ var uninstallCmd = &cobra.Command{
 					return "done", nil
 				},
 			},
+			{
+				Name: "Removing DNS resolver config",
+				Run: func() (string, error) {
+					r, err := system.NewResolver()
+					if err != nil || len(r.InstalledZones()) == 0 {
+						return "skipped (not configured)", nil
+					}
+					if err := r.Uninstall(); err != nil {
+						return fmt.Sprintf("skipped (%v)", err), nil
+					}
+					return "done", nil
+				},
+			},
 			{
 				Name: "Cleaning /etc/hosts",
 				Run: func() (string, error) {
@@ -2,7 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"strings"
+	"slices"
 
 	"github.com/kamranahmedse/slim/internal/cert"
 	"github.com/kamranahmedse/slim/internal/config"
@@ -16,20 +16,21 @@ import (
 var (
 	upDiscoverFn          = project.Discover
 	upEnsureFirstRunFn    = setup.EnsureFirstRun
-	upWithLockFn          = config.WithLock
 	upLoadFn              = config.Load
 	upAddHostFn           = system.AddHost
+	upRemoveHostFn        = system.RemoveHost
 	upEnsureLeafCertFn    = cert.EnsureLeafCert
 	upDaemonIsRunningFn   = daemon.IsRunning
 	upDaemonIsChildFn     = daemon.IsChild
 	upNewPortFwdFn        = system.NewPortForwarder
 	upEnsurePortsFn       = setup.EnsureProxyPortsAvailable
-	upDaemonRunDetachedFn = daemon.RunDetached
+	upDaemonRunDetachedFn = startDaemon
 	upDaemonWaitFn        = daemon.WaitForDaemon
 	upDaemonSendIPCFn     = daemon.SendIPC
 )
 
 var upConfigPath string
+var upNoPortForward bool
 
 var upCmd = &cobra.Command{
 	Use:   "up",
@@ -61,56 +62,68 @@ then start all services defined in it.
 
 		fmt.Printf("Using %s\n", path)
 
-		if err := upEnsureFirstRunFn(); err != nil {
+		current, err := upLoadFn()
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("no-port-forward") {
+			current.NoPortForward = upNoPortForward
+		}
+		if err := upEnsureFirstRunFn(current); err != nil {
 			return err
 		}
 
-		if err := upWithLockFn(func() error {
-			cfg, err := upLoadFn()
-			if err != nil {
+		opts := daemon.SetOptionsRequest{Cors: &pc.Cors, Headers: pc.Headers}
+		if cmd.Flags().Changed("no-port-forward") {
+			opts.NoPortForward = &upNoPortForward
+		}
+		if pc.LogMode != "" {
+			opts.LogMode = &pc.LogMode
+		}
+		domains := make([]config.Domain, len(pc.Services))
+		for i, svc := range pc.Services {
+			domains[i] = svc.ConfigDomain()
+		}
+		running := upDaemonIsRunningFn()
+		var passphrase string
+		if slices.ContainsFunc(domains, func(d config.Domain) bool { return cert.NeedsLeafCert(d.Name) }) {
+			if passphrase, err = unlockCA(running, upDaemonSendIPCFn); err != nil {
 				return err
 			}
-			cfg.Cors = pc.Cors
-			if pc.LogMode != "" {
-				cfg.LogMode = strings.ToLower(strings.TrimSpace(pc.LogMode))
-			}
-			for _, svc := range pc.Services {
-				if existing, idx := cfg.FindDomain(svc.Domain); existing != nil {
-					cfg.Domains[idx].Port = svc.Port
-					cfg.Domains[idx].Routes = svc.Routes
-				} else {
-					cfg.Domains = append(cfg.Domains, config.Domain{
-						Name:   svc.Domain,
-						Port:   svc.Port,
-						Routes: svc.Routes,
-					})
-				}
-			}
-			return cfg.Save()
-		}); err != nil {
+		}
+		diff, err := changeConfig(running, upDaemonSendIPCFn, daemon.MsgAddDomain, daemon.AddDomainRequest{Domains: domains, Options: opts})
+		if err != nil {
 			return err
 		}
+		cfg := diff.Config
 
-		for _, svc := range pc.Services {
-			if err := upAddHostFn(svc.Domain); err != nil {
-				return fmt.Errorf("updating /etc/hosts for %s: %w", svc.Domain, err)
+		for _, c := range diff.Updated {
+			for _, host := range c.StaleHosts {
+				if err := upRemoveHostFn(host); err != nil {
+					fmt.Printf("Warning: failed to remove %s from /etc/hosts: %v\n", host, err)
+				}
+			}
+		}
+		for _, d := range domains {
+			if err := registerHosts(cfg, d.HostNames(), upAddHostFn); err != nil {
+				return fmt.Errorf("%s: %w", d.Name, err)
 			}
-			if err := upEnsureLeafCertFn(svc.Domain); err != nil {
-				return fmt.Errorf("generating certificate for %s: %w", svc.Domain, err)
+			if err := upEnsureLeafCertFn(d.Name); err != nil {
+				return fmt.Errorf("generating certificate for %s: %w", d.Name, err)
 			}
 		}
 
 		if !upDaemonIsChildFn() {
 			pf := upNewPortFwdFn()
-			if shouldReloadPortForwarding(pf, upDaemonIsRunningFn()) {
+			if shouldReloadPortForwarding(cfg, pf, running) {
 				if err := pf.EnsureLoaded(); err != nil {
 					return fmt.Errorf("loading port forwarding rules: %w", err)
 				}
 			}
 		}
 
-		if !upDaemonIsRunningFn() {
-			if err := upEnsurePortsFn(); err != nil {
+		if !running {
+			if err := upEnsurePortsFn(cfg); err != nil {
 				return err
 			}
 			if err := upDaemonRunDetachedFn(); err != nil {
@@ -119,26 +132,23 @@ then start all services defined in it.
 			if err := upDaemonWaitFn(); err != nil {
 				return err
 			}
-		} else {
-			if _, err := upDaemonSendIPCFn(daemon.Request{Type: daemon.MsgReload}); err != nil {
-				return fmt.Errorf("reloading daemon: %w", err)
+			if passphrase != "" {
+				if err := sendUnlockCA(upDaemonSendIPCFn, passphrase); err != nil {
+					return err
+				}
 			}
 		}
 
 		if !upDaemonIsChildFn() {
 			pf := upNewPortFwdFn()
-			if shouldReloadPortForwarding(pf, true) {
+			if shouldReloadPortForwarding(cfg, pf, true) {
 				if err := pf.EnsureLoaded(); err != nil {
 					return fmt.Errorf("loading port forwarding rules: %w", err)
 				}
 			}
 		}
 
-		domains := make([]config.Domain, len(pc.Services))
-		for i, svc := range pc.Services {
-			domains[i] = config.Domain{Name: svc.Domain, Port: svc.Port, Routes: svc.Routes}
-		}
-		printServices(domains)
+		printServices(cfg, domains)
 
 		return nil
 	},
@@ -146,5 +156,6 @@ then start all services defined in it.
 
 func init() {
 	upCmd.Flags().StringVarP(&upConfigPath, "config", "c", "", "Path to .slim.yaml")
+	upCmd.Flags().BoolVar(&upNoPortForward, "no-port-forward", false, "Rootless mode: serve on the listener ports instead of forwarding 80/443")
 	rootCmd.AddCommand(upCmd)
 }
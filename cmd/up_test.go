@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"slices"
 	"strings"
 	"testing"
 
@@ -19,9 +20,9 @@ func setupUpTestHooks(t *testing.T) func() {
 
 	prevDiscover := upDiscoverFn
 	prevFirstRun := upEnsureFirstRunFn
-	prevWithLock := upWithLockFn
 	prevLoad := upLoadFn
 	prevAddHost := upAddHostFn
+	prevRemoveHost := upRemoveHostFn
 	prevLeafCert := upEnsureLeafCertFn
 	prevRunning := upDaemonIsRunningFn
 	prevIsChild := upDaemonIsChildFn
@@ -31,15 +32,14 @@ func setupUpTestHooks(t *testing.T) func() {
 	prevWait := upDaemonWaitFn
 	prevIPC := upDaemonSendIPCFn
 
-	upWithLockFn = config.WithLock
 	upLoadFn = config.Load
 
 	return func() {
 		upDiscoverFn = prevDiscover
 		upEnsureFirstRunFn = prevFirstRun
-		upWithLockFn = prevWithLock
 		upLoadFn = prevLoad
 		upAddHostFn = prevAddHost
+		upRemoveHostFn = prevRemoveHost
 		upEnsureLeafCertFn = prevLeafCert
 		upDaemonIsRunningFn = prevRunning
 		upDaemonIsChildFn = prevIsChild
@@ -65,12 +65,12 @@ func TestUpStartsDaemonForProjectServices(t *testing.T) {
 	upDiscoverFn = func() (*project.ProjectConfig, string, error) {
 		return pc, "/tmp/.slim.yaml", nil
 	}
-	upEnsureFirstRunFn = func() error { return nil }
+	upEnsureFirstRunFn = func(*config.Config) error { return nil }
 	upAddHostFn = func(string) error { return nil }
 	upEnsureLeafCertFn = func(string) error { return nil }
 	upDaemonIsRunningFn = func() bool { return false }
 	upDaemonIsChildFn = func() bool { return true }
-	upEnsurePortsFn = func() error { return nil }
+	upEnsurePortsFn = func(*config.Config) error { return nil }
 	upDaemonRunDetachedFn = func() error { return nil }
 	upDaemonWaitFn = func() error { return nil }
 
@@ -88,7 +88,7 @@ func TestUpStartsDaemonForProjectServices(t *testing.T) {
 	}
 }
 
-func TestUpReloadsDaemonWhenRunning(t *testing.T) {
+func TestUpSendsDomainsToRunningDaemon(t *testing.T) {
 	restore := setupUpTestHooks(t)
 	defer restore()
 
@@ -101,25 +101,29 @@ func TestUpReloadsDaemonWhenRunning(t *testing.T) {
 	upDiscoverFn = func() (*project.ProjectConfig, string, error) {
 		return pc, "/tmp/.slim.yaml", nil
 	}
-	upEnsureFirstRunFn = func() error { return nil }
+	upEnsureFirstRunFn = func(*config.Config) error { return nil }
 	upAddHostFn = func(string) error { return nil }
 	upEnsureLeafCertFn = func(string) error { return nil }
 	upDaemonIsChildFn = func() bool { return true }
 	upDaemonIsRunningFn = func() bool { return true }
 
-	var gotType daemon.MessageType
-	upDaemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
-		gotType = req.Type
-		return &daemon.Response{OK: true}, nil
-	}
+	var types []daemon.MessageType
+	upDaemonSendIPCFn = fakeDaemonIPC(&types)
 
 	err := upCmd.RunE(upCmd, nil)
 	if err != nil {
 		t.Fatalf("up: %v", err)
 	}
 
-	if gotType != daemon.MsgReload {
-		t.Fatalf("expected reload IPC, got %q", gotType)
+	if !slices.Equal(types, []daemon.MessageType{daemon.MsgAddDomain}) {
+		t.Fatalf("expected an add_domain IPC, got %v", types)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Domains) != 1 || cfg.Domains[0].Name != "myapp.test" {
+		t.Fatalf("expected the daemon to add myapp.test, got %+v", cfg.Domains)
 	}
 }
 
@@ -14,6 +14,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/kamranahmedse/slim/internal/daemon"
 	"github.com/kamranahmedse/slim/internal/term"
 	"github.com/spf13/cobra"
 )
@@ -22,7 +23,7 @@ var upgradeCmd = &cobra.Command{
 	Use:     "upgrade",
 	Aliases: []string{"update"},
 	Short:   "Upgrade slim to the latest version",
-	Args:  cobra.NoArgs,
+	Args:    cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		repo := "kamranahmedse/slim"
 
@@ -59,7 +60,7 @@ var upgradeCmd = &cobra.Command{
 		archivePath := filepath.Join(tmpDir, filename)
 		binaryPath := filepath.Join(tmpDir, "slim")
 
-		err = term.RunSteps([]term.Step{
+		steps := []term.Step{
 			{
 				Name: "Downloading archive",
 				Run: func() (string, error) {
@@ -85,8 +86,18 @@ var upgradeCmd = &cobra.Command{
 					return "done", replaceBinary(binaryPath, exe)
 				},
 			},
-		})
-		if err != nil {
+		}
+		// The running daemon still has the old code; bring it up on the
+		// new binary with the same domains.
+		if daemon.IsRunning() {
+			steps = append(steps, term.Step{
+				Name: "Restarting daemon",
+				Run: func() (string, error) {
+					return "done", restartDaemon(daemon.SendIPC)
+				},
+			})
+		}
+		if err := term.RunSteps(steps); err != nil {
 			return err
 		}
 
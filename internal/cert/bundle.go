@@ -0,0 +1,73 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// systemBundlePaths are where Linux distributions and macOS keep the
+// system roots as a single PEM file.
+var systemBundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// BundlePath is the system roots plus the slim CA in one file, for tools
+// that take a single CA file instead of reading the system store.
+func BundlePath() string {
+	return filepath.Join(CADir(), "bundle.pem")
+}
+
+// WriteBundle rebuilds the bundle from the current system roots and CA.
+func WriteBundle() (string, error) {
+	caPEM, err := os.ReadFile(CACertPath())
+	if err != nil {
+		return "", fmt.Errorf("reading CA cert: %w", err)
+	}
+	if block, _ := pem.Decode(caPEM); block == nil {
+		return "", fmt.Errorf("invalid CA cert PEM")
+	}
+
+	var system []byte
+	for _, path := range systemBundlePaths {
+		if system, err = os.ReadFile(path); err == nil {
+			break
+		}
+	}
+	if len(system) == 0 {
+		return "", errors.New("no system CA bundle found")
+	}
+
+	bundle := bytes.TrimRight(system, "\n")
+	// The system bundle already has the CA once it is trusted there.
+	if !bytes.Contains(system, bytes.TrimSpace(caPEM)) {
+		bundle = append(append(bundle, "\n\n# slim Root CA\n"...), caPEM...)
+	} else {
+		bundle = append(bundle, '\n')
+	}
+
+	path := BundlePath()
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, bundle) {
+		return path, nil
+	}
+	if err := os.WriteFile(path, bundle, 0644); err != nil {
+		return "", fmt.Errorf("writing CA bundle: %w", err)
+	}
+	return path, nil
+}
+
+// refreshBundle keeps an existing bundle in step with a new CA.
+func refreshBundle() error {
+	if _, err := os.Stat(BundlePath()); err != nil {
+		return nil
+	}
+	_, err := WriteBundle()
+	return err
+}
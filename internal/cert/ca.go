@@ -15,16 +15,21 @@ import (
 	"github.com/kamranahmedse/slim/internal/config"
 )
 
+const (
+	caCertFile = "rootCA.pem"
+	caKeyFile  = "rootCA-key.pem"
+)
+
 func CADir() string {
 	return filepath.Join(config.Dir(), "ca")
 }
 
 func CACertPath() string {
-	return filepath.Join(CADir(), "rootCA.pem")
+	return filepath.Join(CADir(), caCertFile)
 }
 
 func CAKeyPath() string {
-	return filepath.Join(CADir(), "rootCA-key.pem")
+	return filepath.Join(CADir(), caKeyFile)
 }
 
 func CAExists() bool {
@@ -34,7 +39,34 @@ func CAExists() bool {
 }
 
 func GenerateCA() error {
-	if err := os.MkdirAll(CADir(), 0700); err != nil {
+	cfg, err := loadCAConfig()
+	if err != nil {
+		return err
+	}
+	if err := generateCA(CADir(), cfg.PermittedCASuffixes()); err != nil {
+		return err
+	}
+	return refreshBundle()
+}
+
+func loadCAConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ValidateCASuffixes(cfg.CASuffixes); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// generateCA writes a new CA to dir, limited to suffixes and loopback IPs.
+func generateCA(dir string, suffixes []string) error {
+	passphrase, err := newCAKeyPassphrase()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("creating CA dir: %w", err)
 	}
 
@@ -53,6 +85,9 @@ func GenerateCA() error {
 		Subject: pkix.Name{
 			Organization: []string{"slim"},
 			CommonName:   "slim Root CA",
+			// A subject of its own keeps NSS from mixing this CA up with
+			// the one it replaces while both are trusted.
+			SerialNumber: serial.Text(16),
 		},
 		NotBefore:             time.Now().Add(-1 * time.Hour),
 		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
@@ -60,6 +95,11 @@ func GenerateCA() error {
 		BasicConstraintsValid: true,
 		IsCA:                  true,
 		MaxPathLen:            0,
+		// Name constraints keep a leaked key from minting certs for real
+		// sites.
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         suffixes,
+		PermittedIPRanges:           loopbackRanges,
 	}
 
 	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
@@ -67,56 +107,63 @@ func GenerateCA() error {
 		return fmt.Errorf("creating CA cert: %w", err)
 	}
 
-	certFile, err := os.OpenFile(CACertPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	keyPEM, err := encodeCAKey(key, passphrase)
 	if err != nil {
 		return err
 	}
-	defer certFile.Close()
-	if err := pem.Encode(certFile, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
-		return fmt.Errorf("writing CA cert: %w", err)
-	}
-
-	keyFile, err := os.OpenFile(CAKeyPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer keyFile.Close()
-	if err := pem.Encode(keyFile, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}); err != nil {
-		return fmt.Errorf("writing CA key: %w", err)
-	}
+	// The key goes in first: CAExists needs both files, so a CA cut off
+	// between the renames is regenerated rather than used.
+	return writeFilesAtomic(
+		pendingFile{filepath.Join(dir, caKeyFile), keyPEM, 0600},
+		pendingFile{filepath.Join(dir, caCertFile), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0644},
+	)
+}
 
-	return nil
+func LoadCACert() (*x509.Certificate, error) {
+	return loadCACert(CACertPath())
 }
 
-func LoadCA() (*x509.Certificate, *rsa.PrivateKey, error) {
-	certPEM, err := os.ReadFile(CACertPath())
+func loadCACert(path string) (*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(path)
 	if err != nil {
-		return nil, nil, fmt.Errorf("reading CA cert: %w", err)
+		return nil, fmt.Errorf("reading CA cert: %w", err)
 	}
 
 	block, _ := pem.Decode(certPEM)
 	if block == nil {
-		return nil, nil, fmt.Errorf("invalid CA cert PEM")
+		return nil, fmt.Errorf("invalid CA cert PEM")
 	}
 
 	caCert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing CA cert: %w", err)
+		return nil, fmt.Errorf("parsing CA cert: %w", err)
 	}
+	return caCert, nil
+}
 
-	keyPEM, err := os.ReadFile(CAKeyPath())
-	if err != nil {
-		return nil, nil, fmt.Errorf("reading CA key: %w", err)
-	}
+// loadSigningCACert returns the certificate of the CA LoadCA returns,
+// without needing its key.
+func loadSigningCACert() (*x509.Certificate, error) {
+	certPath, _ := signingCAPaths()
+	return loadCACert(certPath)
+}
 
-	keyBlock, _ := pem.Decode(keyPEM)
-	if keyBlock == nil {
-		return nil, nil, fmt.Errorf("invalid CA key PEM")
+// LoadCA returns the CA that issues leaf certificates. Partway through a
+// rotation that is the new CA, once it is trusted.
+func LoadCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	certPath, keyPath := signingCAPaths()
+	return loadCA(certPath, keyPath)
+}
+
+func loadCA(certPath, keyPath string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	caCert, err := loadCACert(certPath)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	caKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	caKey, err := readCAKey(keyPath)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing CA key: %w", err)
+		return nil, nil, err
 	}
 
 	return caCert, caKey, nil
@@ -0,0 +1,290 @@
+package cert
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A CA key encrypted with a passphrase is unlocked from one of these
+// without prompting: the passphrase itself, or a file holding it.
+const (
+	CAPassphraseEnv     = "SLIM_CA_PASSPHRASE"
+	CAPassphraseFileEnv = "SLIM_CA_PASSPHRASE_FILE"
+)
+
+const encryptedKeyType = "ENCRYPTED PRIVATE KEY"
+
+// caKeyIterations is well above what exports use: the CA key lives for
+// years and is only unlocked once per session.
+var caKeyIterations = 600000
+
+var (
+	ErrCALocked        = errors.New("the CA key is encrypted and locked; set " + CAPassphraseEnv + " or run 'slim cert unlock'")
+	errWrongPassphrase = errors.New("wrong passphrase")
+)
+
+// unlocked holds the passphrase UnlockCA accepted, which new CA keys are
+// encrypted with too, and the keys decrypted with it so far.
+var unlocked struct {
+	sync.Mutex
+	passphrase string
+	keys       map[string]*rsa.PrivateKey
+}
+
+// CAPassphrase returns the passphrase from the environment, or "" when none
+// is set. A passphrase file must not be readable by other users.
+func CAPassphrase() (string, error) {
+	return secretFromEnv(CAPassphraseEnv, CAPassphraseFileEnv, "CA passphrase")
+}
+
+// secretFromEnv reads a secret from the env variable, or from the file the
+// fileEnv variable names. Secrets never go on the command line, where any
+// local user can see them.
+func secretFromEnv(env, fileEnv, what string) (string, error) {
+	if p := os.Getenv(env); p != "" {
+		return p, nil
+	}
+	path := os.Getenv(fileEnv)
+	if path == "" {
+		return "", nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", what, err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s is readable by other users; run: chmod 600 %s", path, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", what, err)
+	}
+	p := strings.TrimRight(string(data), "\r\n")
+	if p == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return p, nil
+}
+
+// CAKeyEncrypted reports whether the CA key is stored encrypted.
+func CAKeyEncrypted() (bool, error) {
+	block, err := readKeyPEM(CAKeyPath())
+	if err != nil {
+		return false, err
+	}
+	return block.Type == encryptedKeyType, nil
+}
+
+// UnlockCA checks passphrase against the CA key and keeps the key unlocked
+// for the rest of the process. A key that isn't encrypted needs no unlocking.
+func UnlockCA(passphrase string) error {
+	block, err := readKeyPEM(CAKeyPath())
+	if err != nil {
+		return err
+	}
+	if block.Type != encryptedKeyType {
+		return nil
+	}
+	key, err := decryptKey(block.Bytes, passphrase)
+	if err != nil {
+		return err
+	}
+	unlocked.Lock()
+	defer unlocked.Unlock()
+	unlocked.passphrase = passphrase
+	unlocked.keys = map[string]*rsa.PrivateKey{string(block.Bytes): key}
+	return nil
+}
+
+// EncryptCAKey encrypts the CA key with passphrase, replacing the one it
+// had. An encrypted key has to be unlocked first.
+func EncryptCAKey(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("passphrase cannot be empty")
+	}
+	return rewriteCAKeys(passphrase)
+}
+
+// DecryptCAKey stores the CA key unencrypted again.
+func DecryptCAKey() error {
+	return rewriteCAKeys("")
+}
+
+// rewriteCAKeys rewrites the CA key, and the next CA's during a rotation,
+// encrypted with passphrase or in plaintext when it is "".
+func rewriteCAKeys(passphrase string) error {
+	for _, path := range []string{CAKeyPath(), nextCAKeyPath()} {
+		if path == nextCAKeyPath() && !fileExists(path) {
+			continue
+		}
+		key, err := readCAKey(path)
+		if err != nil {
+			return err
+		}
+		if err := writeCAKey(path, key, passphrase); err != nil {
+			return err
+		}
+	}
+	unlocked.Lock()
+	defer unlocked.Unlock()
+	unlocked.passphrase = passphrase
+	unlocked.keys = nil
+	return nil
+}
+
+// newCAKeyPassphrase returns what a new CA key is encrypted with: the
+// current key's passphrase when that key is encrypted, or "".
+func newCAKeyPassphrase() (string, error) {
+	block, err := readKeyPEM(CAKeyPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if block.Type != encryptedKeyType {
+		return "", nil
+	}
+	if _, err := decryptCAKey(block.Bytes); err != nil {
+		return "", err
+	}
+	unlocked.Lock()
+	defer unlocked.Unlock()
+	return unlocked.passphrase, nil
+}
+
+func readKeyPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid CA key PEM")
+	}
+	return block, nil
+}
+
+// readCAKey reads the CA key at path. An encrypted key is decrypted with
+// the passphrase UnlockCA accepted, or else one from the environment.
+func readCAKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readKeyPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == encryptedKeyType {
+		return decryptCAKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CA key: %w", err)
+	}
+	return key, nil
+}
+
+func decryptCAKey(der []byte) (*rsa.PrivateKey, error) {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+	if key, ok := unlocked.keys[string(der)]; ok {
+		return key, nil
+	}
+
+	passphrase := unlocked.passphrase
+	if passphrase == "" {
+		p, err := CAPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		if p == "" {
+			return nil, ErrCALocked
+		}
+		passphrase = p
+	}
+	key, err := decryptKey(der, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if unlocked.keys == nil {
+		unlocked.keys = make(map[string]*rsa.PrivateKey)
+	}
+	unlocked.passphrase = passphrase
+	unlocked.keys[string(der)] = key
+	return key, nil
+}
+
+func decryptKey(der []byte, passphrase string) (*rsa.PrivateKey, error) {
+	pkcs8, err := decryptPBES2(der, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting CA key: %w", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(pkcs8)
+	if err != nil {
+		// Padding that happens to look right still decrypts to garbage.
+		return nil, fmt.Errorf("decrypting CA key: %w", errWrongPassphrase)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("CA key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// writeCAKey stores key at path, as PKCS#1 or, with a passphrase, as
+// encrypted PKCS#8. The file is replaced in one step, so a failed write
+// never leaves a CA without its key.
+func writeCAKey(path string, key *rsa.PrivateKey, passphrase string) error {
+	data, err := encodeCAKey(key, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeFilesAtomic(pendingFile{path, data, 0600})
+}
+
+func encodeCAKey(key *rsa.PrivateKey, passphrase string) ([]byte, error) {
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if passphrase != "" {
+		pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding CA key: %w", err)
+		}
+		der, err := encryptPBES2(pkcs8, passphrase, caKeyIterations)
+		if err != nil {
+			return nil, fmt.Errorf("encrypting CA key: %w", err)
+		}
+		block = &pem.Block{Type: encryptedKeyType, Bytes: der}
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+type pendingFile struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+// writeFilesAtomic writes every file to a temporary one first and only
+// then renames them into place, so a failed write leaves none of them
+// half written.
+func writeFilesAtomic(files ...pendingFile) error {
+	for i, f := range files {
+		if err := os.WriteFile(f.path+".tmp", f.data, f.perm); err != nil {
+			for _, written := range files[:i+1] {
+				os.Remove(written.path + ".tmp")
+			}
+			return fmt.Errorf("writing %s: %w", f.path, err)
+		}
+	}
+	for _, f := range files {
+		if err := os.Rename(f.path+".tmp", f.path); err != nil {
+			return fmt.Errorf("writing %s: %w", f.path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package cert
+
+import (
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func initEncryptedCATest(t *testing.T) {
+	t.Helper()
+	initCertTestConfig(t)
+	t.Setenv(CAPassphraseEnv, "")
+	t.Setenv(CAPassphraseFileEnv, "")
+	prev := caKeyIterations
+	caKeyIterations = 1000
+	t.Cleanup(func() { caKeyIterations = prev })
+	lockCA()
+	t.Cleanup(lockCA)
+
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+}
+
+func lockCA() {
+	unlocked.Lock()
+	defer unlocked.Unlock()
+	unlocked.passphrase = ""
+	unlocked.keys = nil
+}
+
+func readCAKeyPEM(t *testing.T, path string) *pem.Block {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("no PEM in %s", path)
+	}
+	return block
+}
+
+func TestEncryptCAKey(t *testing.T) {
+	initEncryptedCATest(t)
+	_, plainKey, err := LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+
+	if err := EncryptCAKey("hunter2"); err != nil {
+		t.Fatalf("EncryptCAKey: %v", err)
+	}
+	block := readCAKeyPEM(t, CAKeyPath())
+	if block.Type != "ENCRYPTED PRIVATE KEY" {
+		t.Fatalf("expected an encrypted PKCS#8 key, got %s", block.Type)
+	}
+	if key := decryptShroudedKey(t, block.Bytes, "hunter2"); !key.(*rsa.PrivateKey).Equal(plainKey) {
+		t.Fatal("expected the encrypted key to be the CA key")
+	}
+	if info, err := os.Stat(CAKeyPath()); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %v (%v)", info.Mode(), err)
+	}
+	if encrypted, err := CAKeyEncrypted(); err != nil || !encrypted {
+		t.Fatalf("CAKeyEncrypted = %v, %v", encrypted, err)
+	}
+
+	lockCA()
+	if _, _, err := LoadCA(); !errors.Is(err, ErrCALocked) {
+		t.Fatalf("expected a locked CA, got %v", err)
+	}
+	if err := GenerateLeafCert("myapp.test"); !errors.Is(err, ErrCALocked) {
+		t.Fatalf("expected leaf issuance to need the key, got %v", err)
+	}
+	if err := UnlockCA("hunter3"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("expected a wrong passphrase error, got %v", err)
+	}
+	if err := UnlockCA("hunter2"); err != nil {
+		t.Fatalf("UnlockCA: %v", err)
+	}
+	caCert, caKey, err := LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+	if !caKey.Equal(plainKey) {
+		t.Fatal("expected the unlocked key to be the CA key")
+	}
+	if err := GenerateLeafCert("myapp.test"); err != nil {
+		t.Fatalf("GenerateLeafCert: %v", err)
+	}
+	if err := verifyLeaf(t, "myapp.test", "myapp.test", caCert); err != nil {
+		t.Fatalf("expected the leaf to verify: %v", err)
+	}
+}
+
+func TestDecryptCAKey(t *testing.T) {
+	initEncryptedCATest(t)
+	if err := EncryptCAKey("hunter2"); err != nil {
+		t.Fatalf("EncryptCAKey: %v", err)
+	}
+	lockCA()
+	if err := DecryptCAKey(); !errors.Is(err, ErrCALocked) {
+		t.Fatalf("expected decrypting a locked key to fail, got %v", err)
+	}
+
+	if err := UnlockCA("hunter2"); err != nil {
+		t.Fatalf("UnlockCA: %v", err)
+	}
+	if err := DecryptCAKey(); err != nil {
+		t.Fatalf("DecryptCAKey: %v", err)
+	}
+	if block := readCAKeyPEM(t, CAKeyPath()); block.Type != "RSA PRIVATE KEY" {
+		t.Fatalf("expected a PKCS#1 key, got %s", block.Type)
+	}
+	lockCA()
+	if _, _, err := LoadCA(); err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+}
+
+func TestCAPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, perm os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		env     string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{"unset", "", "", "", ""},
+		{"env", "hunter2", "", "hunter2", ""},
+		{"env wins", "hunter2", write("wins", "other\n", 0600), "hunter2", ""},
+		{"file", "", write("ok", "hunter2\n", 0600), "hunter2", ""},
+		{"file readable by others", "", write("loose", "hunter2\n", 0644), "", "readable by other users"},
+		{"empty file", "", write("empty", "\n", 0600), "", "is empty"},
+		{"missing file", "", filepath.Join(dir, "missing"), "", "reading CA passphrase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(CAPassphraseEnv, tt.env)
+			t.Setenv(CAPassphraseFileEnv, tt.file)
+			got, err := CAPassphrase()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("CAPassphrase = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCAUnlocksFromEnvironment(t *testing.T) {
+	initEncryptedCATest(t)
+	if err := EncryptCAKey("hunter2"); err != nil {
+		t.Fatalf("EncryptCAKey: %v", err)
+	}
+
+	lockCA()
+	t.Setenv(CAPassphraseEnv, "hunter2")
+	if _, _, err := LoadCA(); err != nil {
+		t.Fatalf("expected the key to unlock from %s: %v", CAPassphraseEnv, err)
+	}
+
+	lockCA()
+	path := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv(CAPassphraseEnv, "")
+	t.Setenv(CAPassphraseFileEnv, path)
+	if _, _, err := LoadCA(); err != nil {
+		t.Fatalf("expected the key to unlock from %s: %v", CAPassphraseFileEnv, err)
+	}
+}
+
+func TestEnsureLeafCertWithLockedCA(t *testing.T) {
+	initEncryptedCATest(t)
+	if err := EncryptCAKey("hunter2"); err != nil {
+		t.Fatalf("EncryptCAKey: %v", err)
+	}
+	lockCA()
+
+	if err := writeLeafCertPEM("old.test", "ecdsa", time.Now().Add(10*24*time.Hour)); err != nil {
+		t.Fatalf("writeLeafCertPEM: %v", err)
+	}
+	if err := os.WriteFile(LeafKeyPath("old.test"), []byte("key"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := EnsureLeafCert("old.test"); err != nil {
+		t.Fatalf("expected a working leaf to be kept while the CA is locked, got %v", err)
+	}
+	if err := writeLeafCertPEM("expired.test", "ecdsa", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("writeLeafCertPEM: %v", err)
+	}
+	if err := os.WriteFile(LeafKeyPath("expired.test"), []byte("key"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := EnsureLeafCert("expired.test"); !errors.Is(err, ErrCALocked) {
+		t.Fatalf("expected an expired leaf to need the key, got %v", err)
+	}
+	if err := EnsureLeafCert("new.test"); !errors.Is(err, ErrCALocked) {
+		t.Fatalf("expected a new leaf to need the key, got %v", err)
+	}
+}
+
+func TestRotationKeepsCAKeyEncrypted(t *testing.T) {
+	initEncryptedCATest(t)
+	stubRotationTrust(t)
+	if err := EncryptCAKey("hunter2"); err != nil {
+		t.Fatalf("EncryptCAKey: %v", err)
+	}
+
+	if err := GenerateNextCA(); err != nil {
+		t.Fatalf("GenerateNextCA: %v", err)
+	}
+	if block := readCAKeyPEM(t, nextCAKeyPath()); block.Type != "ENCRYPTED PRIVATE KEY" {
+		t.Fatalf("expected the new CA key to be encrypted, got %s", block.Type)
+	}
+	if err := InstallNextCA(); err != nil {
+		t.Fatalf("InstallNextCA: %v", err)
+	}
+
+	lockCA()
+	if err := UnlockCA("hunter2"); err != nil {
+		t.Fatalf("expected the new CA key to take the same passphrase: %v", err)
+	}
+	if _, _, err := LoadCA(); err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package cert
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+// loopbackRanges are the only IPs a slim CA may sign, covering the
+// 127.0.0.1 and ::1 SANs every leaf carries.
+var loopbackRanges = []*net.IPNet{
+	{IP: net.IPv4(127, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+}
+
+// CheckName returns an error when the CA's name constraints don't cover
+// name. A missing or unconstrained CA permits everything.
+func CheckName(name string) error {
+	if !CAExists() {
+		return nil
+	}
+	caPath, _ := signingCAPaths()
+	caCert, err := loadCACert(caPath)
+	if err != nil {
+		return err
+	}
+	return checkName(caCert, name)
+}
+
+func checkName(caCert *x509.Certificate, name string) error {
+	if permitsName(caCert, name) {
+		return nil
+	}
+	return fmt.Errorf("%s is outside the names the slim CA may sign (%s); add its suffix to ca_suffixes in %s and run: slim cert rotate-ca",
+		name, formatSuffixes(caCert.PermittedDNSDomains), config.Path())
+}
+
+func permitsName(caCert *x509.Certificate, name string) bool {
+	if len(caCert.PermittedDNSDomains) == 0 {
+		return true
+	}
+	return permitsSuffixes(caCert.PermittedDNSDomains, name)
+}
+
+func permitsSuffixes(suffixes []string, name string) bool {
+	name = strings.ToLower(strings.TrimPrefix(name, config.WildcardPrefix))
+	for _, c := range suffixes {
+		c = strings.ToLower(c)
+		if strings.HasPrefix(c, ".") {
+			if strings.HasSuffix(name, c) {
+				return true
+			}
+			continue
+		}
+		if name == c || strings.HasSuffix(name, "."+c) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplacementReason explains why caCert should be replaced by one
+// limited to suffixes, or returns "" when it already is.
+func ReplacementReason(caCert *x509.Certificate, suffixes []string) string {
+	if len(caCert.PermittedDNSDomains) == 0 {
+		return "can sign certificates for any domain"
+	}
+	var missing []string
+	for _, s := range suffixes {
+		if !permitsName(caCert, s) {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		return "can't sign " + formatSuffixes(missing)
+	}
+	return ""
+}
+
+func replacementDeclinedPath() string {
+	return filepath.Join(CADir(), "replace-declined")
+}
+
+// ReplacementDeclined reports whether replacing the CA was turned down for
+// the same reason and suffixes, so the user isn't asked again until either
+// changes.
+func ReplacementDeclined(reason string, suffixes []string) bool {
+	data, err := os.ReadFile(replacementDeclinedPath())
+	return err == nil && strings.TrimSpace(string(data)) == declinedKey(reason, suffixes)
+}
+
+// DeclineReplacement records that replacing the CA was turned down.
+func DeclineReplacement(reason string, suffixes []string) error {
+	if err := os.WriteFile(replacementDeclinedPath(), []byte(declinedKey(reason, suffixes)+"\n"), 0600); err != nil {
+		return fmt.Errorf("recording declined CA replacement: %w", err)
+	}
+	return nil
+}
+
+func declinedKey(reason string, suffixes []string) string {
+	return reason + " (" + formatSuffixes(suffixes) + ")"
+}
+
+func formatSuffixes(suffixes []string) string {
+	dotted := make([]string, len(suffixes))
+	for i, s := range suffixes {
+		dotted[i] = "." + strings.TrimPrefix(s, ".")
+	}
+	return strings.Join(slices.Compact(dotted), ", ")
+}
@@ -0,0 +1,119 @@
+package cert
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func TestGenerateCAIsNameConstrained(t *testing.T) {
+	initCertTestConfig(t)
+	if err := (&config.Config{CASuffixes: []string{"demo"}}).Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+
+	caCert, err := LoadCACert()
+	if err != nil {
+		t.Fatalf("LoadCACert: %v", err)
+	}
+	if !caCert.PermittedDNSDomainsCritical || strings.Join(caCert.PermittedDNSDomains, ",") != "test,loc,localhost,demo" {
+		t.Fatalf("unexpected DNS constraints %v (critical %v)", caCert.PermittedDNSDomains, caCert.PermittedDNSDomainsCritical)
+	}
+	if len(caCert.PermittedIPRanges) != 2 {
+		t.Fatalf("expected loopback IP constraints, got %v", caCert.PermittedIPRanges)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	for _, name := range []string{"myapp.test", "my.demo", "*.myapp.loc"} {
+		if err := GenerateLeafCert(name); err != nil {
+			t.Fatalf("GenerateLeafCert(%q): %v", name, err)
+		}
+		tlsCert, err := LoadLeafTLS(name)
+		if err != nil {
+			t.Fatalf("LoadLeafTLS: %v", err)
+		}
+		leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+		if err != nil {
+			t.Fatalf("ParseCertificate: %v", err)
+		}
+		host := strings.Replace(name, "*", "tenant", 1)
+		for _, dnsName := range []string{host, "127.0.0.1", "::1"} {
+			if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: dnsName}); err != nil {
+				t.Fatalf("expected %s cert to verify for %s: %v", name, dnsName, err)
+			}
+		}
+	}
+
+	err = GenerateLeafCert("example.com")
+	if err == nil || !strings.Contains(err.Error(), "example.com is outside the names the slim CA may sign (.test, .loc, .localhost, .demo)") {
+		t.Fatalf("expected a constraint error, got %v", err)
+	}
+	if LeafExists("example.com") {
+		t.Fatal("expected no cert to be written for a refused name")
+	}
+	if err := CheckName("example.com"); err == nil {
+		t.Fatal("expected CheckName to refuse example.com")
+	}
+	if err := CheckName("api.myapp.test"); err != nil {
+		t.Fatalf("CheckName: %v", err)
+	}
+}
+
+func TestCheckNameWithoutConstraints(t *testing.T) {
+	initCertTestConfig(t)
+	if err := CheckName("example.com"); err != nil {
+		t.Fatalf("expected no CA to permit everything, got %v", err)
+	}
+	if err := checkName(&x509.Certificate{}, "example.com"); err != nil {
+		t.Fatalf("expected an unconstrained CA to permit everything, got %v", err)
+	}
+}
+
+func TestPermitsName(t *testing.T) {
+	caCert := &x509.Certificate{PermittedDNSDomains: []string{"test", ".corp.example"}}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"test", true},
+		{"myapp.test", true},
+		{"*.myapp.test", true},
+		{"MyApp.Test", true},
+		{"mytest", false},
+		{"test.com", false},
+		{"corp.example", false},
+		{"app.corp.example", true},
+	}
+	for _, tt := range tests {
+		if got := permitsName(caCert, tt.name); got != tt.want {
+			t.Errorf("permitsName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplacementReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		permits  []string
+		suffixes []string
+		want     string
+	}{
+		{"unconstrained", nil, []string{"test"}, "can sign certificates for any domain"},
+		{"covered", []string{"test", "loc"}, []string{"test", "loc"}, ""},
+		{"missing suffix", []string{"test"}, []string{"test", "demo", "corp.example"}, "can't sign .demo, .corp.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReplacementReason(&x509.Certificate{PermittedDNSDomains: tt.permits}, tt.suffixes)
+			if got != tt.want {
+				t.Fatalf("ReplacementReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
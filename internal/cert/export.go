@@ -0,0 +1,130 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"slices"
+)
+
+// Export formats.
+const (
+	FormatPEM    = "pem"
+	FormatDER    = "der"
+	FormatPKCS12 = "pkcs12"
+	FormatJKS    = "jks"
+)
+
+var ExportFormats = []string{FormatPEM, FormatDER, FormatPKCS12, FormatJKS}
+
+const caAlias = "slim-ca"
+
+// The password for a pkcs12 or jks export, or a file holding it.
+const (
+	ExportPasswordEnv     = "SLIM_EXPORT_PASSWORD"
+	ExportPasswordFileEnv = "SLIM_EXPORT_PASSWORD_FILE"
+)
+
+// ExportPassword returns the export password from the environment, or ""
+// when none is set.
+func ExportPassword() (string, error) {
+	return secretFromEnv(ExportPasswordEnv, ExportPasswordFileEnv, "export password")
+}
+
+// ExportFileName is the default file an export is written to. An empty
+// name stands for the CA.
+func ExportFileName(name, format string) string {
+	base := caAlias
+	if name != "" {
+		base = leafFileName(name)
+	}
+	switch format {
+	case FormatDER:
+		return base + ".der"
+	case FormatPKCS12:
+		return base + ".p12"
+	case FormatJKS:
+		return base + ".jks"
+	default:
+		return base + ".pem"
+	}
+}
+
+// ExportCA encodes the root CA certificate, without its key. PKCS#12 and
+// JKS exports are truststores.
+func ExportCA(format, password string) ([]byte, error) {
+	if err := validateExport(format, password); err != nil {
+		return nil, err
+	}
+	caCert, err := loadSigningCACert()
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatDER:
+		return caCert.Raw, nil
+	case FormatPKCS12:
+		return encodePKCS12(nil, []*x509.Certificate{caCert}, caAlias, password)
+	case FormatJKS:
+		return encodeJKS(nil, []*x509.Certificate{caCert}, caAlias, password)
+	default:
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), nil
+	}
+}
+
+// ExportLeaf encodes the certificate for name. PEM, PKCS#12 and JKS
+// exports carry its key and the CA as the rest of the chain; DER holds
+// the certificate alone.
+func ExportLeaf(name, format, password string) ([]byte, error) {
+	if err := validateExport(format, password); err != nil {
+		return nil, err
+	}
+	if !LeafExists(name) {
+		return nil, fmt.Errorf("no certificate for %s yet", name)
+	}
+	pair, err := tls.LoadX509KeyPair(LeafCertPath(name), LeafKeyPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("loading cert for %s: %w", name, err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing cert for %s: %w", name, err)
+	}
+	caCert, err := loadSigningCACert()
+	if err != nil {
+		return nil, err
+	}
+	chain := []*x509.Certificate{leaf, caCert}
+
+	switch format {
+	case FormatDER:
+		return leaf.Raw, nil
+	case FormatPKCS12:
+		return encodePKCS12(pair.PrivateKey, chain, name, password)
+	case FormatJKS:
+		return encodeJKS(pair.PrivateKey, chain, name, password)
+	default:
+		keyDER, err := x509.MarshalPKCS8PrivateKey(pair.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("encoding key for %s: %w", name, err)
+		}
+		var buf bytes.Buffer
+		for _, c := range chain {
+			_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+		}
+		_ = pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+		return buf.Bytes(), nil
+	}
+}
+
+func validateExport(format, password string) error {
+	if !slices.Contains(ExportFormats, format) {
+		return fmt.Errorf("invalid format %q: must be one of pem|der|pkcs12|jks", format)
+	}
+	if password != "" && (format == FormatPEM || format == FormatDER) {
+		return fmt.Errorf("a password only applies to pkcs12 and jks exports")
+	}
+	return nil
+}
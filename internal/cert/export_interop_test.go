@@ -0,0 +1,119 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// These tests read exports back with the tools they are made for, and are
+// skipped where those aren't installed.
+
+func lookPathOrSkip(t *testing.T, tool string) string {
+	t.Helper()
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		t.Skipf("%s not installed", tool)
+	}
+	return path
+}
+
+func writeExport(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestExportPKCS12ReadsWithOpenSSL(t *testing.T) {
+	openssl := lookPathOrSkip(t, "openssl")
+	setupExportTest(t)
+	leafKey, err := LoadLeafTLS("*.myapp.test")
+	if err != nil {
+		t.Fatalf("LoadLeafTLS: %v", err)
+	}
+
+	for _, password := range []string{"", "s3cret"} {
+		data, err := ExportLeaf("*.myapp.test", FormatPKCS12, password)
+		if err != nil {
+			t.Fatalf("ExportLeaf: %v", err)
+		}
+		path := writeExport(t, "leaf.p12", data)
+		out, err := exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:"+password, "-nodes").CombinedOutput()
+		if err != nil {
+			t.Fatalf("openssl pkcs12 with password %q: %v\n%s", password, err, out)
+		}
+		if n := strings.Count(string(out), "BEGIN CERTIFICATE"); n != 2 {
+			t.Fatalf("expected the leaf and CA certificates, got %d:\n%s", n, out)
+		}
+
+		var key any
+		for rest := out; ; {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+			if block.Type == "PRIVATE KEY" {
+				if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+					t.Fatalf("ParsePKCS8PrivateKey: %v", err)
+				}
+			}
+		}
+		if k, ok := key.(*ecdsa.PrivateKey); !ok || !k.Equal(leafKey.PrivateKey) {
+			t.Fatalf("expected openssl to recover the leaf key, got %T", key)
+		}
+	}
+
+	data, err := ExportCA(FormatPKCS12, "changeit")
+	if err != nil {
+		t.Fatalf("ExportCA: %v", err)
+	}
+	out, err := exec.Command(openssl, "pkcs12", "-in", writeExport(t, "ca.p12", data), "-passin", "pass:changeit", "-nokeys").CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl pkcs12: %v\n%s", err, out)
+	}
+	if n := strings.Count(string(out), "BEGIN CERTIFICATE"); n != 1 {
+		t.Fatalf("expected the CA certificate, got %d:\n%s", n, out)
+	}
+}
+
+func TestExportJKSReadsWithKeytool(t *testing.T) {
+	keytool := lookPathOrSkip(t, "keytool")
+	setupExportTest(t)
+
+	tests := []struct {
+		name   string
+		export func() ([]byte, error)
+		want   []string
+	}{
+		{"leaf", func() ([]byte, error) { return ExportLeaf("*.myapp.test", FormatJKS, "changeit") },
+			[]string{"Alias name: *.myapp.test", "PrivateKeyEntry", "Certificate chain length: 2"}},
+		{"ca", func() ([]byte, error) { return ExportCA(FormatJKS, "changeit") },
+			[]string{"Alias name: " + caAlias, "trustedCertEntry"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.export()
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			path := writeExport(t, "store.jks", data)
+			out, err := exec.Command(keytool, "-list", "-v", "-storetype", "JKS", "-keystore", path, "-storepass", "changeit").CombinedOutput()
+			if err != nil {
+				t.Fatalf("keytool -list: %v\n%s", err, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Fatalf("expected keytool output to contain %q:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
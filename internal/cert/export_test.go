@@ -0,0 +1,326 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupExportTest(t *testing.T) {
+	t.Helper()
+	initCertTestConfig(t)
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	if err := GenerateLeafCert("*.myapp.test"); err != nil {
+		t.Fatalf("GenerateLeafCert: %v", err)
+	}
+}
+
+func TestExportFileName(t *testing.T) {
+	tests := []struct {
+		name, format, want string
+	}{
+		{"", FormatPEM, "slim-ca.pem"},
+		{"", FormatJKS, "slim-ca.jks"},
+		{"myapp.test", FormatDER, "myapp.test.der"},
+		{"*.myapp.test", FormatPKCS12, "_wildcard.myapp.test.p12"},
+	}
+	for _, tt := range tests {
+		if got := ExportFileName(tt.name, tt.format); got != tt.want {
+			t.Fatalf("ExportFileName(%q, %q) = %q, want %q", tt.name, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestExportPEMAndDER(t *testing.T) {
+	setupExportTest(t)
+	caCert, _, err := LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+
+	caPEM, err := ExportCA(FormatPEM, "")
+	if err != nil {
+		t.Fatalf("ExportCA: %v", err)
+	}
+	if block, rest := pem.Decode(caPEM); block == nil || !bytes.Equal(block.Bytes, caCert.Raw) || len(bytes.TrimSpace(rest)) != 0 {
+		t.Fatalf("expected the CA certificate alone, got %q", caPEM)
+	}
+	caDER, err := ExportCA(FormatDER, "")
+	if err != nil || !bytes.Equal(caDER, caCert.Raw) {
+		t.Fatalf("expected the CA in DER, got %v", err)
+	}
+
+	leafPEM, err := ExportLeaf("*.myapp.test", FormatPEM, "")
+	if err != nil {
+		t.Fatalf("ExportLeaf: %v", err)
+	}
+	var types []string
+	for rest := leafPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		types = append(types, block.Type)
+	}
+	if strings.Join(types, ",") != "CERTIFICATE,CERTIFICATE,PRIVATE KEY" {
+		t.Fatalf("expected leaf, CA and key blocks, got %v", types)
+	}
+	leafDER, err := ExportLeaf("*.myapp.test", FormatDER, "")
+	if err != nil {
+		t.Fatalf("ExportLeaf: %v", err)
+	}
+	if leaf, err := x509.ParseCertificate(leafDER); err != nil || leaf.Subject.CommonName != "*.myapp.test" {
+		t.Fatalf("expected the leaf in DER, got %v", err)
+	}
+}
+
+func TestExportRejectsInvalidOptions(t *testing.T) {
+	setupExportTest(t)
+	tests := []struct {
+		name   string
+		export func() ([]byte, error)
+		want   string
+	}{
+		{"unknown format", func() ([]byte, error) { return ExportCA("p7b", "") }, "invalid format"},
+		{"password on pem", func() ([]byte, error) { return ExportLeaf("*.myapp.test", FormatPEM, "x") }, "only applies to pkcs12 and jks"},
+		{"missing leaf", func() ([]byte, error) { return ExportLeaf("gone.test", FormatPEM, "") }, "no certificate for gone.test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.export(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExportPKCS12(t *testing.T) {
+	setupExportTest(t)
+	leafKey, err := LoadLeafTLS("*.myapp.test")
+	if err != nil {
+		t.Fatalf("LoadLeafTLS: %v", err)
+	}
+
+	for _, password := range []string{"", "s3cret"} {
+		data, err := ExportLeaf("*.myapp.test", FormatPKCS12, password)
+		if err != nil {
+			t.Fatalf("ExportLeaf: %v", err)
+		}
+		var pfx pfxPDU
+		if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+			t.Fatalf("Unmarshal PFX: %v", err)
+		}
+		var authSafe []byte
+		if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+			t.Fatalf("Unmarshal authSafe: %v", err)
+		}
+
+		mac := hmac.New(sha256.New, pkcs12KDF(password, pfx.MacData.MacSalt, pfx.MacData.Iterations, 3, sha256.Size))
+		mac.Write(authSafe)
+		if !hmac.Equal(mac.Sum(nil), pfx.MacData.Mac.Digest) {
+			t.Fatalf("MAC does not verify with password %q", password)
+		}
+
+		var safes []contentInfo
+		if _, err := asn1.Unmarshal(authSafe, &safes); err != nil || len(safes) != 2 {
+			t.Fatalf("expected a cert and a key safe, got %d (%v)", len(safes), err)
+		}
+		var keyBags []safeBag
+		var keySafe []byte
+		if _, err := asn1.Unmarshal(safes[1].Content.Bytes, &keySafe); err != nil {
+			t.Fatalf("Unmarshal key safe: %v", err)
+		}
+		if _, err := asn1.Unmarshal(keySafe, &keyBags); err != nil || len(keyBags) != 1 || !keyBags[0].ID.Equal(oidShroudedKeyBag) {
+			t.Fatalf("expected one shrouded key bag, got %+v (%v)", keyBags, err)
+		}
+		key := decryptShroudedKey(t, keyBags[0].Value.Bytes, password)
+		if !key.(*ecdsa.PrivateKey).Equal(leafKey.PrivateKey) {
+			t.Fatal("expected the exported key to be the leaf key")
+		}
+	}
+}
+
+func decryptShroudedKey(t *testing.T, der []byte, password string) any {
+	t.Helper()
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil || !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		t.Fatalf("expected PBES2, got %v", err)
+	}
+	var params pbes2Params
+	var kdf pbkdf2Params
+	var iv []byte
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		t.Fatalf("Unmarshal PBES2 params: %v", err)
+	}
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		t.Fatalf("Unmarshal PBKDF2 params: %v", err)
+	}
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		t.Fatalf("Unmarshal IV: %v", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, kdf.Salt, kdf.Iterations, 32)
+	if err != nil {
+		t.Fatalf("pbkdf2: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+	plain = plain[:len(plain)-int(plain[len(plain)-1])]
+	parsed, err := x509.ParsePKCS8PrivateKey(plain)
+	if err != nil {
+		t.Fatalf("ParsePKCS8PrivateKey: %v", err)
+	}
+	return parsed
+}
+
+func TestExportJKS(t *testing.T) {
+	setupExportTest(t)
+	leafKey, err := LoadLeafTLS("*.myapp.test")
+	if err != nil {
+		t.Fatalf("LoadLeafTLS: %v", err)
+	}
+
+	data, err := ExportLeaf("*.myapp.test", FormatJKS, "changeit")
+	if err != nil {
+		t.Fatalf("ExportLeaf: %v", err)
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	h := sha1.New()
+	h.Write(bmpString("changeit"))
+	h.Write([]byte(jksWhitener))
+	h.Write(body)
+	if !bytes.Equal(h.Sum(nil), digest) {
+		t.Fatal("store digest does not verify")
+	}
+
+	r := bytes.NewReader(body)
+	var header struct{ Magic, Version, Count, Tag uint32 }
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		t.Fatalf("read header: %v", err)
+	}
+	if header.Magic != jksMagic || header.Version != jksVersion || header.Count != 1 || header.Tag != jksPrivateKey {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	var aliasLen uint16
+	_ = binary.Read(r, binary.BigEndian, &aliasLen)
+	alias := make([]byte, aliasLen)
+	_, _ = r.Read(alias)
+	if string(alias) != "*.myapp.test" {
+		t.Fatalf("unexpected alias %q", alias)
+	}
+	var created int64
+	var keyLen uint32
+	_ = binary.Read(r, binary.BigEndian, &created)
+	_ = binary.Read(r, binary.BigEndian, &keyLen)
+	protected := make([]byte, keyLen)
+	_, _ = r.Read(protected)
+
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(protected, &info); err != nil || !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		t.Fatalf("expected the JKS key protector, got %v", err)
+	}
+	salt, encrypted := info.EncryptedData[:sha1.Size], info.EncryptedData[sha1.Size:len(info.EncryptedData)-sha1.Size]
+	plain := make([]byte, len(encrypted))
+	stream := salt
+	for off := 0; off < len(encrypted); off += sha1.Size {
+		sum := sha1.Sum(append(bmpString("changeit"), stream...))
+		stream = sum[:]
+		for i := 0; i < sha1.Size && off+i < len(encrypted); i++ {
+			plain[off+i] = encrypted[off+i] ^ stream[i]
+		}
+	}
+	key, err := x509.ParsePKCS8PrivateKey(plain)
+	if err != nil || !key.(*ecdsa.PrivateKey).Equal(leafKey.PrivateKey) {
+		t.Fatalf("expected the leaf key to be recovered, got %v", err)
+	}
+}
+
+func TestExportCATruststores(t *testing.T) {
+	setupExportTest(t)
+
+	p12, err := ExportCA(FormatPKCS12, "")
+	if err != nil {
+		t.Fatalf("ExportCA pkcs12: %v", err)
+	}
+	if !bytes.Contains(p12, mustMarshalOID(t, oidJavaTrustedKeyUsage)) {
+		t.Fatal("expected the CA to be marked as a trusted certificate for Java")
+	}
+
+	jks, err := ExportCA(FormatJKS, "")
+	if err != nil {
+		t.Fatalf("ExportCA jks: %v", err)
+	}
+	if tag := binary.BigEndian.Uint32(jks[12:]); tag != jksTrusted {
+		t.Fatalf("expected a trusted certificate entry, got tag %d", tag)
+	}
+}
+
+func mustMarshalOID(t *testing.T, oid asn1.ObjectIdentifier) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(oid)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return b
+}
+
+func TestWriteBundle(t *testing.T) {
+	setupExportTest(t)
+	caPEM, err := os.ReadFile(CACertPath())
+	if err != nil {
+		t.Fatalf("read CA: %v", err)
+	}
+
+	prev := systemBundlePaths
+	defer func() { systemBundlePaths = prev }()
+	system := filepath.Join(t.TempDir(), "ca-certificates.crt")
+	systemBundlePaths = []string{filepath.Join(t.TempDir(), "missing.crt"), system}
+
+	if _, err := WriteBundle(); err == nil || !strings.Contains(err.Error(), "no system CA bundle") {
+		t.Fatalf("expected a missing system bundle to fail, got %v", err)
+	}
+
+	roots := "-----BEGIN CERTIFICATE-----\nc3lzdGVt\n-----END CERTIFICATE-----\n"
+	if err := os.WriteFile(system, []byte(roots), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	path, err := WriteBundle()
+	if err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	bundle, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(bundle), roots) || !bytes.HasSuffix(bundle, caPEM) {
+		t.Fatalf("expected the system roots followed by the CA, got %q", bundle)
+	}
+
+	// Once the system store trusts the CA it isn't appended twice.
+	if err := os.WriteFile(system, append([]byte(roots), caPEM...), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := WriteBundle(); err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	bundle, _ = os.ReadFile(path)
+	if n := bytes.Count(bundle, bytes.TrimSpace(caPEM)); n != 1 {
+		t.Fatalf("expected the CA once, got %d times", n)
+	}
+}
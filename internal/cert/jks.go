@@ -0,0 +1,118 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// JKS is Java's original keystore format. Keys are protected with Sun's
+// SHA-1 based key protector, and the store ends with a SHA-1 digest keyed
+// by the password, as keytool expects.
+const (
+	jksMagic      = 0xFEEDFEED
+	jksVersion    = 2
+	jksPrivateKey = 1
+	jksTrusted    = 2
+	jksWhitener   = "Mighty Aphrodite"
+)
+
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// encodeJKS writes certs as a keystore. With a key, the certs are its
+// chain under alias; otherwise each cert is a trusted entry.
+func encodeJKS(key any, certs []*x509.Certificate, alias, password string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := func(v any) { _ = binary.Write(&buf, binary.BigEndian, v) }
+	now := time.Now().UnixMilli()
+
+	w(uint32(jksMagic))
+	w(uint32(jksVersion))
+	if key != nil {
+		w(uint32(1))
+		protected, err := protectJKSKey(key, password)
+		if err != nil {
+			return nil, err
+		}
+		w(uint32(jksPrivateKey))
+		writeJKSString(&buf, alias)
+		w(now)
+		w(uint32(len(protected)))
+		buf.Write(protected)
+		w(uint32(len(certs)))
+		for _, c := range certs {
+			writeJKSCert(&buf, c)
+		}
+	} else {
+		w(uint32(len(certs)))
+		for i, c := range certs {
+			w(uint32(jksTrusted))
+			name := alias
+			if i > 0 {
+				name = fmt.Sprintf("%s-%d", alias, i)
+			}
+			writeJKSString(&buf, name)
+			w(now)
+			writeJKSCert(&buf, c)
+		}
+	}
+
+	h := sha1.New()
+	h.Write(bmpString(password))
+	h.Write([]byte(jksWhitener))
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+	return buf.Bytes(), nil
+}
+
+func writeJKSString(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+func writeJKSCert(buf *bytes.Buffer, c *x509.Certificate) {
+	writeJKSString(buf, "X.509")
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(c.Raw)))
+	buf.Write(c.Raw)
+}
+
+// protectJKSKey encrypts a key with the JKS key protector: the PKCS#8 key
+// is XORed with a SHA-1 keystream seeded by a random salt, followed by a
+// SHA-1 check of the password and plaintext.
+func protectJKSKey(key any, password string) ([]byte, error) {
+	plaintext, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding key: %w", err)
+	}
+	pw := bmpString(password)
+
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(plaintext))
+	digest := salt
+	for off := 0; off < len(plaintext); off += sha1.Size {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for i := 0; i < sha1.Size && off+i < len(plaintext); i++ {
+			encrypted[off+i] = plaintext[off+i] ^ digest[i]
+		}
+	}
+	check := sha1.New()
+	check.Write(pw)
+	check.Write(plaintext)
+
+	protected := append(append(salt, encrypted...), check.Sum(nil)...)
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1Null},
+		EncryptedData: protected,
+	})
+}
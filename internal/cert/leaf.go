@@ -4,18 +4,22 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 )
 
 func CertsDir() string {
@@ -23,11 +27,20 @@ func CertsDir() string {
 }
 
 func LeafCertPath(name string) string {
-	return filepath.Join(CertsDir(), name+".pem")
+	return filepath.Join(CertsDir(), leafFileName(name)+".pem")
 }
 
 func LeafKeyPath(name string) string {
-	return filepath.Join(CertsDir(), name+"-key.pem")
+	return filepath.Join(CertsDir(), leafFileName(name)+"-key.pem")
+}
+
+// leafFileName keeps "*" out of file names, so *.myapp.test is stored as
+// _wildcard.myapp.test.
+func leafFileName(name string) string {
+	if base, ok := strings.CutPrefix(name, config.WildcardPrefix); ok {
+		return "_wildcard." + base
+	}
+	return name
 }
 
 func LeafExists(name string) bool {
@@ -41,6 +54,13 @@ func GenerateLeafCert(name string) error {
 	if err != nil {
 		return fmt.Errorf("loading CA: %w", err)
 	}
+	return issueLeaf(name, caCert, caKey)
+}
+
+func issueLeaf(name string, caCert *x509.Certificate, caKey *rsa.PrivateKey) error {
+	if err := checkName(caCert, name); err != nil {
+		return err
+	}
 
 	if err := os.MkdirAll(CertsDir(), 0700); err != nil {
 		return fmt.Errorf("creating certs dir: %w", err)
@@ -96,6 +116,7 @@ func GenerateLeafCert(name string) error {
 		return fmt.Errorf("writing leaf key: %w", err)
 	}
 
+	event.Publish(event.CertIssued, event.Cert{Domain: name, NotAfter: template.NotAfter})
 	return nil
 }
 
@@ -107,11 +128,23 @@ func LoadLeafTLS(name string) (*tls.Certificate, error) {
 	return &cert, nil
 }
 
+// NeedsLeafCert reports whether EnsureLeafCert would issue a certificate
+// for name.
+func NeedsLeafCert(name string) bool {
+	return !LeafExists(name) || leafNeedsRenewal(name)
+}
+
 func EnsureLeafCert(name string) error {
-	if LeafExists(name) && !leafNeedsRenewal(name) {
+	if !NeedsLeafCert(name) {
+		return nil
+	}
+	err := GenerateLeafCert(name)
+	// A locked CA key can't renew a cert that still works, so it is
+	// served until the key is unlocked.
+	if errors.Is(err, ErrCALocked) && leafUnexpired(name) {
 		return nil
 	}
-	return GenerateLeafCert(name)
+	return err
 }
 
 func leafNeedsRenewal(name string) bool {
@@ -134,5 +167,56 @@ func leafNeedsRenewal(name string) bool {
 		return true
 	}
 
+	// Leaves from a CA that has since been replaced are reissued.
+	caPath, _ := signingCAPaths()
+	if caCert, err := loadCACert(caPath); err == nil && cert.CheckSignatureFrom(caCert) != nil {
+		return true
+	}
+
 	return time.Until(cert.NotAfter) < 30*24*time.Hour
 }
+
+// leafNames lists the names that have a certificate under CertsDir.
+func leafNames() ([]string, error) {
+	entries, err := os.ReadDir(CertsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading certs dir: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		base, ok := strings.CutSuffix(e.Name(), ".pem")
+		if !ok || e.IsDir() || strings.HasSuffix(base, "-key") {
+			continue
+		}
+		if wildcard, ok := strings.CutPrefix(base, "_wildcard."); ok {
+			base = config.WildcardPrefix + wildcard
+		}
+		names = append(names, base)
+	}
+	return names, nil
+}
+
+func leafSignedBy(name string, caCert *x509.Certificate) bool {
+	leaf, err := loadLeafCert(name)
+	return err == nil && leaf.CheckSignatureFrom(caCert) == nil
+}
+
+func leafUnexpired(name string) bool {
+	leaf, err := loadLeafCert(name)
+	return err == nil && LeafExists(name) && time.Now().Before(leaf.NotAfter)
+}
+
+func loadLeafCert(name string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(LeafCertPath(name))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM in %s", LeafCertPath(name))
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
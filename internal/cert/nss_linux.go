@@ -0,0 +1,144 @@
+//go:build linux
+
+package cert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// NSSNickname names the slim CA inside NSS databases.
+const NSSNickname = "slim Root CA"
+
+// NSSStore is an NSS certificate database that a browser reads its trust
+// settings from, instead of the system store.
+type NSSStore struct {
+	Name string
+	Dir  string
+}
+
+// nssProfileGlobs are Firefox profile directories, including the Snap and
+// Flatpak packages, relative to the home directory.
+var nssProfileGlobs = []struct {
+	name string
+	glob string
+}{
+	{"Firefox", ".mozilla/firefox/*"},
+	{"Firefox (Snap)", "snap/firefox/common/.mozilla/firefox/*"},
+	{"Firefox (Flatpak)", ".var/app/org.mozilla.firefox/.mozilla/firefox/*"},
+}
+
+// nssSharedDirs are the shared databases Chrome and Chromium read.
+var nssSharedDirs = []struct {
+	name string
+	dir  string
+}{
+	{"Chrome/Chromium", ".pki/nssdb"},
+	{"Chromium (Snap)", "snap/chromium/current/.pki/nssdb"},
+	{"Chromium (Flatpak)", ".var/app/org.chromium.Chromium/.pki/nssdb"},
+}
+
+var ErrNoCertutil = errors.New("certutil is not installed (install libnss3-tools or nss-tools)")
+
+var (
+	userHomeDirFn = os.UserHomeDir
+	runCertutilFn = runCertutil
+	nssStoresFn   = NSSStores
+)
+
+// NSSStores lists the NSS databases in the user's home directory. Only
+// databases in the current SQL format (cert9.db) are included.
+func NSSStores() []NSSStore {
+	home, err := userHomeDirFn()
+	if err != nil {
+		return nil
+	}
+
+	var stores []NSSStore
+	for _, s := range nssSharedDirs {
+		dir := filepath.Join(home, s.dir)
+		if fileExists(filepath.Join(dir, "cert9.db")) {
+			stores = append(stores, NSSStore{Name: s.name, Dir: dir})
+		}
+	}
+	for _, p := range nssProfileGlobs {
+		dirs, _ := filepath.Glob(filepath.Join(home, p.glob))
+		for _, dir := range dirs {
+			if fileExists(filepath.Join(dir, "cert9.db")) {
+				stores = append(stores, NSSStore{Name: fmt.Sprintf("%s %s", p.name, profileName(dir)), Dir: dir})
+			}
+		}
+	}
+	return stores
+}
+
+// profileName strips the random prefix Firefox gives profile directories,
+// so "abcd1234.default-release" reads as "default-release".
+func profileName(dir string) string {
+	base := filepath.Base(dir)
+	if _, name, ok := strings.Cut(base, "."); ok && name != "" {
+		return name
+	}
+	return base
+}
+
+// NSSTrusted reports whether the slim CA is in store.
+func NSSTrusted(store NSSStore) (bool, error) {
+	if !commandExistsFn("certutil") {
+		return false, ErrNoCertutil
+	}
+	return nssHas(store, NSSNickname), nil
+}
+
+func nssHas(store NSSStore, nickname string) bool {
+	_, err := runCertutilFn("-L", "-d", "sql:"+store.Dir, "-n", nickname)
+	return err == nil
+}
+
+// nssNickname names the CA trusted in slot, such as "slim Root CA (next)".
+func nssNickname(slot string) string {
+	if slot == "" {
+		return NSSNickname
+	}
+	return fmt.Sprintf("%s (%s)", NSSNickname, slot)
+}
+
+// trustNSS adds the CA to every NSS database found. Without certutil there
+// is nothing to do; doctor points that out when databases exist.
+func trustNSS(certPath, slot string) error {
+	if !commandExistsFn("certutil") {
+		return nil
+	}
+	var errs []error
+	for _, store := range nssStoresFn() {
+		if output, err := runCertutilFn("-A", "-d", "sql:"+store.Dir, "-t", "C,,", "-n", nssNickname(slot), "-i", certPath); err != nil {
+			errs = append(errs, fmt.Errorf("adding CA to %s: %s: %w", store.Name, strings.TrimSpace(string(output)), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// untrustNSS removes the CA from every NSS database that has it.
+func untrustNSS(slot string) error {
+	if !commandExistsFn("certutil") {
+		return nil
+	}
+	var errs []error
+	for _, store := range nssStoresFn() {
+		if !nssHas(store, nssNickname(slot)) {
+			continue
+		}
+		if output, err := runCertutilFn("-D", "-d", "sql:"+store.Dir, "-n", nssNickname(slot)); err != nil {
+			errs = append(errs, fmt.Errorf("removing CA from %s: %s: %w", store.Name, strings.TrimSpace(string(output)), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func runCertutil(args ...string) ([]byte, error) {
+	return exec.Command("certutil", args...).CombinedOutput()
+}
@@ -0,0 +1,192 @@
+//go:build linux
+
+package cert
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestNSSStoresFindsBrowserDatabases(t *testing.T) {
+	restore := snapshotTrustLinuxTestHooks()
+	defer restore()
+
+	home := t.TempDir()
+	userHomeDirFn = func() (string, error) { return home, nil }
+	for _, dir := range []string{
+		".pki/nssdb",
+		".mozilla/firefox/ab12cd34.default-release",
+		"snap/firefox/common/.mozilla/firefox/ef56.default",
+	} {
+		if err := os.MkdirAll(filepath.Join(home, dir), 0755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(home, dir, "cert9.db"), nil, 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	legacy := filepath.Join(home, ".mozilla/firefox/old.legacy")
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "cert8.db"), nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	want := []NSSStore{
+		{Name: "Chrome/Chromium", Dir: filepath.Join(home, ".pki/nssdb")},
+		{Name: "Firefox default-release", Dir: filepath.Join(home, ".mozilla/firefox/ab12cd34.default-release")},
+		{Name: "Firefox (Snap) default", Dir: filepath.Join(home, "snap/firefox/common/.mozilla/firefox/ef56.default")},
+	}
+	if got := NSSStores(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("NSSStores() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTrustCAAddsCAToNSSStores(t *testing.T) {
+	restore := snapshotTrustLinuxTestHooks()
+	defer restore()
+
+	readCertFileFn = func(string) ([]byte, error) { return []byte("pem"), nil }
+	commandExistsFn = func(name string) bool { return name == "update-ca-certificates" || name == "certutil" }
+	writeAnchorFileFn = func(string, []byte) error { return nil }
+	runPrivilegedTrustFn = func(string, ...string) ([]byte, error) { return nil, nil }
+	nssStoresFn = func() []NSSStore {
+		return []NSSStore{{Name: "Chrome/Chromium", Dir: "/home/u/.pki/nssdb"}, {Name: "Firefox default", Dir: "/home/u/ff"}}
+	}
+
+	var commands [][]string
+	runCertutilFn = func(args ...string) ([]byte, error) {
+		commands = append(commands, args)
+		return nil, nil
+	}
+
+	if err := TrustCA(); err != nil {
+		t.Fatalf("TrustCA: %v", err)
+	}
+	want := [][]string{
+		{"-A", "-d", "sql:/home/u/.pki/nssdb", "-t", "C,,", "-n", NSSNickname, "-i", CACertPath()},
+		{"-A", "-d", "sql:/home/u/ff", "-t", "C,,", "-n", NSSNickname, "-i", CACertPath()},
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("unexpected certutil commands: got %v want %v", commands, want)
+	}
+}
+
+func TestTrustCASkipsNSSWithoutCertutil(t *testing.T) {
+	restore := snapshotTrustLinuxTestHooks()
+	defer restore()
+
+	readCertFileFn = func(string) ([]byte, error) { return []byte("pem"), nil }
+	commandExistsFn = func(name string) bool { return name == "update-ca-certificates" }
+	writeAnchorFileFn = func(string, []byte) error { return nil }
+	runPrivilegedTrustFn = func(string, ...string) ([]byte, error) { return nil, nil }
+	runCertutilFn = func(args ...string) ([]byte, error) {
+		t.Fatalf("unexpected certutil call %v", args)
+		return nil, nil
+	}
+
+	if err := TrustCA(); err != nil {
+		t.Fatalf("TrustCA: %v", err)
+	}
+	if _, err := NSSTrusted(NSSStore{Dir: "/home/u/ff"}); !errors.Is(err, ErrNoCertutil) {
+		t.Fatalf("expected ErrNoCertutil, got %v", err)
+	}
+}
+
+func TestUntrustCARemovesCAFromNSSStoresThatHaveIt(t *testing.T) {
+	restore := snapshotTrustLinuxTestHooks()
+	defer restore()
+
+	commandExistsFn = func(name string) bool { return name == "update-ca-certificates" || name == "certutil" }
+	removeAnchorFileFn = func(string) error { return nil }
+	runPrivilegedTrustFn = func(string, ...string) ([]byte, error) { return nil, nil }
+	nssStoresFn = func() []NSSStore {
+		return []NSSStore{{Name: "trusting", Dir: "/a"}, {Name: "clean", Dir: "/b"}}
+	}
+
+	var deleted []string
+	runCertutilFn = func(args ...string) ([]byte, error) {
+		switch {
+		case args[0] == "-L" && args[2] == "sql:/b":
+			return []byte("could not find cert"), errors.New("exit status 255")
+		case args[0] == "-D":
+			deleted = append(deleted, args[2])
+		}
+		return nil, nil
+	}
+
+	if err := UntrustCA(); err != nil {
+		t.Fatalf("UntrustCA: %v", err)
+	}
+	if want := []string{"sql:/a"}; !reflect.DeepEqual(deleted, want) {
+		t.Fatalf("deleted from %v, want %v", deleted, want)
+	}
+}
+
+func TestTrustCAReportsNSSFailures(t *testing.T) {
+	restore := snapshotTrustLinuxTestHooks()
+	defer restore()
+
+	readCertFileFn = func(string) ([]byte, error) { return []byte("pem"), nil }
+	commandExistsFn = func(name string) bool { return name == "update-ca-certificates" || name == "certutil" }
+	writeAnchorFileFn = func(string, []byte) error { return nil }
+	runPrivilegedTrustFn = func(string, ...string) ([]byte, error) { return nil, nil }
+	nssStoresFn = func() []NSSStore { return []NSSStore{{Name: "Firefox default", Dir: "/ff"}} }
+	runCertutilFn = func(...string) ([]byte, error) { return []byte("SEC_ERROR_READ_ONLY"), errors.New("exit status 255") }
+
+	err := TrustCA()
+	if err == nil || err.Error() != "adding CA to Firefox default: SEC_ERROR_READ_ONLY: exit status 255" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRotationTrustsNextCAInItsOwnSlot(t *testing.T) {
+	restore := snapshotTrustLinuxTestHooks()
+	defer restore()
+
+	readCertFileFn = func(string) ([]byte, error) { return []byte("pem"), nil }
+	commandExistsFn = func(name string) bool { return name == "update-ca-certificates" || name == "certutil" }
+	nssStoresFn = func() []NSSStore { return []NSSStore{{Name: "Firefox default", Dir: "/ff"}} }
+
+	var calls [][]string
+	writeAnchorFileFn = func(path string, _ []byte) error {
+		calls = append(calls, []string{"write", path})
+		return nil
+	}
+	removeAnchorFileFn = func(path string) error {
+		if path == "/usr/local/share/ca-certificates/slim-next.crt" {
+			calls = append(calls, []string{"remove", path})
+		}
+		return nil
+	}
+	runPrivilegedTrustFn = func(string, ...string) ([]byte, error) { return nil, nil }
+	runCertutilFn = func(args ...string) ([]byte, error) {
+		if args[0] != "-L" {
+			calls = append(calls, []string{"certutil", args[0], args[slices.Index(args, "-n")+1]})
+		}
+		return nil, nil
+	}
+
+	if err := trustNextCA(); err != nil {
+		t.Fatalf("trustNextCA: %v", err)
+	}
+	if err := promoteNextCA(); err != nil {
+		t.Fatalf("promoteNextCA: %v", err)
+	}
+	want := [][]string{
+		{"write", "/usr/local/share/ca-certificates/slim-next.crt"},
+		{"certutil", "-A", "slim Root CA (next)"},
+		{"write", debianAnchorPath},
+		{"remove", "/usr/local/share/ca-certificates/slim-next.crt"},
+		{"certutil", "-D", "slim Root CA (next)"},
+		{"certutil", "-A", NSSNickname},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("unexpected calls:\n got %v\nwant %v", calls, want)
+	}
+}
@@ -131,6 +131,42 @@ func TestEnsureLeafCertAndLoadLeafTLS(t *testing.T) {
 	}
 }
 
+func TestGenerateWildcardLeafCert(t *testing.T) {
+	home := initCertTestConfig(t)
+
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	if err := EnsureLeafCert("*.myapp.test"); err != nil {
+		t.Fatalf("EnsureLeafCert: %v", err)
+	}
+	if got, want := LeafCertPath("*.myapp.test"), filepath.Join(home, ".slim", "certs", "_wildcard.myapp.test.pem"); got != want {
+		t.Fatalf("LeafCertPath() = %q, want %q", got, want)
+	}
+
+	tlsCert, err := LoadLeafTLS("*.myapp.test")
+	if err != nil {
+		t.Fatalf("LoadLeafTLS: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	caCert, _, err := LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "tenant1.myapp.test"}); err != nil {
+		t.Fatalf("expected wildcard cert to cover tenant1.myapp.test: %v", err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "a.b.myapp.test"}); err == nil {
+		t.Fatal("expected wildcard cert to cover a single label only")
+	}
+}
+
 func TestLeafNeedsRenewal(t *testing.T) {
 	initCertTestConfig(t)
 	name := "renewal"
@@ -0,0 +1,386 @@
+package cert
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 bundles are written the way current OpenSSL and Java write them:
+// keys are encrypted with PBES2 (PBKDF2-SHA256, AES-256-CBC) and the file
+// is authenticated with an HMAC-SHA256 MAC.
+const pkcs12Iterations = 10000
+
+var (
+	oidData                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidX509Certificate     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidJavaTrustedKeyUsage = asn1.ObjectIdentifier{2, 16, 840, 1, 113894, 746875, 1, 1}
+	oidAnyExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37, 0}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm algorithmIdentifier
+	Digest    []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional,omitempty"`
+}
+
+type pkcs12Attribute struct {
+	ID     asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        algorithmIdentifier
+}
+
+var asn1Null = asn1.RawValue{Tag: asn1.TagNull}
+
+// encodePKCS12 writes certs, and key when it isn't nil, as a PKCS#12
+// bundle. Without a key the certificates are marked as trusted, which is
+// what Java looks for in a truststore.
+func encodePKCS12(key any, certs []*x509.Certificate, alias, password string) ([]byte, error) {
+	localKeyID := []byte{1}
+	var certBags []safeBag
+	for i, c := range certs {
+		var attrs []pkcs12Attribute
+		if key == nil {
+			a, err := pkcs12Attr(oidJavaTrustedKeyUsage, oidAnyExtendedKeyUsage)
+			if err != nil {
+				return nil, err
+			}
+			attrs = append(attrs, a)
+		}
+		if i == 0 {
+			name, err := pkcs12Attr(oidFriendlyName, asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(alias)})
+			if err != nil {
+				return nil, err
+			}
+			attrs = append(attrs, name)
+			if key != nil {
+				id, err := pkcs12Attr(oidLocalKeyID, localKeyID)
+				if err != nil {
+					return nil, err
+				}
+				attrs = append(attrs, id)
+			}
+		}
+		bag, err := asn1.Marshal(certBag{ID: oidX509Certificate, Data: c.Raw})
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, safeBag{ID: oidCertBag, Value: explicit0(bag), Attributes: attrs})
+	}
+
+	safes := []contentInfo{}
+	certSafe, err := dataContentInfo(certBags)
+	if err != nil {
+		return nil, err
+	}
+	safes = append(safes, certSafe)
+
+	if key != nil {
+		pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("encoding key: %w", err)
+		}
+		shrouded, err := encryptPBES2(pkcs8, password, pkcs12Iterations)
+		if err != nil {
+			return nil, err
+		}
+		name, err := pkcs12Attr(oidFriendlyName, asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(alias)})
+		if err != nil {
+			return nil, err
+		}
+		id, err := pkcs12Attr(oidLocalKeyID, localKeyID)
+		if err != nil {
+			return nil, err
+		}
+		keySafe, err := dataContentInfo([]safeBag{{ID: oidShroudedKeyBag, Value: explicit0(shrouded), Attributes: []pkcs12Attribute{name, id}}})
+		if err != nil {
+			return nil, err
+		}
+		safes = append(safes, keySafe)
+	}
+
+	authSafe, err := asn1.Marshal(safes)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, pkcs12KDF(password, salt, pkcs12Iterations, 3, sha256.Size))
+	mac.Write(authSafe)
+
+	content, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfxPDU{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidData, Content: explicit0(content)},
+		MacData: macData{
+			Mac:        digestInfo{Algorithm: algorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1Null}, Digest: mac.Sum(nil)},
+			MacSalt:    salt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+func pkcs12Attr(id asn1.ObjectIdentifier, value any) (pkcs12Attribute, error) {
+	v, err := asn1.Marshal(value)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{ID: id, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: v}}, nil
+}
+
+// explicit0 wraps DER in the [0] EXPLICIT tag that carries PKCS#12 values.
+// encoding/asn1 writes a RawValue as is, ignoring field tags.
+func explicit0(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func dataContentInfo(bags []safeBag) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	content, err := asn1.Marshal(safeContents)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidData, Content: explicit0(content)}, nil
+}
+
+// encryptPBES2 encrypts a PKCS#8 key into an EncryptedPrivateKeyInfo.
+func encryptPBES2(plaintext []byte, password string, iterations int) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte(nil), plaintext...), make([]byte, padding)...)
+	for i := len(plaintext); i < len(padded); i++ {
+		padded[i] = byte(padding)
+	}
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: iterations,
+		PRF:        algorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1Null},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  algorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+}
+
+// decryptPBES2 reverses encryptPBES2. It only reads the algorithms
+// encryptPBES2 writes.
+func decryptPBES2(der []byte, password string) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("invalid encrypted key")
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption %s", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBES2 parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) || !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, fmt.Errorf("unsupported PBES2 algorithms")
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("invalid PBKDF2 parameters: %w", err)
+	}
+	if !kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+		return nil, fmt.Errorf("unsupported PBKDF2 hash %s", kdfParams.PRF.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid AES-CBC parameters")
+	}
+	encrypted := info.EncryptedData
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted key length")
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, kdfParams.Salt, kdfParams.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, encrypted)
+
+	// A wrong password almost always shows up as broken padding.
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errWrongPassphrase
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, errWrongPassphrase
+		}
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// pkcs12KDF derives key material from a password with SHA-256, as
+// described in RFC 7292 appendix B.2. id 3 derives MAC keys.
+func pkcs12KDF(password string, salt []byte, iterations int, id byte, size int) []byte {
+	const v = 64
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	i := append(fill(salt, v), fill(bmpPassword(password), v)...)
+
+	var out []byte
+	one := big.NewInt(1)
+	for len(out) < size {
+		h := sha256.New()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			sum := sha256.Sum256(a)
+			a = sum[:]
+		}
+		out = append(out, a...)
+
+		b := new(big.Int).SetBytes(fill(a, v)[:v])
+		b.Add(b, one)
+		mod := new(big.Int).Lsh(one, v*8)
+		for j := 0; j < len(i); j += v {
+			block := new(big.Int).SetBytes(i[j : j+v])
+			block.Add(block, b).Mod(block, mod)
+			sum := block.Bytes()
+			copy(i[j:j+v], make([]byte, v))
+			copy(i[j+v-len(sum):j+v], sum)
+		}
+	}
+	return out[:size]
+}
+
+// fill repeats b to a multiple of v bytes, as the PKCS#12 KDF expects.
+func fill(b []byte, v int) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	out := make([]byte, v*((len(b)+v-1)/v))
+	for i := range out {
+		out[i] = b[i%len(b)]
+	}
+	return out
+}
+
+// bmpPassword encodes a password as a NUL-terminated BMPString.
+func bmpPassword(password string) []byte {
+	return append(bmpString(password), 0, 0)
+}
+
+func bmpString(s string) []byte {
+	var out []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package cert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+// Stages of a CA rotation, in order. An interrupted rotation resumes after
+// the last stage it recorded.
+const (
+	RotateGenerated    = "generated"
+	RotateTrusted      = "trusted"
+	RotateReissued     = "reissued"
+	RotateSwapped      = "swapped"
+	RotateOldUntrusted = "old_untrusted"
+	RotatePromoted     = "promoted"
+)
+
+var rotateStages = []string{RotateGenerated, RotateTrusted, RotateReissued, RotateSwapped, RotateOldUntrusted, RotatePromoted}
+
+var (
+	trustNextCAFn   = trustNextCA
+	untrustCAFn     = UntrustCA
+	promoteNextCAFn = promoteNextCA
+)
+
+// NextCADir holds the CA a rotation is moving to until it replaces the
+// current one.
+func NextCADir() string {
+	return filepath.Join(CADir(), "next")
+}
+
+func nextCACertPath() string {
+	return filepath.Join(NextCADir(), caCertFile)
+}
+
+func nextCAKeyPath() string {
+	return filepath.Join(NextCADir(), caKeyFile)
+}
+
+func rotationStatePath() string {
+	return filepath.Join(CADir(), "rotation")
+}
+
+// RotationStage returns the last stage an unfinished rotation recorded, or
+// "" when none is in progress.
+func RotationStage() (string, error) {
+	data, err := os.ReadFile(rotationStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading CA rotation state: %w", err)
+	}
+	stage := strings.TrimSpace(string(data))
+	if !slices.Contains(rotateStages, stage) {
+		return "", fmt.Errorf("unknown CA rotation stage %q in %s", stage, rotationStatePath())
+	}
+	return stage, nil
+}
+
+func SetRotationStage(stage string) error {
+	if err := os.WriteFile(rotationStatePath(), []byte(stage+"\n"), 0600); err != nil {
+		return fmt.Errorf("recording CA rotation stage: %w", err)
+	}
+	return nil
+}
+
+// signingCAPaths returns the CA that issues leaves: the next one once a
+// rotation has it trusted, the current one otherwise.
+func signingCAPaths() (string, string) {
+	stage, _ := RotationStage()
+	if slices.Index(rotateStages, stage) >= slices.Index(rotateStages, RotateTrusted) &&
+		fileExists(nextCACertPath()) && fileExists(nextCAKeyPath()) {
+		return nextCACertPath(), nextCAKeyPath()
+	}
+	return CACertPath(), CAKeyPath()
+}
+
+// GenerateNextCA creates the CA a rotation moves to, after checking that
+// it may sign every configured domain.
+func GenerateNextCA() error {
+	cfg, err := loadCAConfig()
+	if err != nil {
+		return err
+	}
+	if err := CheckNextCA(cfg); err != nil {
+		return err
+	}
+	return generateCA(NextCADir(), cfg.PermittedCASuffixes())
+}
+
+// CheckNextCA returns an error naming a configured domain that a new CA
+// limited to cfg's suffixes could not sign.
+func CheckNextCA(cfg *config.Config) error {
+	suffixes := cfg.PermittedCASuffixes()
+	for _, d := range cfg.Domains {
+		if !permitsSuffixes(suffixes, d.Name) {
+			return fmt.Errorf("%s is outside the names the new CA may sign (%s); add its suffix to ca_suffixes in %s or stop it first",
+				d.Name, formatSuffixes(suffixes), config.Path())
+		}
+	}
+	return nil
+}
+
+// TrustNextCA trusts the next CA alongside the current one, so certs from
+// either are accepted while leaves are reissued.
+func TrustNextCA() error {
+	return trustNextCAFn()
+}
+
+// ReissueLeaves signs every leaf under CertsDir with the next CA and
+// returns how many it issued. Leaves it already signed are kept, so an
+// interrupted run picks up where it stopped. Leaves for names the next CA
+// may not sign belong to no configured domain and are removed.
+func ReissueLeaves() (int, error) {
+	caCert, caKey, err := loadCA(nextCACertPath(), nextCAKeyPath())
+	if err != nil {
+		return 0, fmt.Errorf("loading new CA: %w", err)
+	}
+	names, err := leafNames()
+	if err != nil {
+		return 0, err
+	}
+
+	issued := 0
+	for _, name := range names {
+		if !permitsName(caCert, name) {
+			if err := removeLeaf(name); err != nil {
+				return issued, err
+			}
+			continue
+		}
+		if leafSignedBy(name, caCert) {
+			continue
+		}
+		if err := issueLeaf(name, caCert, caKey); err != nil {
+			return issued, fmt.Errorf("reissuing %s: %w", name, err)
+		}
+		issued++
+	}
+	return issued, nil
+}
+
+// UntrustOldCA removes the CA being replaced from the trust stores.
+func UntrustOldCA() error {
+	return untrustCAFn()
+}
+
+// PromoteNextCA moves the next CA's trust entries to the ones the current
+// CA uses.
+func PromoteNextCA() error {
+	return promoteNextCAFn()
+}
+
+// InstallNextCA replaces the old CA files with the next CA's and ends the
+// rotation. The rotation stays recorded until both files are in place, so
+// a run cut off between them is finished by the next one, which finds the
+// moved file already gone from NextCADir.
+func InstallNextCA() error {
+	for _, f := range []string{caKeyFile, caCertFile} {
+		src := filepath.Join(NextCADir(), f)
+		if !fileExists(src) {
+			continue
+		}
+		if err := os.Rename(src, filepath.Join(CADir(), f)); err != nil {
+			return fmt.Errorf("installing new CA: %w", err)
+		}
+	}
+	if err := os.RemoveAll(NextCADir()); err != nil {
+		return fmt.Errorf("removing %s: %w", NextCADir(), err)
+	}
+	if err := os.Remove(rotationStatePath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("clearing CA rotation state: %w", err)
+	}
+	return refreshBundle()
+}
+
+func removeLeaf(name string) error {
+	for _, path := range []string{LeafCertPath(name), LeafKeyPath(name)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,285 @@
+package cert
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func stubRotationTrust(t *testing.T) *[]string {
+	t.Helper()
+	prevTrust, prevUntrust, prevPromote := trustNextCAFn, untrustCAFn, promoteNextCAFn
+	t.Cleanup(func() { trustNextCAFn, untrustCAFn, promoteNextCAFn = prevTrust, prevUntrust, prevPromote })
+
+	var calls []string
+	trustNextCAFn = func() error { calls = append(calls, "trust next"); return nil }
+	untrustCAFn = func() error { calls = append(calls, "untrust old"); return nil }
+	promoteNextCAFn = func() error { calls = append(calls, "promote"); return nil }
+	return &calls
+}
+
+func verifyLeaf(t *testing.T, name, host string, caCert *x509.Certificate) error {
+	t.Helper()
+	tlsCert, err := LoadLeafTLS(name)
+	if err != nil {
+		t.Fatalf("LoadLeafTLS: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: host})
+	return err
+}
+
+func TestRotateCA(t *testing.T) {
+	initCertTestConfig(t)
+	calls := stubRotationTrust(t)
+
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	oldCA, err := LoadCACert()
+	if err != nil {
+		t.Fatalf("LoadCACert: %v", err)
+	}
+	for _, name := range []string{"myapp.test", "*.myapp.test"} {
+		if err := GenerateLeafCert(name); err != nil {
+			t.Fatalf("GenerateLeafCert: %v", err)
+		}
+	}
+	// A leaf left behind by an unconstrained CA.
+	if err := writeLeafCertPEM("example.com", "ecdsa", time.Now().Add(90*24*time.Hour)); err != nil {
+		t.Fatalf("writeLeafCertPEM: %v", err)
+	}
+	if err := os.WriteFile(LeafKeyPath("example.com"), []byte("key"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := GenerateNextCA(); err != nil {
+		t.Fatalf("GenerateNextCA: %v", err)
+	}
+	if err := SetRotationStage(RotateGenerated); err != nil {
+		t.Fatalf("SetRotationStage: %v", err)
+	}
+	if certPath, _ := signingCAPaths(); certPath != CACertPath() {
+		t.Fatalf("expected the old CA to sign until the new one is trusted, got %s", certPath)
+	}
+
+	if err := TrustNextCA(); err != nil {
+		t.Fatalf("TrustNextCA: %v", err)
+	}
+	if err := SetRotationStage(RotateTrusted); err != nil {
+		t.Fatalf("SetRotationStage: %v", err)
+	}
+	newCA, err := loadCACert(nextCACertPath())
+	if err != nil {
+		t.Fatalf("loading next CA: %v", err)
+	}
+	if reflect.DeepEqual(newCA.Subject, oldCA.Subject) {
+		t.Fatal("expected the new CA to have a subject of its own")
+	}
+	if certPath, _ := signingCAPaths(); certPath != nextCACertPath() {
+		t.Fatalf("expected the new CA to sign once trusted, got %s", certPath)
+	}
+
+	n, err := ReissueLeaves()
+	if err != nil || n != 2 {
+		t.Fatalf("ReissueLeaves = %d, %v; want 2 reissued", n, err)
+	}
+	if n, err := ReissueLeaves(); err != nil || n != 0 {
+		t.Fatalf("expected a second run to skip reissued leaves, got %d, %v", n, err)
+	}
+	if LeafExists("example.com") {
+		t.Fatal("expected the leaf outside the new CA's names to be removed")
+	}
+	if err := verifyLeaf(t, "*.myapp.test", "a.myapp.test", newCA); err != nil {
+		t.Fatalf("expected the reissued leaf to verify against the new CA: %v", err)
+	}
+
+	for _, fn := range []func() error{UntrustOldCA, PromoteNextCA, InstallNextCA} {
+		if err := fn(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"trust next", "untrust old", "promote"}; !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("trust calls = %v, want %v", *calls, want)
+	}
+	if stage, err := RotationStage(); stage != "" || err != nil {
+		t.Fatalf("expected the rotation to be finished, got %q, %v", stage, err)
+	}
+	if _, err := os.Stat(NextCADir()); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", NextCADir(), err)
+	}
+	installed, err := LoadCACert()
+	if err != nil {
+		t.Fatalf("LoadCACert: %v", err)
+	}
+	if !installed.Equal(newCA) {
+		t.Fatal("expected the new CA to replace the old one")
+	}
+	if _, _, err := LoadCA(); err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+	if err := verifyLeaf(t, "myapp.test", "myapp.test", installed); err != nil {
+		t.Fatalf("expected the leaf to verify against the installed CA: %v", err)
+	}
+	if leafNeedsRenewal("myapp.test") {
+		t.Fatal("expected the reissued leaf not to need renewal")
+	}
+}
+
+func TestGenerateNextCARefusesConfiguredDomainsOutsideItsNames(t *testing.T) {
+	initCertTestConfig(t)
+	if err := (&config.Config{Domains: []config.Domain{{Name: "my.demo", Port: 3000}}}).Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	err := GenerateNextCA()
+	if err == nil || !strings.Contains(err.Error(), "my.demo is outside the names the new CA may sign") {
+		t.Fatalf("expected a constraint error, got %v", err)
+	}
+	if fileExists(nextCACertPath()) {
+		t.Fatal("expected no CA to be generated")
+	}
+}
+
+func TestRotationStage(t *testing.T) {
+	initCertTestConfig(t)
+	if err := os.MkdirAll(CADir(), 0700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	if stage, err := RotationStage(); stage != "" || err != nil {
+		t.Fatalf("expected no rotation, got %q, %v", stage, err)
+	}
+	if err := SetRotationStage(RotateReissued); err != nil {
+		t.Fatalf("SetRotationStage: %v", err)
+	}
+	if stage, err := RotationStage(); stage != RotateReissued || err != nil {
+		t.Fatalf("RotationStage = %q, %v", stage, err)
+	}
+	if err := os.WriteFile(rotationStatePath(), []byte("halfway\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := RotationStage(); err == nil || !strings.Contains(err.Error(), `unknown CA rotation stage "halfway"`) {
+		t.Fatalf("expected an unknown stage error, got %v", err)
+	}
+}
+
+func TestLeafNeedsRenewalWhenIssuedByAnotherCA(t *testing.T) {
+	initCertTestConfig(t)
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	if err := GenerateLeafCert("myapp.test"); err != nil {
+		t.Fatalf("GenerateLeafCert: %v", err)
+	}
+	if leafNeedsRenewal("myapp.test") {
+		t.Fatal("expected a fresh leaf not to need renewal")
+	}
+
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	if !leafNeedsRenewal("myapp.test") {
+		t.Fatal("expected a leaf from the replaced CA to need renewal")
+	}
+}
+
+func TestLeafNames(t *testing.T) {
+	initCertTestConfig(t)
+	if names, err := leafNames(); names != nil || err != nil {
+		t.Fatalf("expected no leaves without a certs dir, got %v, %v", names, err)
+	}
+	if err := os.MkdirAll(CertsDir(), 0700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	for _, name := range []string{"myapp.test", "*.myapp.test"} {
+		for _, path := range []string{LeafCertPath(name), LeafKeyPath(name)} {
+			if err := os.WriteFile(path, nil, 0600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+		}
+	}
+
+	names, err := leafNames()
+	if err != nil {
+		t.Fatalf("leafNames: %v", err)
+	}
+	slices.Sort(names)
+	if want := []string{"*.myapp.test", "myapp.test"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("leafNames() = %v, want %v", names, want)
+	}
+}
+
+func TestInstallNextCAFinishesAfterInterruption(t *testing.T) {
+	initCertTestConfig(t)
+	stubRotationTrust(t)
+	if err := GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	if err := GenerateNextCA(); err != nil {
+		t.Fatalf("GenerateNextCA: %v", err)
+	}
+	if err := SetRotationStage(RotatePromoted); err != nil {
+		t.Fatalf("SetRotationStage: %v", err)
+	}
+	newCA, err := loadCACert(nextCACertPath())
+	if err != nil {
+		t.Fatalf("loading next CA: %v", err)
+	}
+
+	// Cut off after the key was moved but before the cert.
+	if err := os.Rename(nextCAKeyPath(), CAKeyPath()); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if stage, err := RotationStage(); stage != RotatePromoted || err != nil {
+		t.Fatalf("expected the rotation to stay recorded, got %q, %v", stage, err)
+	}
+
+	if err := InstallNextCA(); err != nil {
+		t.Fatalf("InstallNextCA: %v", err)
+	}
+	caCert, caKey, err := LoadCA()
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+	if !caCert.Equal(newCA) || !caKey.PublicKey.Equal(caCert.PublicKey) {
+		t.Fatal("expected the new CA cert and key to be installed together")
+	}
+	if stage, err := RotationStage(); stage != "" || err != nil {
+		t.Fatalf("expected the rotation to be finished, got %q, %v", stage, err)
+	}
+}
+
+func TestWriteFilesAtomicWritesAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "rootCA-key.pem")
+	if err := os.WriteFile(first, []byte("old"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	err := writeFilesAtomic(
+		pendingFile{first, []byte("new"), 0600},
+		pendingFile{filepath.Join(dir, "missing", "rootCA.pem"), []byte("new"), 0644},
+	)
+	if err == nil {
+		t.Fatal("expected the write into a missing directory to fail")
+	}
+	if data, _ := os.ReadFile(first); string(data) != "old" {
+		t.Fatalf("expected the first file to be left alone, got %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected no temporary files to be left behind, got %v", entries)
+	}
+}
@@ -12,10 +12,14 @@ import (
 var execCommandDarwinFn = exec.Command
 
 func TrustCA() error {
+	return trustCA(CACertPath())
+}
+
+func trustCA(certPath string) error {
 	cmd := execCommandDarwinFn("sudo", "security", "add-trusted-cert",
 		"-d", "-r", "trustRoot",
 		"-k", "/Library/Keychains/System.keychain",
-		CACertPath(),
+		certPath,
 	)
 	cmd.Stdin = nil
 	output, err := cmd.CombinedOutput()
@@ -38,3 +42,13 @@ func UntrustCA() error {
 	}
 	return nil
 }
+
+func trustNextCA() error {
+	return trustCA(nextCACertPath())
+}
+
+// promoteNextCA has nothing to do: the keychain trusts certificates, not
+// file paths, so the next CA stays trusted once it replaces the old one.
+func promoteNextCA() error {
+	return nil
+}
@@ -28,14 +28,70 @@ var (
 	detectTrustAnchorPathFn = detectTrustAnchorPath
 )
 
+// nextSlot names the trust entries of the CA a rotation moves to, so it is
+// trusted alongside the current one.
+const nextSlot = "next"
+
+// TrustCA adds the CA to the system store and to the NSS databases that
+// Firefox and Chromium use instead.
 func TrustCA() error {
-	certPEM, err := readCertFileFn(CACertPath())
+	return trustCA(CACertPath(), "")
+}
+
+func UntrustCA() error {
+	return untrustCA("")
+}
+
+func trustCA(certPath, slot string) error {
+	if err := trustSystem(certPath, slot); err != nil {
+		return err
+	}
+	return trustNSS(certPath, slot)
+}
+
+func untrustCA(slot string) error {
+	if err := untrustSystem(slot); err != nil {
+		return err
+	}
+	return untrustNSS(slot)
+}
+
+func trustNextCA() error {
+	return trustCA(nextCACertPath(), nextSlot)
+}
+
+// promoteNextCA trusts the next CA under the current CA's entries. NSS
+// keeps one nickname per certificate, so its next entry goes first.
+func promoteNextCA() error {
+	if err := trustSystem(nextCACertPath(), ""); err != nil {
+		return err
+	}
+	if err := untrustSystem(nextSlot); err != nil {
+		return err
+	}
+	if err := untrustNSS(nextSlot); err != nil {
+		return err
+	}
+	return trustNSS(nextCACertPath(), "")
+}
+
+// slotAnchor returns the anchor file for slot, such as slim-next.crt
+// next to slim.crt.
+func slotAnchor(path, slot string) string {
+	if slot == "" {
+		return path
+	}
+	return strings.TrimSuffix(path, ".crt") + "-" + slot + ".crt"
+}
+
+func trustSystem(certPath, slot string) error {
+	certPEM, err := readCertFileFn(certPath)
 	if err != nil {
 		return fmt.Errorf("reading CA cert: %w", err)
 	}
 
 	if commandExistsFn("update-ca-certificates") {
-		if err := writeAnchorFileFn(debianAnchorPath, certPEM); err != nil {
+		if err := writeAnchorFileFn(slotAnchor(debianAnchorPath, slot), certPEM); err != nil {
 			return err
 		}
 		if output, err := runPrivilegedTrustFn("update-ca-certificates"); err != nil {
@@ -45,7 +101,7 @@ func TrustCA() error {
 	}
 
 	if commandExistsFn("update-ca-trust") {
-		anchorPath := detectTrustAnchorPathFn()
+		anchorPath := slotAnchor(detectTrustAnchorPathFn(), slot)
 		if err := writeAnchorFileFn(anchorPath, certPEM); err != nil {
 			return err
 		}
@@ -58,9 +114,9 @@ func TrustCA() error {
 	return errors.New("no supported Linux CA trust tool found (need update-ca-certificates or update-ca-trust)")
 }
 
-func UntrustCA() error {
+func untrustSystem(slot string) error {
 	for _, path := range []string{debianAnchorPath, rhelAnchorPath, archAnchorPath} {
-		if err := removeAnchorFileFn(path); err != nil {
+		if err := removeAnchorFileFn(slotAnchor(path, slot)); err != nil {
 			return err
 		}
 	}
@@ -149,8 +149,14 @@ func snapshotTrustLinuxTestHooks() func() {
 	prevRun := runPrivilegedTrustFn
 	prevRemove := removeAnchorFileFn
 	prevDetect := detectTrustAnchorPathFn
+	prevHome := userHomeDirFn
+	prevCertutil := runCertutilFn
+	prevStores := nssStoresFn
 
 	return func() {
+		userHomeDirFn = prevHome
+		runCertutilFn = prevCertutil
+		nssStoresFn = prevStores
 		readCertFileFn = prevRead
 		commandExistsFn = prevExists
 		writeAnchorFileFn = prevWrite
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultCASuffixes are the suffixes every root CA may sign. Loopback IPs
+// are permitted too.
+var DefaultCASuffixes = []string{"test", "loc", "localhost"}
+
+// ValidateCASuffixes checks user-configured CA suffixes such as "demo" or
+// ".corp.example".
+func ValidateCASuffixes(suffixes []string) error {
+	for _, s := range suffixes {
+		name := normalizeCASuffix(s)
+		if name == "" {
+			return fmt.Errorf("ca_suffixes: suffix cannot be empty")
+		}
+		for _, label := range strings.Split(name, ".") {
+			if len(label) > 63 || !validLabel.MatchString(label) {
+				return fmt.Errorf("ca_suffixes: invalid suffix %q", s)
+			}
+		}
+	}
+	return nil
+}
+
+// PermittedCASuffixes returns the default suffixes followed by the
+// configured ones, without leading dots or duplicates.
+func (c *Config) PermittedCASuffixes() []string {
+	suffixes := slices.Clone(DefaultCASuffixes)
+	for _, s := range c.CASuffixes {
+		if name := normalizeCASuffix(s); name != "" && !slices.Contains(suffixes, name) {
+			suffixes = append(suffixes, name)
+		}
+	}
+	return suffixes
+}
+
+func normalizeCASuffix(s string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "."))
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPermittedCASuffixes(t *testing.T) {
+	cfg := &Config{CASuffixes: []string{".Demo", "test", " corp.example "}}
+	want := []string{"test", "loc", "localhost", "demo", "corp.example"}
+	if got := cfg.PermittedCASuffixes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("PermittedCASuffixes() = %v, want %v", got, want)
+	}
+	if got := (&Config{}).PermittedCASuffixes(); !reflect.DeepEqual(got, DefaultCASuffixes) {
+		t.Fatalf("PermittedCASuffixes() = %v, want the defaults", got)
+	}
+}
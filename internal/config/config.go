@@ -19,24 +19,82 @@ const (
 	LogModeOff     = "off"
 )
 
+// Port forwarding backends. Auto picks one for the platform.
+const (
+	PortForwardAuto     = "auto"
+	PortForwardIPTables = "iptables"
+	PortForwardNFTables = "nftables"
+)
+
 type Route struct {
-	Path string `yaml:"path"`
-	Port int    `yaml:"port"`
+	Path        string       `yaml:"path"`
+	Port        int          `yaml:"port,omitempty"`
+	Ports       []int        `yaml:"ports,omitempty"`
+	Balance     string       `yaml:"balance,omitempty"`
+	Sticky      bool         `yaml:"sticky,omitempty"`
+	Upstream    string       `yaml:"upstream,omitempty"`
+	Socket      string       `yaml:"socket,omitempty"`
+	Dir         string       `yaml:"dir,omitempty"`
+	SPA         bool         `yaml:"spa,omitempty"`
+	Listing     bool         `yaml:"listing,omitempty"`
+	Scheme      string       `yaml:"scheme,omitempty"`
+	TLS         *UpstreamTLS `yaml:"tls,omitempty"`
+	Headers     *Headers     `yaml:"headers,omitempty"`
+	StripPrefix *bool        `yaml:"strip_prefix,omitempty"`
+	Rewrite     *PathRewrite `yaml:"rewrite,omitempty"`
+}
+
+// PathRewrite replaces matches of Pattern in the forwarded path. It runs
+// after the route prefix has been stripped, unless strip_prefix is false.
+type PathRewrite struct {
+	Pattern string `yaml:"pattern"`
+	Replace string `yaml:"replace"`
 }
 
 type Domain struct {
-	Name   string  `yaml:"name"`
-	Port   int     `yaml:"port"`
-	Routes []Route `yaml:"routes,omitempty"`
+	Name     string       `yaml:"name"`
+	Port     int          `yaml:"port,omitempty"`
+	Ports    []int        `yaml:"ports,omitempty"`
+	Balance  string       `yaml:"balance,omitempty"`
+	Sticky   bool         `yaml:"sticky,omitempty"`
+	Upstream string       `yaml:"upstream,omitempty"`
+	Socket   string       `yaml:"socket,omitempty"`
+	Dir      string       `yaml:"dir,omitempty"`
+	SPA      bool         `yaml:"spa,omitempty"`
+	Listing  bool         `yaml:"listing,omitempty"`
+	Scheme   string       `yaml:"scheme,omitempty"`
+	TLS      *UpstreamTLS `yaml:"tls,omitempty"`
+	Headers  *Headers     `yaml:"headers,omitempty"`
+	Hosts    []string     `yaml:"hosts,omitempty"`
+	Routes   []Route      `yaml:"routes,omitempty"`
 }
 
 type Config struct {
 	Domains []Domain `yaml:"domains"`
 	LogMode string   `yaml:"log_mode,omitempty"`
 	Cors    bool     `yaml:"cors,omitempty"`
+	Headers *Headers `yaml:"headers,omitempty"`
+	// DNS resolves domains through the daemon's embedded resolver instead
+	// of /etc/hosts entries.
+	DNS bool `yaml:"dns,omitempty"`
+	// PortForward overrides the port forwarding backend on Linux.
+	PortForward string `yaml:"port_forward,omitempty"`
+	// HTTPPort and HTTPSPort move the proxy listeners off 10080/10443.
+	// They take effect when the daemon starts.
+	HTTPPort  int `yaml:"http_port,omitempty"`
+	HTTPSPort int `yaml:"https_port,omitempty"`
+	// NoPortForward is rootless mode: nothing redirects 80/443, so URLs
+	// carry the listener port and /etc/hosts is only edited when needed.
+	NoPortForward bool `yaml:"no_port_forward,omitempty"`
+	// CASuffixes are extra domain suffixes a newly generated root CA may
+	// sign, on top of DefaultCASuffixes.
+	CASuffixes []string `yaml:"ca_suffixes,omitempty"`
 }
 
 func NormalizeDomain(name string) string {
+	if base, ok := strings.CutPrefix(name, WildcardPrefix); ok {
+		return WildcardPrefix + NormalizeDomain(base)
+	}
 	if !strings.Contains(name, ".") {
 		return name + ".test"
 	}
@@ -44,8 +102,8 @@ func NormalizeDomain(name string) string {
 }
 
 func ValidateRoute(path string, port int) error {
-	if path == "" || path[0] != '/' {
-		return fmt.Errorf("route path must start with /")
+	if err := validateRoutePath(path); err != nil {
+		return err
 	}
 	if port < 1 || port > 65535 {
 		return fmt.Errorf("invalid route port %d: must be between 1 and 65535", port)
@@ -53,6 +111,57 @@ func ValidateRoute(path string, port int) error {
 	return nil
 }
 
+func validateRoutePath(path string) error {
+	if path == "" || path[0] != '/' {
+		return fmt.Errorf("route path must start with /")
+	}
+	return nil
+}
+
+func (r *Route) Validate() error {
+	if err := validateRoutePath(r.Path); err != nil {
+		return err
+	}
+	if err := r.Target().Validate(); err != nil {
+		return err
+	}
+	if r.Rewrite != nil {
+		if _, err := r.Rewrite.Compile(); err != nil {
+			return err
+		}
+	}
+	return r.Headers.Validate()
+}
+
+// ShouldStripPrefix reports whether the route prefix is removed before
+// forwarding. Routes strip by default.
+func (r *Route) ShouldStripPrefix() bool {
+	return r.StripPrefix == nil || *r.StripPrefix
+}
+
+func (p *PathRewrite) Compile() (*regexp.Regexp, error) {
+	if p.Pattern == "" {
+		return nil, fmt.Errorf("rewrite pattern cannot be empty")
+	}
+	re, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite pattern %q: %w", p.Pattern, err)
+	}
+	return re, nil
+}
+
+// RouteMatches reports whether reqPath falls under the route prefix: an exact
+// match or a match at a path segment boundary.
+func RouteMatches(prefix string, reqPath string) bool {
+	if reqPath == prefix {
+		return true
+	}
+	if !strings.HasPrefix(reqPath, prefix) {
+		return false
+	}
+	return prefix[len(prefix)-1] == '/' || (len(reqPath) > len(prefix) && reqPath[len(prefix)] == '/')
+}
+
 func (d *Domain) MatchRoute(reqPath string) int {
 	bestLen := 0
 	bestPort := d.Port
@@ -60,7 +169,7 @@ func (d *Domain) MatchRoute(reqPath string) int {
 		if len(r.Path) <= bestLen {
 			continue
 		}
-		if reqPath == r.Path || (strings.HasPrefix(reqPath, r.Path) && (r.Path[len(r.Path)-1] == '/' || (len(reqPath) > len(r.Path) && reqPath[len(r.Path)] == '/'))) {
+		if RouteMatches(r.Path, reqPath) {
 			bestLen = len(r.Path)
 			bestPort = r.Port
 		}
@@ -69,13 +178,30 @@ func (d *Domain) MatchRoute(reqPath string) int {
 }
 
 func ValidateDomain(name string, port int) error {
+	if err := ValidateDomainName(name); err != nil {
+		return err
+	}
+	return validatePort(port)
+}
+
+func ValidateDomainName(name string) error {
 	if name == "" {
 		return fmt.Errorf("domain name cannot be empty")
 	}
+	if name == InspectorDomain {
+		return fmt.Errorf("domain %s is reserved for the request inspector", name)
+	}
 	if len(name) > 253 {
 		return fmt.Errorf("domain name %q is too long: must be 253 characters or fewer", name)
 	}
-	for _, label := range strings.Split(name, ".") {
+	labels := name
+	if base, ok := strings.CutPrefix(name, WildcardPrefix); ok {
+		if !strings.Contains(base, ".") {
+			return fmt.Errorf("wildcard domain %q is too broad: use at least two labels after *., like *.myapp.test", name)
+		}
+		labels = base
+	}
+	for _, label := range strings.Split(labels, ".") {
 		if len(label) > 63 {
 			return fmt.Errorf("domain label %q is too long: must be 63 characters or fewer", label)
 		}
@@ -83,12 +209,59 @@ func ValidateDomain(name string, port int) error {
 			return fmt.Errorf("invalid domain name %q: labels must be lowercase alphanumeric with hyphens", name)
 		}
 	}
-	if port < 1 || port > 65535 {
-		return fmt.Errorf("invalid port %d: must be between 1 and 65535", port)
+	return nil
+}
+
+func (d *Domain) Validate() error {
+	if err := ValidateDomainName(d.Name); err != nil {
+		return err
+	}
+	if err := d.Target().Validate(); err != nil {
+		return err
+	}
+	if err := d.Headers.Validate(); err != nil {
+		return err
+	}
+	if err := d.validateHosts(); err != nil {
+		return err
+	}
+	for _, r := range d.Routes {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("route %q: %w", r.Path, err)
+		}
 	}
 	return nil
 }
 
+// Validate checks a whole config, such as one edited by hand, before it
+// replaces the one being served.
+func (c *Config) Validate() error {
+	seen := make(map[string]bool, len(c.Domains))
+	for i := range c.Domains {
+		d := &c.Domains[i]
+		if err := d.Validate(); err != nil {
+			return fmt.Errorf("domain %s: %w", d.Name, err)
+		}
+		if seen[d.Name] {
+			return fmt.Errorf("duplicate domain %s", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	if err := ValidateLogMode(c.LogMode); err != nil {
+		return err
+	}
+	if err := c.Headers.Validate(); err != nil {
+		return err
+	}
+	if err := ValidatePortForward(c.PortForward); err != nil {
+		return err
+	}
+	if err := ValidateCASuffixes(c.CASuffixes); err != nil {
+		return err
+	}
+	return c.ValidatePorts()
+}
+
 func ValidateLogMode(mode string) error {
 	switch normalizeLogMode(mode) {
 	case LogModeFull, LogModeMinimal, LogModeOff:
@@ -98,6 +271,15 @@ func ValidateLogMode(mode string) error {
 	}
 }
 
+func ValidatePortForward(backend string) error {
+	switch backend {
+	case "", PortForwardAuto, PortForwardIPTables, PortForwardNFTables:
+		return nil
+	default:
+		return fmt.Errorf("invalid port_forward %q: must be one of auto|iptables|nftables", backend)
+	}
+}
+
 func normalizeLogMode(mode string) string {
 	mode = strings.ToLower(strings.TrimSpace(mode))
 	if mode == "" {
@@ -143,14 +325,23 @@ func (c *Config) Save() error {
 		return fmt.Errorf("creating config dir: %w", err)
 	}
 
-	data, err := yaml.Marshal(c)
+	data, err := c.Encode()
 	if err != nil {
-		return fmt.Errorf("marshaling config: %w", err)
+		return err
 	}
 
 	return os.WriteFile(Path(), data, 0644)
 }
 
+// Encode returns the config as Save writes it.
+func (c *Config) Encode() ([]byte, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling config: %w", err)
+	}
+	return data, nil
+}
+
 func (c *Config) FindDomain(name string) (*Domain, int) {
 	for i := range c.Domains {
 		if c.Domains[i].Name == name {
@@ -161,15 +352,18 @@ func (c *Config) FindDomain(name string) (*Domain, int) {
 }
 
 func (c *Config) SetDomain(name string, port int, routes []Route) error {
-	if existing, idx := c.FindDomain(name); existing != nil {
-		c.Domains[idx].Port = port
-		c.Domains[idx].Routes = routes
-		return c.Save()
-	}
-	c.Domains = append(c.Domains, Domain{Name: name, Port: port, Routes: routes})
+	c.PutDomain(Domain{Name: name, Port: port, Routes: routes})
 	return c.Save()
 }
 
+func (c *Config) PutDomain(d Domain) {
+	if existing, idx := c.FindDomain(d.Name); existing != nil {
+		c.Domains[idx] = d
+		return
+	}
+	c.Domains = append(c.Domains, d)
+}
+
 func (c *Config) RemoveDomain(name string) error {
 	_, idx := c.FindDomain(name)
 	if idx == -1 {
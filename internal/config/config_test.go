@@ -17,6 +17,8 @@ func TestNormalizeDomain(t *testing.T) {
 		{"my.custom.domain", "my.custom.domain"},
 		{"app.local", "app.local"},
 		{"web.dev", "web.dev"},
+		{"*.myapp", "*.myapp.test"},
+		{"*.myapp.test", "*.myapp.test"},
 	}
 
 	for _, tt := range tests {
@@ -85,6 +87,7 @@ func TestValidateDomain(t *testing.T) {
 		{"my.app", 3000, false},
 		{"web.roadmap", 3000, false},
 		{"a.b.c", 3000, false},
+		{"slim.test", 3000, true},
 		{"my..app", 3000, true},
 		{".myapp", 3000, true},
 		{"myapp.", 3000, true},
@@ -93,6 +96,11 @@ func TestValidateDomain(t *testing.T) {
 		{strings.Repeat("a", 63), 3000, false},
 		{strings.Repeat("a", 64), 3000, true},
 		{strings.Repeat("a", 63) + "." + strings.Repeat("b", 63), 3000, false},
+		{"*.myapp.test", 3000, false},
+		{"*.test", 3000, true},
+		{"*.*.myapp.test", 3000, true},
+		{"a.*.myapp.test", 3000, true},
+		{"*", 3000, true},
 		{"myapp", 0, true},
 		{"myapp", -1, true},
 		{"myapp", 65536, true},
@@ -228,6 +236,37 @@ func TestMatchRoute(t *testing.T) {
 	}
 }
 
+func TestRouteValidateRewrite(t *testing.T) {
+	tests := []struct {
+		route   Route
+		wantErr bool
+	}{
+		{Route{Path: "/api", Port: 8080, Rewrite: &PathRewrite{Pattern: "^/api", Replace: "/v2"}}, false},
+		{Route{Path: "/api", Port: 8080, Rewrite: &PathRewrite{Pattern: ""}}, true},
+		{Route{Path: "/api", Port: 8080, Rewrite: &PathRewrite{Pattern: "(", Replace: "/"}}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.route.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() error = %v, wantErr %v", tt.route.Rewrite, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRouteShouldStripPrefix(t *testing.T) {
+	keep, strip := false, true
+	if !(&Route{}).ShouldStripPrefix() {
+		t.Error("expected routes to strip the prefix by default")
+	}
+	if !(&Route{StripPrefix: &strip}).ShouldStripPrefix() {
+		t.Error("expected strip_prefix: true to strip")
+	}
+	if (&Route{StripPrefix: &keep}).ShouldStripPrefix() {
+		t.Error("expected strip_prefix: false to keep the prefix")
+	}
+}
+
 func TestSetDomainWithRoutes(t *testing.T) {
 	baseDir = t.TempDir()
 
@@ -273,3 +312,55 @@ func TestLogMode(t *testing.T) {
 		t.Fatal("expected error for invalid log mode")
 	}
 }
+
+func TestValidatePortForward(t *testing.T) {
+	tests := []struct {
+		backend string
+		wantErr bool
+	}{
+		{"", false},
+		{"auto", false},
+		{"iptables", false},
+		{"nftables", false},
+		{"pf", true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePortForward(tt.backend); (err != nil) != tt.wantErr {
+			t.Fatalf("ValidatePortForward(%q) error = %v, wantErr %v", tt.backend, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"valid", Config{Domains: []Domain{{Name: "myapp.test", Port: 3000}}, LogMode: "minimal"}, ""},
+		{"empty", Config{}, ""},
+		{"bad domain", Config{Domains: []Domain{{Name: "My App.test", Port: 3000}}}, "invalid domain name"},
+		{"bad port", Config{Domains: []Domain{{Name: "myapp.test", Port: 70000}}}, "domain myapp.test"},
+		{"duplicate", Config{Domains: []Domain{{Name: "myapp.test", Port: 3000}, {Name: "myapp.test", Port: 4000}}}, "duplicate domain myapp.test"},
+		{"log mode", Config{LogMode: "verbose"}, "invalid log mode"},
+		{"port forward", Config{PortForward: "pf"}, "invalid port_forward"},
+		{"listener ports", Config{HTTPPort: 8443, HTTPSPort: 8443}, "must differ"},
+		{"ca suffixes", Config{CASuffixes: []string{"demo", ".corp.example"}}, ""},
+		{"bad ca suffix", Config{CASuffixes: []string{"my demo"}}, "invalid suffix"},
+		{"empty ca suffix", Config{CASuffixes: []string{"."}}, "cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
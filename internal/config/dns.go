@@ -0,0 +1,43 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// DNSZone returns the zone the embedded resolver answers name in. Under
+// one of the private suffixes that is the whole suffix; any other name,
+// such as one under a public TLD, is its own zone, so the rest of that TLD
+// keeps resolving through the real DNS.
+func DNSZone(name string, suffixes []string) string {
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "."), WildcardPrefix)
+	for _, s := range suffixes {
+		if name == s || strings.HasSuffix(name, "."+s) {
+			return s
+		}
+	}
+	return name
+}
+
+// DNSZones returns the sorted zones covering the configured domains, with
+// ca_suffixes counted as private. The inspector's zone is always included.
+func (c *Config) DNSZones() []string {
+	suffixes := c.PermittedCASuffixes()
+	zones := []string{DNSZone(InspectorDomain, suffixes)}
+	for _, d := range c.Domains {
+		if z := DNSZone(d.Name, suffixes); !slices.Contains(zones, z) {
+			zones = append(zones, z)
+		}
+	}
+	slices.Sort(zones)
+	return zones
+}
+
+// DomainNames returns the names of all configured domains.
+func (c *Config) DomainNames() []string {
+	names := make([]string, len(c.Domains))
+	for i, d := range c.Domains {
+		names[i] = d.Name
+	}
+	return names
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDNSZones(t *testing.T) {
+	tests := []struct {
+		name     string
+		domains  []string
+		suffixes []string
+		want     []string
+	}{
+		{"none", nil, nil, []string{"test"}},
+		{"dedupes", []string{"myapp.test", "api.test"}, nil, []string{"test"}},
+		{"private suffixes", []string{"app.loc", "web.localhost", "myapp.test"}, nil, []string{"loc", "localhost", "test"}},
+		{"public tlds", []string{"api.dev", "*.tenants.dev", "app.example.com"}, nil, []string{"api.dev", "app.example.com", "tenants.dev", "test"}},
+		{"ca suffixes", []string{"app.demo", "a.b.corp.example"}, []string{"demo", "corp.example"}, []string{"corp.example", "demo", "test"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CASuffixes: tt.suffixes}
+			for _, name := range tt.domains {
+				cfg.Domains = append(cfg.Domains, Domain{Name: name, Port: 3000})
+			}
+			if got := cfg.DNSZones(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DNSZones(%v) = %v, want %v", tt.domains, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Headers rewrites headers on proxied requests and responses. Set values may
+// contain the templates {host} and {remote_addr}.
+type Headers struct {
+	Request  HeaderRules `yaml:"request,omitempty"`
+	Response HeaderRules `yaml:"response,omitempty"`
+}
+
+type HeaderRules struct {
+	Set    map[string]string `yaml:"set,omitempty"`
+	Remove []string          `yaml:"remove,omitempty"`
+}
+
+func (h *Headers) Validate() error {
+	if h == nil {
+		return nil
+	}
+	if err := h.Request.validate(); err != nil {
+		return fmt.Errorf("headers.request: %w", err)
+	}
+	if err := h.Response.validate(); err != nil {
+		return fmt.Errorf("headers.response: %w", err)
+	}
+	return nil
+}
+
+func (r HeaderRules) validate() error {
+	for name, value := range r.Set {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid value for header %q", name)
+		}
+	}
+	for _, name := range r.Remove {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import "testing"
+
+func TestHeadersValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers *Headers
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"valid", &Headers{
+			Request:  HeaderRules{Set: map[string]string{"X-Forwarded-User": "dev", "X-Client": "{remote_addr}"}},
+			Response: HeaderRules{Remove: []string{"Strict-Transport-Security"}},
+		}, false},
+		{"invalid set name", &Headers{Request: HeaderRules{Set: map[string]string{"X Bad": "1"}}}, true},
+		{"invalid set value", &Headers{Response: HeaderRules{Set: map[string]string{"X-Bad": "a\nb"}}}, true},
+		{"invalid remove name", &Headers{Response: HeaderRules{Remove: []string{"Bad:Name"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.headers.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
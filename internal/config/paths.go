@@ -9,6 +9,11 @@ import (
 const (
 	ProxyHTTPPort  = 10080
 	ProxyHTTPSPort = 10443
+	ProxyDNSPort   = 10053
+
+	// InspectorDomain serves the request inspector and can't be used for
+	// a service.
+	InspectorDomain = "slim.test"
 
 	defaultAPIBase      = "https://app.slim.sh"
 	defaultTunnelServer = "wss://app.slim.sh/tunnel"
@@ -51,6 +56,10 @@ func LogPath() string {
 	return filepath.Join(Dir(), "access.log")
 }
 
+func DaemonLogPath() string {
+	return filepath.Join(Dir(), "daemon.log")
+}
+
 func SocketPath() string {
 	return filepath.Join(Dir(), "slim.sock")
 }
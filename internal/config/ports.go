@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// HTTPListenPort is the port the daemon serves plain HTTP on.
+func (c *Config) HTTPListenPort() int {
+	if c != nil && c.HTTPPort != 0 {
+		return c.HTTPPort
+	}
+	return ProxyHTTPPort
+}
+
+// HTTPSListenPort is the port the daemon serves HTTPS on.
+func (c *Config) HTTPSListenPort() int {
+	if c != nil && c.HTTPSPort != 0 {
+		return c.HTTPSPort
+	}
+	return ProxyHTTPSPort
+}
+
+// PublicHTTPPort is the port clients use for plain HTTP: 80 when it is
+// forwarded to the listener, the listener port itself in rootless mode.
+func (c *Config) PublicHTTPPort() int {
+	if c != nil && c.NoPortForward {
+		return c.HTTPListenPort()
+	}
+	return 80
+}
+
+// PublicHTTPSPort is the HTTPS counterpart of PublicHTTPPort.
+func (c *Config) PublicHTTPSPort() int {
+	if c != nil && c.NoPortForward {
+		return c.HTTPSListenPort()
+	}
+	return 443
+}
+
+// HostPort returns host with the public HTTPS port appended when it
+// isn't 443.
+func (c *Config) HostPort(host string) string {
+	if port := c.PublicHTTPSPort(); port != 443 {
+		return net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	return host
+}
+
+// URL returns the address browsers use to reach host.
+func (c *Config) URL(host string) string {
+	return "https://" + c.HostPort(host)
+}
+
+func (c *Config) ValidatePorts() error {
+	for _, p := range []struct {
+		name string
+		port int
+	}{{"http_port", c.HTTPPort}, {"https_port", c.HTTPSPort}} {
+		if p.port < 0 || p.port > 65535 {
+			return fmt.Errorf("invalid %s %d: must be between 1 and 65535", p.name, p.port)
+		}
+	}
+	if c.HTTPListenPort() == c.HTTPSListenPort() {
+		return fmt.Errorf("http_port and https_port must differ (both are %d)", c.HTTPListenPort())
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import "testing"
+
+func TestConfigURL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		want string
+	}{
+		{"nil config", nil, "https://myapp.test"},
+		{"port forwarding", &Config{HTTPSPort: 8443}, "https://myapp.test"},
+		{"rootless default port", &Config{NoPortForward: true}, "https://myapp.test:10443"},
+		{"rootless custom port", &Config{NoPortForward: true, HTTPSPort: 8443}, "https://myapp.test:8443"},
+		{"rootless on 443", &Config{NoPortForward: true, HTTPSPort: 443}, "https://myapp.test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.URL("myapp.test"); got != tt.want {
+				t.Fatalf("URL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"defaults", Config{}, false},
+		{"custom", Config{HTTPPort: 8080, HTTPSPort: 8443}, false},
+		{"out of range", Config{HTTPSPort: 70000}, true},
+		{"same port", Config{HTTPPort: 8443, HTTPSPort: 8443}, true},
+		{"clashes with default", Config{HTTPPort: ProxyHTTPSPort}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.ValidatePorts(); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
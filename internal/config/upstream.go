@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Target is where a domain or route forwards traffic: exactly one of a local
+// port, a set of local ports to balance across, a full upstream URL, a Unix
+// domain socket or a static directory.
+type Target struct {
+	Port     int
+	Ports    []int
+	Balance  string
+	Sticky   bool
+	Upstream string
+	Socket   string
+	Dir      string
+	SPA      bool
+	Listing  bool
+	Scheme   string
+	TLS      *UpstreamTLS
+}
+
+// UpstreamTLS controls certificate verification for https upstreams. With
+// no options set the system roots are used.
+type UpstreamTLS struct {
+	Insecure bool   `yaml:"insecure,omitempty"`
+	CAFile   string `yaml:"ca_file,omitempty"`
+	SlimCA   bool   `yaml:"slim_ca,omitempty"`
+}
+
+const (
+	BalanceRoundRobin = "round_robin"
+	BalanceLeastConn  = "least_conn"
+	BalanceRandom     = "random"
+)
+
+func ParseUpstream(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid upstream %q: scheme must be http or https", raw)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid upstream %q: missing host", raw)
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid upstream %q: must not contain credentials, query or fragment", raw)
+	}
+	return u, nil
+}
+
+func (t Target) Validate() error {
+	set := 0
+	for _, ok := range []bool{t.Port != 0, len(t.Ports) > 0, t.Upstream != "", t.Socket != "", t.Dir != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("only one of port, ports, upstream, socket or dir can be set")
+	}
+	if (t.Balance != "" || t.Sticky) && len(t.Ports) == 0 {
+		return fmt.Errorf("balance and sticky can only be set together with ports")
+	}
+	switch t.Balance {
+	case "", BalanceRoundRobin, BalanceLeastConn, BalanceRandom:
+	default:
+		return fmt.Errorf("invalid balance %q: must be round_robin, least_conn or random", t.Balance)
+	}
+	if (t.SPA || t.Listing) && t.Dir == "" {
+		return fmt.Errorf("spa and listing can only be set together with dir")
+	}
+
+	switch t.Scheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("invalid scheme %q: must be http or https", t.Scheme)
+	}
+	if t.Scheme != "" && t.Port == 0 && len(t.Ports) == 0 {
+		return fmt.Errorf("scheme can only be set together with port")
+	}
+
+	switch {
+	case len(t.Ports) > 0:
+		seen := make(map[int]bool, len(t.Ports))
+		for _, port := range t.Ports {
+			if err := validatePort(port); err != nil {
+				return err
+			}
+			if seen[port] {
+				return fmt.Errorf("duplicate port %d", port)
+			}
+			seen[port] = true
+		}
+	case t.Upstream != "":
+		if _, err := ParseUpstream(t.Upstream); err != nil {
+			return err
+		}
+	case t.Socket != "":
+		if !filepath.IsAbs(t.Socket) {
+			return fmt.Errorf("invalid socket %q: must be an absolute path", t.Socket)
+		}
+	case t.Dir != "":
+		if !filepath.IsAbs(t.Dir) {
+			return fmt.Errorf("invalid dir %q: must be an absolute path", t.Dir)
+		}
+	default:
+		if err := validatePort(t.Port); err != nil {
+			return err
+		}
+	}
+
+	if t.TLS != nil {
+		if !t.IsHTTPS() {
+			return fmt.Errorf("tls options require an https upstream")
+		}
+		if t.TLS.CAFile != "" && !filepath.IsAbs(t.TLS.CAFile) {
+			return fmt.Errorf("invalid tls ca_file %q: must be an absolute path", t.TLS.CAFile)
+		}
+	}
+	return nil
+}
+
+func (t Target) IsHTTPS() bool {
+	if t.Upstream != "" {
+		u, err := ParseUpstream(t.Upstream)
+		return err == nil && u.Scheme == "https"
+	}
+	return (t.Port != 0 || len(t.Ports) > 0) && t.Scheme == "https"
+}
+
+// Expand splits a balanced target into one single-port target per upstream.
+// Any other target is returned as is.
+func (t Target) Expand() []Target {
+	if len(t.Ports) == 0 {
+		return []Target{t}
+	}
+	targets := make([]Target, len(t.Ports))
+	for i, port := range t.Ports {
+		targets[i] = Target{Port: port, Scheme: t.Scheme, TLS: t.TLS}
+	}
+	return targets
+}
+
+// URL resolves the target into the address requests are forwarded to. Bare
+// ports point at localhost, sockets use the unix scheme and static
+// directories the file scheme.
+func (t Target) URL() (*url.URL, error) {
+	switch {
+	case len(t.Ports) > 0:
+		return nil, fmt.Errorf("target balances across %d ports and has no single URL", len(t.Ports))
+	case t.Upstream != "":
+		return ParseUpstream(t.Upstream)
+	case t.Socket != "":
+		return &url.URL{Scheme: "unix", Path: t.Socket}, nil
+	case t.Dir != "":
+		return &url.URL{Scheme: "file", Path: t.Dir}, nil
+	default:
+		scheme := t.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		return &url.URL{Scheme: scheme, Host: fmt.Sprintf("localhost:%d", t.Port)}, nil
+	}
+}
+
+func (t Target) String() string {
+	switch {
+	case len(t.Ports) > 0:
+		ports := make([]string, len(t.Ports))
+		for i, port := range t.Ports {
+			ports[i] = strconv.Itoa(port)
+		}
+		if t.Scheme == "https" {
+			return "https://localhost:" + strings.Join(ports, ",")
+		}
+		return "localhost:" + strings.Join(ports, ",")
+	case t.Upstream != "":
+		return t.Upstream
+	case t.Socket != "":
+		return "unix:" + t.Socket
+	case t.Dir != "":
+		return "dir:" + t.Dir
+	case t.Scheme == "https":
+		return fmt.Sprintf("https://localhost:%d", t.Port)
+	default:
+		return fmt.Sprintf("localhost:%d", t.Port)
+	}
+}
+
+func (d *Domain) Target() Target {
+	return Target{
+		Port:     d.Port,
+		Ports:    d.Ports,
+		Balance:  d.Balance,
+		Sticky:   d.Sticky,
+		Upstream: d.Upstream,
+		Socket:   d.Socket,
+		Dir:      d.Dir,
+		SPA:      d.SPA,
+		Listing:  d.Listing,
+		Scheme:   d.Scheme,
+		TLS:      d.TLS,
+	}
+}
+
+// Targets returns the domain's target followed by one per route, in order.
+func (d *Domain) Targets() []Target {
+	targets := make([]Target, 0, len(d.Routes)+1)
+	targets = append(targets, d.Target())
+	for _, r := range d.Routes {
+		targets = append(targets, r.Target())
+	}
+	return targets
+}
+
+func (d *Domain) UpstreamURL() (*url.URL, error) {
+	return d.Target().URL()
+}
+
+// UpstreamURLs returns every upstream of the domain and its routes, in the
+// order of Targets with balanced targets expanded. Entries that fail to
+// parse are nil.
+func (d *Domain) UpstreamURLs() []*url.URL {
+	var urls []*url.URL
+	for _, t := range d.Targets() {
+		for _, e := range t.Expand() {
+			u, _ := e.URL()
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func (r *Route) Target() Target {
+	return Target{
+		Port:     r.Port,
+		Ports:    r.Ports,
+		Balance:  r.Balance,
+		Sticky:   r.Sticky,
+		Upstream: r.Upstream,
+		Socket:   r.Socket,
+		Dir:      r.Dir,
+		SPA:      r.SPA,
+		Listing:  r.Listing,
+		Scheme:   r.Scheme,
+		TLS:      r.TLS,
+	}
+}
+
+func (r *Route) UpstreamURL() (*url.URL, error) {
+	return r.Target().URL()
+}
+
+func validatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d: must be between 1 and 65535", port)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WildcardPrefix marks a domain that matches any single label below it, so
+// *.myapp.test serves tenant1.myapp.test and tenant2.myapp.test.
+const WildcardPrefix = "*."
+
+func IsWildcardDomain(name string) bool {
+	return strings.HasPrefix(name, WildcardPrefix)
+}
+
+// WildcardFor returns the wildcard domain that would cover host.
+func WildcardFor(host string) (string, bool) {
+	if IsWildcardDomain(host) {
+		return "", false
+	}
+	_, rest, ok := strings.Cut(host, ".")
+	if !ok || !strings.Contains(rest, ".") {
+		return "", false
+	}
+	return WildcardPrefix + rest, true
+}
+
+// QualifyHost turns a bare label like tenant1 into a name under the
+// wildcard domain. Names that already contain a dot are returned as is.
+func QualifyHost(wildcard, host string) string {
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+	if strings.Contains(host, ".") {
+		return host
+	}
+	return host + "." + strings.TrimPrefix(wildcard, WildcardPrefix)
+}
+
+// HostNames returns the names slim adds to /etc/hosts for the domain. The
+// hosts file cannot hold wildcards, so a wildcard domain only gets entries
+// for the names listed in Hosts.
+func (d *Domain) HostNames() []string {
+	if IsWildcardDomain(d.Name) {
+		return d.Hosts
+	}
+	return []string{d.Name}
+}
+
+func (d *Domain) validateHosts() error {
+	if len(d.Hosts) == 0 {
+		return nil
+	}
+	if !IsWildcardDomain(d.Name) {
+		return fmt.Errorf("hosts are only supported on wildcard domains")
+	}
+	for _, h := range d.Hosts {
+		if err := ValidateDomainName(h); err != nil {
+			return err
+		}
+		if w, _ := WildcardFor(h); w != d.Name {
+			return fmt.Errorf("host %s is not covered by %s", h, d.Name)
+		}
+	}
+	return nil
+}
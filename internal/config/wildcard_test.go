@@ -0,0 +1,91 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWildcardFor(t *testing.T) {
+	tests := []struct {
+		host   string
+		want   string
+		wantOK bool
+	}{
+		{"tenant1.myapp.test", "*.myapp.test", true},
+		{"a.b.myapp.test", "*.b.myapp.test", true},
+		{"myapp.test", "", false},
+		{"localhost", "", false},
+		{"*.myapp.test", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := WildcardFor(tt.host)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("WildcardFor(%q) = %q, %v, want %q, %v", tt.host, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestQualifyHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"tenant1", "tenant1.myapp.test"},
+		{" Tenant1 ", "tenant1.myapp.test"},
+		{"tenant1.myapp.test", "tenant1.myapp.test"},
+		{"tenant1.myapp.test.", "tenant1.myapp.test"},
+	}
+	for _, tt := range tests {
+		if got := QualifyHost("*.myapp.test", tt.host); got != tt.want {
+			t.Errorf("QualifyHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestDomainHostNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain Domain
+		want   []string
+	}{
+		{"exact", Domain{Name: "myapp.test"}, []string{"myapp.test"}},
+		{"wildcard", Domain{Name: "*.myapp.test", Hosts: []string{"a.myapp.test"}}, []string{"a.myapp.test"}},
+		{"wildcard without hosts", Domain{Name: "*.myapp.test"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.domain.HostNames(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("HostNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainValidateHosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  Domain
+		wantErr string
+	}{
+		{"covered hosts", Domain{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test", "b.myapp.test"}}, ""},
+		{"exact domain", Domain{Name: "myapp.test", Port: 3000, Hosts: []string{"a.myapp.test"}}, "only supported on wildcard"},
+		{"other domain", Domain{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.other.test"}}, "not covered"},
+		{"nested name", Domain{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.b.myapp.test"}}, "not covered"},
+		{"invalid name", Domain{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a_b.myapp.test"}}, "invalid domain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.domain.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
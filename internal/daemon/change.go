@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/log"
+	"github.com/kamranahmedse/slim/internal/proxy"
+)
+
+// changeMu serialises changes and reloads, so concurrent clients, the
+// config watcher and reload requests see changes applied in order.
+var changeMu sync.Mutex
+
+// Apply makes the change carried by req to the config file, under the
+// config lock. The daemon runs it for change messages; clients call it
+// directly when no daemon is running.
+func Apply(req Request) (*ConfigDiff, error) {
+	return apply(req, nil)
+}
+
+// apply is Apply with a serve step: a change is validated and, when serve
+// is set, served before it is saved, so one the daemon can't serve never
+// reaches the config file.
+func apply(req Request, serve func(*config.Config) error) (*ConfigDiff, error) {
+	var diff *ConfigDiff
+	err := config.WithLock(func() error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		diff = &ConfigDiff{Config: cfg}
+
+		switch req.Type {
+		case MsgAddDomain:
+			var r AddDomainRequest
+			if err := decodeChange(req, &r); err != nil {
+				return err
+			}
+			err = addDomains(cfg, r, diff)
+		case MsgRemoveDomain:
+			var r RemoveDomainRequest
+			if err := decodeChange(req, &r); err != nil {
+				return err
+			}
+			err = removeDomains(cfg, r, diff)
+		case MsgSetOptions:
+			var r SetOptionsRequest
+			if err := decodeChange(req, &r); err != nil {
+				return err
+			}
+			err = setOptions(cfg, r, diff)
+		default:
+			return fmt.Errorf("unknown change message: %s", req.Type)
+		}
+		if err != nil {
+			return err
+		}
+		if !diff.changed() {
+			return nil
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if serve == nil {
+			return cfg.Save()
+		}
+		err = serve(cfg)
+		if err == nil {
+			err = cfg.Save()
+		}
+		if err != nil {
+			// A failed serve may have got partway, so in either case go
+			// back to serving what the file still holds.
+			if old, loadErr := config.Load(); loadErr == nil {
+				_ = serve(old)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func decodeChange(req Request, v any) error {
+	if len(req.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Data, v); err != nil {
+		return fmt.Errorf("invalid %s request: %w", req.Type, err)
+	}
+	return nil
+}
+
+func (d *ConfigDiff) changed() bool {
+	return len(d.Added) > 0 || len(d.Updated) > 0 || len(d.Removed) > 0 || len(d.Options) > 0
+}
+
+func addDomains(cfg *config.Config, r AddDomainRequest, diff *ConfigDiff) error {
+	seen := make(map[string]bool, len(r.Domains))
+	for i := range r.Domains {
+		d := &r.Domains[i]
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		if err := cert.CheckName(d.Name); err != nil {
+			return err
+		}
+		if seen[d.Name] {
+			return fmt.Errorf("duplicate domain %s", d.Name)
+		}
+		seen[d.Name] = true
+	}
+	if err := setOptions(cfg, r.Options, diff); err != nil {
+		return err
+	}
+
+	for _, d := range r.Domains {
+		existing, _ := cfg.FindDomain(d.Name)
+		if existing == nil {
+			cfg.PutDomain(d)
+			diff.Added = append(diff.Added, DomainChange{Name: d.Name, Hosts: d.HostNames()})
+			continue
+		}
+		if r.KeepHosts && config.IsWildcardDomain(d.Name) && len(d.Hosts) == 0 {
+			d.Hosts = existing.Hosts
+		}
+		if reflect.DeepEqual(*existing, d) {
+			continue
+		}
+		change := DomainChange{Name: d.Name, Hosts: d.HostNames()}
+		for _, h := range existing.HostNames() {
+			if !slices.Contains(change.Hosts, h) {
+				change.StaleHosts = append(change.StaleHosts, h)
+			}
+		}
+		cfg.PutDomain(d)
+		diff.Updated = append(diff.Updated, change)
+	}
+	return nil
+}
+
+func removeDomains(cfg *config.Config, r RemoveDomainRequest, diff *ConfigDiff) error {
+	remove := make(map[string]bool, len(r.Names))
+	for _, name := range r.Names {
+		if d, _ := cfg.FindDomain(name); d == nil && !r.IgnoreMissing {
+			return fmt.Errorf("%s is not running", name)
+		}
+		remove[name] = true
+	}
+
+	cfg.Domains = slices.DeleteFunc(cfg.Domains, func(d config.Domain) bool {
+		if !r.All && !remove[d.Name] {
+			return false
+		}
+		diff.Removed = append(diff.Removed, DomainChange{Name: d.Name, Hosts: d.HostNames()})
+		return true
+	})
+	return nil
+}
+
+func setOptions(cfg *config.Config, r SetOptionsRequest, diff *ConfigDiff) error {
+	if r.LogMode != nil {
+		if err := config.ValidateLogMode(*r.LogMode); err != nil {
+			return err
+		}
+	}
+	if err := r.Headers.Validate(); err != nil {
+		return err
+	}
+
+	if r.Cors != nil && cfg.Cors != *r.Cors {
+		cfg.Cors = *r.Cors
+		diff.Options = append(diff.Options, "cors")
+	}
+	if r.LogMode != nil {
+		if mode := strings.ToLower(strings.TrimSpace(*r.LogMode)); cfg.LogMode != mode {
+			cfg.LogMode = mode
+			diff.Options = append(diff.Options, "log_mode")
+		}
+	}
+	if r.Headers != nil && !reflect.DeepEqual(cfg.Headers, r.Headers) {
+		cfg.Headers = r.Headers
+		diff.Options = append(diff.Options, "headers")
+	}
+	if r.DNS != nil && cfg.DNS != *r.DNS {
+		cfg.DNS = *r.DNS
+		diff.Options = append(diff.Options, "dns")
+	}
+	if r.NoPortForward != nil && cfg.NoPortForward != *r.NoPortForward {
+		cfg.NoPortForward = *r.NoPortForward
+		diff.Options = append(diff.Options, "no_port_forward")
+	}
+	return nil
+}
+
+func handleChange(req Request, srv *proxy.Server) Response {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+
+	diff, err := apply(req, func(cfg *config.Config) error {
+		if err := srv.ApplyConfig(cfg); err != nil {
+			log.Error("Applying %s failed: %v", req.Type, err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return Response{OK: false, Error: err.Error()}
+	}
+	if diff.changed() {
+		data, err := diff.Config.Encode()
+		if err != nil {
+			return Response{OK: false, Error: err.Error()}
+		}
+		if resp := servingConfig(data, diff.Config); !resp.OK {
+			return resp
+		}
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return Response{OK: false, Error: err.Error()}
+	}
+	return Response{OK: true, Data: data}
+}
@@ -0,0 +1,309 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/proxy"
+)
+
+func changeRequest(t *testing.T, msg MessageType, payload any) Request {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return Request{Type: msg, Data: data}
+}
+
+func seedConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	initDaemonStateTestConfig(t)
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save config: %v", err)
+	}
+}
+
+func TestApplyAddDomain(t *testing.T) {
+	seedConfig(t, &config.Config{Domains: []config.Domain{
+		{Name: "api.test", Port: 8080},
+		{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test", "b.myapp.test"}},
+	}})
+
+	cors := true
+	mode := " Minimal "
+	diff, err := Apply(changeRequest(t, MsgAddDomain, AddDomainRequest{
+		Domains: []config.Domain{
+			{Name: "web.test", Port: 5173},
+			{Name: "api.test", Port: 8080},
+			{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test", "c.myapp.test"}},
+		},
+		Options: SetOptionsRequest{Cors: &cors, LogMode: &mode},
+	}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if want := []DomainChange{{Name: "web.test", Hosts: []string{"web.test"}}}; !reflect.DeepEqual(diff.Added, want) {
+		t.Fatalf("added = %+v, want %+v", diff.Added, want)
+	}
+	wantUpdated := []DomainChange{{Name: "*.myapp.test", Hosts: []string{"a.myapp.test", "c.myapp.test"}, StaleHosts: []string{"b.myapp.test"}}}
+	if !reflect.DeepEqual(diff.Updated, wantUpdated) {
+		t.Fatalf("updated = %+v, want %+v", diff.Updated, wantUpdated)
+	}
+	if want := []string{"cors", "log_mode"}; !reflect.DeepEqual(diff.Options, want) {
+		t.Fatalf("options = %v, want %v", diff.Options, want)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Domains) != 3 || !cfg.Cors || cfg.LogMode != "minimal" {
+		t.Fatalf("unexpected saved config %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg, diff.Config) {
+		t.Fatalf("diff config %+v does not match saved config %+v", diff.Config, cfg)
+	}
+}
+
+func TestApplyAddDomainKeepsWildcardHosts(t *testing.T) {
+	seedConfig(t, &config.Config{Domains: []config.Domain{
+		{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test"}},
+	}})
+
+	diff, err := Apply(changeRequest(t, MsgAddDomain, AddDomainRequest{
+		Domains:   []config.Domain{{Name: "*.myapp.test", Port: 4000}},
+		KeepHosts: true,
+	}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(diff.Updated) != 1 || len(diff.Updated[0].StaleHosts) != 0 {
+		t.Fatalf("expected an update without stale hosts, got %+v", diff.Updated)
+	}
+	if d, _ := diff.Config.FindDomain("*.myapp.test"); d == nil || d.Port != 4000 || !reflect.DeepEqual(d.Hosts, []string{"a.myapp.test"}) {
+		t.Fatalf("expected the hosts to be kept, got %+v", d)
+	}
+}
+
+func TestApplyRemoveDomain(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       RemoveDomainRequest
+		removed   []string
+		remaining int
+		wantErr   string
+	}{
+		{"by name", RemoveDomainRequest{Names: []string{"api.test"}}, []string{"api.test"}, 1, ""},
+		{"all", RemoveDomainRequest{All: true}, []string{"api.test", "*.myapp.test"}, 0, ""},
+		{"missing", RemoveDomainRequest{Names: []string{"gone.test"}}, nil, 2, "gone.test is not running"},
+		{"missing ignored", RemoveDomainRequest{Names: []string{"gone.test", "api.test"}, IgnoreMissing: true}, []string{"api.test"}, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seedConfig(t, &config.Config{Domains: []config.Domain{
+				{Name: "api.test", Port: 8080},
+				{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test"}},
+			}})
+
+			diff, err := Apply(changeRequest(t, MsgRemoveDomain, tt.req))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Apply: %v", err)
+			} else {
+				var removed []string
+				for _, c := range diff.Removed {
+					removed = append(removed, c.Name)
+				}
+				if !reflect.DeepEqual(removed, tt.removed) {
+					t.Fatalf("removed = %v, want %v", removed, tt.removed)
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if len(cfg.Domains) != tt.remaining {
+				t.Fatalf("expected %d remaining domains, got %+v", tt.remaining, cfg.Domains)
+			}
+		})
+	}
+}
+
+func TestApplyRemoveDomainReportsHosts(t *testing.T) {
+	seedConfig(t, &config.Config{Domains: []config.Domain{
+		{Name: "*.myapp.test", Port: 3000, Hosts: []string{"a.myapp.test", "b.myapp.test"}},
+	}})
+
+	diff, err := Apply(changeRequest(t, MsgRemoveDomain, RemoveDomainRequest{Names: []string{"*.myapp.test"}}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	want := []DomainChange{{Name: "*.myapp.test", Hosts: []string{"a.myapp.test", "b.myapp.test"}}}
+	if !reflect.DeepEqual(diff.Removed, want) {
+		t.Fatalf("removed = %+v, want %+v", diff.Removed, want)
+	}
+}
+
+func TestApplyRejectsInvalidChanges(t *testing.T) {
+	badMode := "loud"
+	tests := []struct {
+		name string
+		req  func(t *testing.T) Request
+		want string
+	}{
+		{"invalid domain", func(t *testing.T) Request {
+			return changeRequest(t, MsgAddDomain, AddDomainRequest{Domains: []config.Domain{{Name: "bad name.test", Port: 3000}}})
+		}, "invalid"},
+		{"invalid port", func(t *testing.T) Request {
+			return changeRequest(t, MsgAddDomain, AddDomainRequest{Domains: []config.Domain{{Name: "myapp.test", Port: 70000}}})
+		}, "port"},
+		{"duplicate domain", func(t *testing.T) Request {
+			return changeRequest(t, MsgAddDomain, AddDomainRequest{Domains: []config.Domain{{Name: "myapp.test", Port: 3000}, {Name: "myapp.test", Port: 4000}}})
+		}, "duplicate"},
+		{"invalid log mode", func(t *testing.T) Request {
+			return changeRequest(t, MsgSetOptions, SetOptionsRequest{LogMode: &badMode})
+		}, "log mode"},
+		{"malformed payload", func(t *testing.T) Request {
+			return Request{Type: MsgRemoveDomain, Data: json.RawMessage(`{"names":1}`)}
+		}, "invalid remove_domain request"},
+		{"not a change", func(t *testing.T) Request {
+			return Request{Type: MsgStatus}
+		}, "unknown change message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seedConfig(t, &config.Config{Domains: []config.Domain{{Name: "api.test", Port: 8080}}})
+
+			_, err := Apply(tt.req(t))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if len(cfg.Domains) != 1 || cfg.LogMode != "" {
+				t.Fatalf("expected the config to be left alone, got %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestApplyRefusesNamesOutsideCAConstraints(t *testing.T) {
+	seedConfig(t, &config.Config{})
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+
+	_, err := Apply(changeRequest(t, MsgAddDomain, AddDomainRequest{Domains: []config.Domain{{Name: "example.com", Port: 3000}}}))
+	if err == nil || !strings.Contains(err.Error(), "outside the names the slim CA may sign") {
+		t.Fatalf("expected a constraint error, got %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Domains) != 0 {
+		t.Fatalf("expected no domains to be added, got %+v", cfg.Domains)
+	}
+}
+
+func TestHandleChangeLeavesConfigWhenServingFails(t *testing.T) {
+	seedConfig(t, &config.Config{Domains: []config.Domain{{Name: "api.test", Port: 8080}}})
+	before, err := os.ReadFile(config.Path())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// Without a CA no certificate can be issued for the new domain.
+	srv := proxy.NewServer(&config.Config{})
+	resp := handleIPC(changeRequest(t, MsgAddDomain, AddDomainRequest{Domains: []config.Domain{{Name: "web.test", Port: 5173}}}), srv)
+	if resp.OK || !strings.Contains(resp.Error, "CA") {
+		t.Fatalf("expected the change to fail, got %+v", resp)
+	}
+	after, err := os.ReadFile(config.Path())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(after) != string(before) {
+		t.Fatalf("expected config.yaml to be left alone, got:\n%s", after)
+	}
+}
+
+func TestApplyServesSavedConfigAgainWhenServingFails(t *testing.T) {
+	seedConfig(t, &config.Config{Domains: []config.Domain{{Name: "api.test", Port: 8080}}})
+
+	var served [][]string
+	serve := func(cfg *config.Config) error {
+		served = append(served, cfg.DomainNames())
+		if len(served) == 1 {
+			return errors.New("starting DNS resolver: address already in use")
+		}
+		return nil
+	}
+	_, err := apply(changeRequest(t, MsgAddDomain, AddDomainRequest{Domains: []config.Domain{{Name: "web.test", Port: 5173}}}), serve)
+	if err == nil || !strings.Contains(err.Error(), "address already in use") {
+		t.Fatalf("expected the serve error, got %v", err)
+	}
+	want := [][]string{{"api.test", "web.test"}, {"api.test"}}
+	if !reflect.DeepEqual(served, want) {
+		t.Fatalf("served %v, want %v", served, want)
+	}
+}
+
+func TestApplySetOptionsReportsOnlyChanges(t *testing.T) {
+	seedConfig(t, &config.Config{Cors: true})
+
+	cors, dns := true, true
+	diff, err := Apply(changeRequest(t, MsgSetOptions, SetOptionsRequest{Cors: &cors, DNS: &dns}))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if want := []string{"dns"}; !reflect.DeepEqual(diff.Options, want) {
+		t.Fatalf("options = %v, want %v", diff.Options, want)
+	}
+	if !diff.Config.DNS {
+		t.Fatal("expected dns to be enabled")
+	}
+}
+
+func TestConfigDiffRoundTripJSON(t *testing.T) {
+	strip := false
+	diff := ConfigDiff{
+		Added: []DomainChange{{Name: "myapp.test", Hosts: []string{"myapp.test"}}},
+		Config: &config.Config{
+			Domains: []config.Domain{{
+				Name:    "myapp.test",
+				Port:    3000,
+				TLS:     &config.UpstreamTLS{Insecure: true},
+				Headers: &config.Headers{Request: config.HeaderRules{Set: map[string]string{"X-A": "b"}}},
+				Routes:  []config.Route{{Path: "/api", Port: 8080, StripPrefix: &strip, Rewrite: &config.PathRewrite{Pattern: "^/v1", Replace: "/v2"}}},
+			}},
+			LogMode: "minimal",
+		},
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var got ConfigDiff
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(got, diff) {
+		t.Fatalf("round trip changed the diff:\n got %+v\nwant %+v", got, diff)
+	}
+}
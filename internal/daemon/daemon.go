@@ -3,6 +3,7 @@ package daemon
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -14,7 +15,9 @@ import (
 
 	godaemon "github.com/sevlyar/go-daemon"
 
+	"github.com/kamranahmedse/slim/internal/cert"
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 	"github.com/kamranahmedse/slim/internal/log"
 	"github.com/kamranahmedse/slim/internal/proxy"
 )
@@ -41,12 +44,19 @@ func RunDetached() error {
 		return err
 	}
 
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("daemonize: %w", err)
+	}
+	// The child runs the hidden daemon command rather than whatever command
+	// forked it, which may be stop or upgrade restarting an older daemon.
 	daemonCtx := &godaemon.Context{
 		PidFileName: "",
 		PidFilePerm: 0644,
 		LogFileName: "",
 		WorkDir:     "./",
 		Umask:       027,
+		Args:        []string{exe, "daemon"},
 	}
 
 	child, err := daemonCtx.Reborn()
@@ -60,15 +70,31 @@ func RunDetached() error {
 
 	defer func() { _ = daemonCtx.Release() }()
 	if err := run(); err != nil {
-		errPath := config.Dir() + "/daemon.err"
-		_ = os.WriteFile(errPath, []byte(err.Error()+"\n"), 0644)
+		_ = os.WriteFile(errPath(), []byte(err.Error()+"\n"), 0644)
 		os.Exit(1)
 	}
 	return nil
 }
 
+// RunForeground runs the daemon in this process until it is shut down, for
+// supervisors such as systemd that manage its lifetime.
+func RunForeground() error {
+	if err := os.MkdirAll(config.Dir(), 0755); err != nil {
+		return err
+	}
+	if err := run(); err != nil {
+		_ = os.WriteFile(errPath(), []byte(err.Error()+"\n"), 0644)
+		return err
+	}
+	return nil
+}
+
+func errPath() string {
+	return config.Dir() + "/daemon.err"
+}
+
 func WaitForDaemon() error {
-	errPath := config.Dir() + "/daemon.err"
+	errPath := errPath()
 	_ = os.Truncate(errPath, 0)
 
 	for i := 0; i < 50; i++ {
@@ -84,11 +110,26 @@ func WaitForDaemon() error {
 	return fmt.Errorf("daemon failed to start within 5 seconds")
 }
 
-func run() error {
+func run() (err error) {
+	if err := log.SetDaemonOutput(config.DaemonLogPath()); err != nil {
+		return err
+	}
+	defer log.CloseDaemonOutput()
+	log.Info("Daemon starting (version %s, pid %d)", Version, os.Getpid())
+	defer func() {
+		if err != nil {
+			log.Error("Daemon exited: %v", err)
+			return
+		}
+		log.Info("Daemon stopped")
+	}()
+
+	data, _ := os.ReadFile(config.Path())
 	cfg, err := config.Load()
 	if err != nil {
 		return err
 	}
+	markConfigLoaded(data)
 
 	if err := log.SetOutput(config.LogPath(), cfg.EffectiveLogMode()); err != nil {
 		return fmt.Errorf("opening log file: %w", err)
@@ -105,6 +146,14 @@ func run() error {
 	}
 	go ipc.Serve()
 
+	stopWatch := make(chan struct{})
+	go watchConfig(config.Path(), stopWatch, func() error {
+		if resp := handleReload(srv); !resp.OK {
+			return errors.New(resp.Error)
+		}
+		return nil
+	})
+
 	if err := os.WriteFile(config.PidPath(), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
 		return fmt.Errorf("writing pid file: %w", err)
 	}
@@ -112,6 +161,7 @@ func run() error {
 	var cleanupOnce sync.Once
 	cleanup := func() {
 		cleanupOnce.Do(func() {
+			close(stopWatch)
 			ipc.Close()
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
@@ -148,6 +198,21 @@ func handleIPC(req Request, srv *proxy.Server) Response {
 	case MsgReload:
 		return handleReload(srv)
 
+	case MsgReloadCerts:
+		return handleReloadCerts(srv)
+
+	case MsgUnlockCA:
+		return handleUnlockCA(req.Data, srv)
+
+	case MsgReplay:
+		return handleReplay(req.Data, srv)
+
+	case MsgAddDomain, MsgRemoveDomain, MsgSetOptions:
+		return handleChange(req, srv)
+
+	case MsgPublish:
+		return handlePublish(req.Data)
+
 	default:
 		return Response{OK: false, Error: fmt.Sprintf("unknown message type: %s", req.Type)}
 	}
@@ -159,24 +224,23 @@ func handleStatus() Response {
 		return Response{OK: false, Error: err.Error()}
 	}
 
-	var allPorts []int
+	var targets []config.Target
 	domains := make([]DomainInfo, len(cfg.Domains))
 	for i, d := range cfg.Domains {
-		domains[i] = DomainInfo{Name: d.Name, Port: d.Port}
-		allPorts = append(allPorts, d.Port)
+		domains[i] = DomainInfo{Name: d.Name, Port: d.Port, Ports: d.Ports, Upstream: d.Upstream, Socket: d.Socket, Dir: d.Dir}
 		for _, r := range d.Routes {
-			domains[i].Routes = append(domains[i].Routes, RouteInfo{Path: r.Path, Port: r.Port})
-			allPorts = append(allPorts, r.Port)
+			domains[i].Routes = append(domains[i].Routes, RouteInfo{Path: r.Path, Port: r.Port, Ports: r.Ports, Upstream: r.Upstream, Socket: r.Socket, Dir: r.Dir})
 		}
+		targets = append(targets, d.Targets()...)
 	}
 
-	health := proxy.CheckUpstreams(allPorts)
+	health := proxy.CheckTargets(targets)
 	idx := 0
 	for i := range domains {
-		domains[i].Healthy = health[idx]
+		domains[i].Healthy, domains[i].Targets = targetHealth(targets[idx], health[idx])
 		idx++
 		for j := range domains[i].Routes {
-			domains[i].Routes[j].Healthy = health[idx]
+			domains[i].Routes[j].Healthy, domains[i].Routes[j].Targets = targetHealth(targets[idx], health[idx])
 			idx++
 		}
 	}
@@ -193,13 +257,96 @@ func handleStatus() Response {
 	return Response{OK: true, Data: data}
 }
 
+// targetHealth reports a target as healthy when any of its upstreams is,
+// listing each upstream separately for balanced targets.
+func targetHealth(t config.Target, health []bool) (bool, []TargetHealth) {
+	healthy := false
+	var targets []TargetHealth
+	for i, e := range t.Expand() {
+		healthy = healthy || health[i]
+		if len(t.Ports) > 0 {
+			targets = append(targets, TargetHealth{Target: e.String(), Healthy: health[i]})
+		}
+	}
+	return healthy, targets
+}
+
+func handleReplay(raw json.RawMessage, srv *proxy.Server) Response {
+	var req ReplayRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return Response{OK: false, Error: fmt.Sprintf("invalid replay request: %v", err)}
+	}
+	result, err := srv.Replay(req.ID, req.Options)
+	if err != nil {
+		return Response{OK: false, Error: err.Error()}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Response{OK: false, Error: err.Error()}
+	}
+	return Response{OK: true, Data: data}
+}
+
+// handleReload serves the config file as it is now. It waits for a change
+// in progress, so an older file can't be served over a newer one.
 func handleReload(srv *proxy.Server) Response {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+	data, _ := os.ReadFile(config.Path())
 	cfg, err := srv.ReloadConfig()
 	if err != nil {
+		log.Error("Reloading config failed: %v", err)
 		return Response{OK: false, Error: err.Error()}
 	}
+	return servingConfig(data, cfg)
+}
+
+// servingConfig finishes switching to cfg, which the proxy already serves
+// and data holds as saved.
+func servingConfig(data []byte, cfg *config.Config) Response {
+	markConfigLoaded(data)
 	if err := log.SetOutput(config.LogPath(), cfg.EffectiveLogMode()); err != nil {
+		log.Error("Reopening access log failed: %v", err)
+		return Response{OK: false, Error: err.Error()}
+	}
+	log.Info("Reloaded config: %d domains", len(cfg.Domains))
+	event.Publish(event.ConfigReloaded, event.Config{Domains: cfg.DomainNames()})
+	return Response{OK: true}
+}
+
+func handleReloadCerts(srv *proxy.Server) Response {
+	n, err := srv.ReloadCertificates()
+	if err != nil {
+		log.Error("Reloading certificates failed: %v", err)
 		return Response{OK: false, Error: err.Error()}
 	}
+	log.Info("Reloaded %d certificates", n)
+	return Response{OK: true}
+}
+
+func handleUnlockCA(raw json.RawMessage, srv *proxy.Server) Response {
+	var req UnlockCARequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return Response{OK: false, Error: fmt.Sprintf("invalid unlock_ca request: %v", err)}
+	}
+	if err := cert.UnlockCA(req.Passphrase); err != nil {
+		return Response{OK: false, Error: err.Error()}
+	}
+	log.Info("CA key unlocked")
+
+	// Certificates kept past their renewal while the key was locked are
+	// renewed by the reload.
+	return handleReload(srv)
+}
+
+func handlePublish(raw json.RawMessage) Response {
+	var e event.Event
+	if err := json.Unmarshal(raw, &e); err != nil || e.Type == "" {
+		return Response{OK: false, Error: "invalid event"}
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	event.Emit(e)
 	return Response{OK: true}
 }
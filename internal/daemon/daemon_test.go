@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"net"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/kamranahmedse/slim/internal/cert"
 	"github.com/kamranahmedse/slim/internal/config"
 	"github.com/kamranahmedse/slim/internal/proxy"
 )
@@ -20,6 +23,66 @@ func TestHandleIPCUnknownMessage(t *testing.T) {
 	}
 }
 
+func TestHandleUnlockCA(t *testing.T) {
+	initDaemonStateTestConfig(t)
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+	if err := cert.EncryptCAKey("hunter2"); err != nil {
+		t.Fatalf("EncryptCAKey: %v", err)
+	}
+	srv := proxy.NewServer(&config.Config{})
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"malformed", `{"passphrase":1}`, "invalid unlock_ca request"},
+		{"wrong passphrase", `{"passphrase":"hunter3"}`, "wrong passphrase"},
+		{"unlocked", `{"passphrase":"hunter2"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := handleIPC(Request{Type: MsgUnlockCA, Data: json.RawMessage(tt.data)}, srv)
+			if tt.want == "" {
+				if !resp.OK {
+					t.Fatalf("expected the CA to unlock, got %+v", resp)
+				}
+				return
+			}
+			if resp.OK || !strings.Contains(resp.Error, tt.want) {
+				t.Fatalf("expected error containing %q, got %+v", tt.want, resp)
+			}
+		})
+	}
+}
+
+func TestHandleReloadWaitsForChanges(t *testing.T) {
+	initDaemonStateTestConfig(t)
+	srv := proxy.NewServer(&config.Config{})
+
+	changeMu.Lock()
+	done := make(chan Response, 1)
+	go func() { done <- handleIPC(Request{Type: MsgReload}, srv) }()
+	select {
+	case resp := <-done:
+		changeMu.Unlock()
+		t.Fatalf("expected the reload to wait for the change in progress, got %+v", resp)
+	case <-time.After(50 * time.Millisecond):
+	}
+	changeMu.Unlock()
+
+	select {
+	case resp := <-done:
+		if !resp.OK {
+			t.Fatalf("expected the reload to succeed, got %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload did not finish after the change")
+	}
+}
+
 func TestHandleStatusIncludesDomainHealth(t *testing.T) {
 	initDaemonStateTestConfig(t)
 
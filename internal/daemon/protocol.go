@@ -1,6 +1,21 @@
 package daemon
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
+	"github.com/kamranahmedse/slim/internal/proxy"
+)
+
+// ProtocolVersion changes whenever messages change in a way that another
+// build of the CLI or daemon would not understand.
+const ProtocolVersion = 4
+
+// Version is the slim build of this process. The CLI sets it at startup so
+// a daemon forked from it reports the same build.
+var Version = "dev"
 
 type MessageType string
 
@@ -8,17 +23,64 @@ const (
 	MsgShutdown MessageType = "shutdown"
 	MsgStatus   MessageType = "status"
 	MsgReload   MessageType = "reload"
+	MsgReplay   MessageType = "replay"
+	MsgVersion  MessageType = "version"
+
+	// MsgReloadCerts reloads the certificates being served from disk,
+	// after a CA rotation reissued them.
+	MsgReloadCerts MessageType = "reload_certs"
+	// MsgUnlockCA hands the daemon the passphrase of an encrypted CA key,
+	// so it can issue certificates for the rest of its run.
+	MsgUnlockCA MessageType = "unlock_ca"
+
+	MsgAddDomain    MessageType = "add_domain"
+	MsgRemoveDomain MessageType = "remove_domain"
+	MsgSetOptions   MessageType = "set_options"
+
+	// MsgSubscribe keeps the connection open: after the response, the
+	// daemon writes one JSON event per line until either side hangs up.
+	MsgSubscribe MessageType = "subscribe"
+	// MsgPublish hands the daemon an event from another process, such as
+	// slim share, to pass on to subscribers.
+	MsgPublish MessageType = "publish"
 )
 
+// Request and Response carry the protocol and build version of the side
+// that sent them. Daemons from before versioning leave both empty.
 type Request struct {
-	Type MessageType     `json:"type"`
-	Data json.RawMessage `json:"data,omitempty"`
+	Type     MessageType     `json:"type"`
+	Protocol int             `json:"protocol,omitempty"`
+	Version  string          `json:"version,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
 }
 
 type Response struct {
-	OK    bool            `json:"ok"`
-	Error string          `json:"error,omitempty"`
-	Data  json.RawMessage `json:"data,omitempty"`
+	OK       bool            `json:"ok"`
+	Error    string          `json:"error,omitempty"`
+	Protocol int             `json:"protocol,omitempty"`
+	Version  string          `json:"version,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// VersionInfo is what a running daemon reports about its build.
+type VersionInfo struct {
+	Protocol int
+	Version  string
+}
+
+// Current reports whether the daemon runs the same build as this process.
+func (v VersionInfo) Current() bool {
+	return v.Protocol == ProtocolVersion && v.Version == Version
+}
+
+func (v VersionInfo) String() string {
+	if v.Version == "" {
+		return "an older version"
+	}
+	if v.Protocol != ProtocolVersion {
+		return fmt.Sprintf("%s (protocol %d)", v.Version, v.Protocol)
+	}
+	return v.Version
 }
 
 type StatusData struct {
@@ -27,15 +89,90 @@ type StatusData struct {
 	Domains []DomainInfo `json:"domains"`
 }
 
-type RouteInfo struct {
-	Path    string `json:"path"`
-	Port    int    `json:"port"`
+// TargetHealth is the status of one upstream of a balanced domain or route.
+type TargetHealth struct {
+	Target  string `json:"target"`
 	Healthy bool   `json:"healthy"`
 }
 
+type RouteInfo struct {
+	Path     string         `json:"path"`
+	Port     int            `json:"port,omitempty"`
+	Ports    []int          `json:"ports,omitempty"`
+	Upstream string         `json:"upstream,omitempty"`
+	Socket   string         `json:"socket,omitempty"`
+	Dir      string         `json:"dir,omitempty"`
+	Healthy  bool           `json:"healthy"`
+	Targets  []TargetHealth `json:"targets,omitempty"`
+}
+
 type DomainInfo struct {
-	Name    string      `json:"name"`
-	Port    int         `json:"port"`
-	Healthy bool        `json:"healthy"`
-	Routes  []RouteInfo `json:"routes,omitempty"`
+	Name     string         `json:"name"`
+	Port     int            `json:"port,omitempty"`
+	Ports    []int          `json:"ports,omitempty"`
+	Upstream string         `json:"upstream,omitempty"`
+	Socket   string         `json:"socket,omitempty"`
+	Dir      string         `json:"dir,omitempty"`
+	Healthy  bool           `json:"healthy"`
+	Targets  []TargetHealth `json:"targets,omitempty"`
+	Routes   []RouteInfo    `json:"routes,omitempty"`
+}
+
+type ReplayRequest struct {
+	ID      uint64              `json:"id"`
+	Options proxy.ReplayOptions `json:"options"`
+}
+
+type UnlockCARequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// SubscribeRequest picks the event types to stream; none means all of them.
+type SubscribeRequest struct {
+	Types []event.Type `json:"types,omitempty"`
+}
+
+// AddDomainRequest adds domains, replacing any with the same name, and sets
+// Options in the same step. With KeepHosts, a wildcard domain sent without
+// hosts keeps the ones it already has.
+type AddDomainRequest struct {
+	Domains   []config.Domain   `json:"domains"`
+	KeepHosts bool              `json:"keep_hosts,omitempty"`
+	Options   SetOptionsRequest `json:"options"`
+}
+
+// RemoveDomainRequest removes the named domains, or every domain with All.
+// Names that are not configured fail the request unless IgnoreMissing is set.
+type RemoveDomainRequest struct {
+	Names         []string `json:"names,omitempty"`
+	All           bool     `json:"all,omitempty"`
+	IgnoreMissing bool     `json:"ignore_missing,omitempty"`
+}
+
+// SetOptionsRequest changes global options. Nil fields are left alone.
+type SetOptionsRequest struct {
+	Cors          *bool           `json:"cors,omitempty"`
+	LogMode       *string         `json:"log_mode,omitempty"`
+	Headers       *config.Headers `json:"headers,omitempty"`
+	DNS           *bool           `json:"dns,omitempty"`
+	NoPortForward *bool           `json:"no_port_forward,omitempty"`
+}
+
+// DomainChange is a domain touched by a change. Hosts are the names it
+// resolves after the change, or before it when removed. StaleHosts are
+// names an updated domain no longer resolves.
+type DomainChange struct {
+	Name       string   `json:"name"`
+	Hosts      []string `json:"hosts,omitempty"`
+	StaleHosts []string `json:"stale_hosts,omitempty"`
+}
+
+// ConfigDiff is the result of a change message: what changed, and the
+// config as saved.
+type ConfigDiff struct {
+	Added   []DomainChange `json:"added,omitempty"`
+	Updated []DomainChange `json:"updated,omitempty"`
+	Removed []DomainChange `json:"removed,omitempty"`
+	Options []string       `json:"options,omitempty"`
+	Config  *config.Config `json:"config"`
 }
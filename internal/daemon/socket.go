@@ -3,18 +3,23 @@ package daemon
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 	"github.com/kamranahmedse/slim/internal/httperr"
 )
 
 type IPCServer struct {
-	listener net.Listener
-	handler  func(Request) Response
+	listener  net.Listener
+	handler   func(Request) Response
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 func NewIPCServer(handler func(Request) Response) (*IPCServer, error) {
@@ -30,7 +35,7 @@ func NewIPCServer(handler func(Request) Response) (*IPCServer, error) {
 		return nil, fmt.Errorf("listening on socket: %w", err)
 	}
 
-	return &IPCServer{listener: ln, handler: handler}, nil
+	return &IPCServer{listener: ln, handler: handler, done: make(chan struct{})}, nil
 }
 
 func (s *IPCServer) Serve() {
@@ -50,23 +55,82 @@ func (s *IPCServer) handleConn(conn net.Conn) {
 	var req Request
 	if err := json.NewDecoder(conn).Decode(&req); err != nil {
 		resp := Response{OK: false, Error: err.Error()}
-		_ = json.NewEncoder(conn).Encode(resp)
+		_ = json.NewEncoder(conn).Encode(stamp(resp))
+		return
+	}
+
+	switch req.Type {
+	case MsgSubscribe:
+		s.stream(conn, req)
+		return
+	case MsgVersion:
+		_ = json.NewEncoder(conn).Encode(stamp(Response{OK: true}))
 		return
 	}
 
 	resp := s.handler(req)
-	_ = json.NewEncoder(conn).Encode(resp)
+	_ = json.NewEncoder(conn).Encode(stamp(resp))
+}
+
+func stamp(resp Response) Response {
+	resp.Protocol, resp.Version = ProtocolVersion, Version
+	return resp
+}
+
+// stream writes events to a subscriber until it hangs up or the server
+// closes. Events a subscriber is too slow to take are dropped.
+func (s *IPCServer) stream(conn net.Conn, req Request) {
+	enc := json.NewEncoder(conn)
+	var sr SubscribeRequest
+	if len(req.Data) > 0 {
+		if err := json.Unmarshal(req.Data, &sr); err != nil {
+			_ = enc.Encode(stamp(Response{OK: false, Error: fmt.Sprintf("invalid subscribe request: %v", err)}))
+			return
+		}
+	}
+
+	sub := event.Subscribe(sr.Types...)
+	defer sub.Close()
+
+	if err := enc.Encode(stamp(Response{OK: true})); err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	gone := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case e := <-sub.Events():
+			_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-s.done:
+			return
+		}
+	}
 }
 
 func (s *IPCServer) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
 	s.listener.Close()
 	os.Remove(config.SocketPath())
 }
 
 func SendIPC(req Request) (*Response, error) {
-	conn, err := net.DialTimeout("unix", config.SocketPath(), 5*time.Second)
+	if req.Protocol == 0 {
+		req.Protocol, req.Version = ProtocolVersion, Version
+	}
+	conn, err := dialDaemon()
 	if err != nil {
-		return nil, httperr.Wrap("connecting to daemon (is slim running?)", err)
+		return nil, err
 	}
 	defer conn.Close()
 
@@ -83,3 +147,74 @@ func SendIPC(req Request) (*Response, error) {
 
 	return &resp, nil
 }
+
+// EventStream is an open subscription to daemon events.
+type EventStream struct {
+	conn net.Conn
+	dec  *json.Decoder
+}
+
+// Subscribe streams daemon events of the given types, or of every type
+// when none are given.
+func Subscribe(types ...event.Type) (*EventStream, error) {
+	data, err := json.Marshal(SubscribeRequest{Types: types})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialDaemon()
+	if err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	req := Request{Type: MsgSubscribe, Protocol: ProtocolVersion, Version: Version, Data: data}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	dec := json.NewDecoder(conn)
+	var resp Response
+	if err := dec.Decode(&resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if !resp.OK {
+		conn.Close()
+		return nil, fmt.Errorf("subscribing to events: %s", resp.Error)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return &EventStream{conn: conn, dec: dec}, nil
+}
+
+// Next blocks until the next event arrives. It returns io.EOF once the
+// daemon goes away.
+func (s *EventStream) Next() (event.Event, error) {
+	var e event.Event
+	if err := s.dec.Decode(&e); err != nil {
+		return event.Event{}, err
+	}
+	return e, nil
+}
+
+func (s *EventStream) Close() error {
+	return s.conn.Close()
+}
+
+// Handshake asks the daemon which build it runs. A daemon from before
+// versioning does not know the message, and shows up with Protocol 0.
+func Handshake(sendIPC func(Request) (*Response, error)) (VersionInfo, error) {
+	resp, err := sendIPC(Request{Type: MsgVersion})
+	if err != nil {
+		return VersionInfo{}, err
+	}
+	return VersionInfo{Protocol: resp.Protocol, Version: resp.Version}, nil
+}
+
+func dialDaemon() (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", config.SocketPath(), 5*time.Second)
+	if err != nil {
+		return nil, httperr.Wrap("connecting to daemon (is slim running?)", err)
+	}
+	return conn, nil
+}
@@ -2,6 +2,8 @@ package daemon
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net"
 	"os"
 	"strings"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 )
 
 func TestIPCServerRoundTrip(t *testing.T) {
@@ -104,6 +107,74 @@ func TestIPCServerCloseRemovesSocket(t *testing.T) {
 	}
 }
 
+func TestSubscribeStreamsEvents(t *testing.T) {
+	initDaemonTestConfig(t)
+
+	srv, err := NewIPCServer(func(req Request) Response { return handlePublish(req.Data) })
+	if err != nil {
+		t.Fatalf("NewIPCServer: %v", err)
+	}
+	defer srv.Close()
+	go srv.Serve()
+
+	stream, err := Subscribe(event.RequestCompleted, event.TunnelState)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer stream.Close()
+
+	event.Publish(event.HealthChanged, event.Health{Upstream: "3000"})
+	event.Publish(event.RequestCompleted, event.Request{Domain: "myapp.test", Status: 204})
+
+	e, err := stream.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	var r event.Request
+	if e.Type != event.RequestCompleted || json.Unmarshal(e.Data, &r) != nil || r.Status != 204 {
+		t.Fatalf("expected the request event, got %+v", e)
+	}
+
+	relayed, err := event.New(event.TunnelState, event.Tunnel{State: "connected"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	data, err := json.Marshal(relayed)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if resp, err := SendIPC(Request{Type: MsgPublish, Data: data}); err != nil || !resp.OK {
+		t.Fatalf("publish: %+v, %v", resp, err)
+	}
+	if e, err := stream.Next(); err != nil || e.Type != event.TunnelState {
+		t.Fatalf("expected the relayed tunnel event, got %+v (%v)", e, err)
+	}
+
+	srv.Close()
+	if _, err := stream.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF once the server closes, got %v", err)
+	}
+}
+
+func TestSubscribeRejectsInvalidRequest(t *testing.T) {
+	initDaemonTestConfig(t)
+
+	srv, err := NewIPCServer(func(req Request) Response { return Response{OK: true} })
+	if err != nil {
+		t.Fatalf("NewIPCServer: %v", err)
+	}
+	defer srv.Close()
+	go srv.Serve()
+
+	resp, err := SendIPC(Request{Type: MsgSubscribe, Data: json.RawMessage(`{"types":1}`)})
+	if err != nil {
+		t.Fatalf("SendIPC: %v", err)
+	}
+	if resp.OK || !strings.Contains(resp.Error, "invalid subscribe request") {
+		t.Fatalf("expected an invalid request error, got %+v", resp)
+	}
+}
+
 func initDaemonTestConfig(t *testing.T) {
 	t.Helper()
 	home, err := os.MkdirTemp("", "slim-daemon-test-")
@@ -118,3 +189,34 @@ func initDaemonTestConfig(t *testing.T) {
 		t.Fatalf("config.Init: %v", err)
 	}
 }
+
+func TestHandshakeReportsDaemonBuild(t *testing.T) {
+	initDaemonTestConfig(t)
+
+	srv, err := NewIPCServer(func(req Request) Response {
+		if req.Protocol != ProtocolVersion || req.Version != Version {
+			return Response{OK: false, Error: "request was not stamped"}
+		}
+		return Response{OK: true}
+	})
+	if err != nil {
+		t.Fatalf("NewIPCServer: %v", err)
+	}
+	defer srv.Close()
+	go srv.Serve()
+
+	info, err := Handshake(SendIPC)
+	if err != nil {
+		t.Fatalf("Handshake: %v", err)
+	}
+	if !info.Current() {
+		t.Fatalf("expected the daemon to match this build, got %+v", info)
+	}
+	resp, err := SendIPC(Request{Type: MsgStatus})
+	if err != nil || !resp.OK {
+		t.Fatalf("expected a stamped request to be accepted, got %+v (%v)", resp, err)
+	}
+	if resp.Protocol != ProtocolVersion || resp.Version != Version {
+		t.Fatalf("expected the response to carry the daemon build, got %+v", resp)
+	}
+}
@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/log"
+)
+
+// Edits to the config file are applied once they have settled for
+// watchDebounce, so an editor's save never gets half read. Without
+// inotify the file is polled every watchPollInterval.
+var (
+	watchDebounce     = 300 * time.Millisecond
+	watchPollInterval = time.Second
+	notifyChangesFn   = notifyChanges
+)
+
+// loadedSum is the checksum of the config file the proxy last loaded. The
+// watcher skips content matching it, which covers the daemon's own writes.
+var (
+	loadedMu  sync.Mutex
+	loadedSum [sha256.Size]byte
+)
+
+func markConfigLoaded(data []byte) {
+	loadedMu.Lock()
+	loadedSum = sha256.Sum256(data)
+	loadedMu.Unlock()
+}
+
+func configLoaded(sum [sha256.Size]byte) bool {
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	return sum == loadedSum
+}
+
+// watchConfig calls reload when the content of path changes, until stop is
+// closed. A failed reload is logged and the daemon keeps serving the last
+// good config until the next edit.
+func watchConfig(path string, stop <-chan struct{}, reload func() error) {
+	changes, err := notifyChangesFn(path, stop)
+	if err != nil {
+		changes = pollChanges(path, watchPollInterval, stop)
+	}
+
+	var last [sha256.Size]byte
+	if data, err := os.ReadFile(path); err == nil {
+		last = sha256.Sum256(data)
+	}
+
+	settled := time.NewTimer(watchDebounce)
+	settled.Stop()
+	defer settled.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case _, ok := <-changes:
+			if !ok {
+				changes = pollChanges(path, watchPollInterval, stop)
+				continue
+			}
+			settled.Reset(watchDebounce)
+		case <-settled.C:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					log.Error("Reading %s: %v", path, err)
+				}
+				continue
+			}
+			sum := sha256.Sum256(data)
+			if sum == last {
+				continue
+			}
+			last = sum
+			if configLoaded(sum) {
+				continue
+			}
+			log.Info("%s changed", path)
+			if err := reload(); err != nil {
+				log.Warn("Ignoring the edit, still serving the last good config")
+			}
+		}
+	}
+}
+
+// pollChanges reports a change whenever the size or modification time of
+// path differs from the previous check.
+func pollChanges(path string, interval time.Duration, stop <-chan struct{}) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		prev, _ := os.Stat(path)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			cur, _ := os.Stat(path)
+			if !sameFileState(prev, cur) {
+				notify(changes)
+			}
+			prev = cur
+		}
+	}()
+	return changes
+}
+
+func sameFileState(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+func notify(changes chan struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
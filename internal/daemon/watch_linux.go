@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// notifyChanges watches the directory holding path with inotify, since
+// editors often save by renaming a new file over the old one.
+func notifyChanges(path string, stop <-chan struct{}) (<-chan struct{}, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	dir, name := filepath.Split(path)
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("watching %s: %w", dir, err)
+	}
+	// A non-blocking descriptor goes through the runtime poller, so closing
+	// the file unblocks the pending read.
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		<-stop
+		_ = f.Close()
+	}()
+	go func() {
+		defer close(changes)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				mask := binary.NativeEndian.Uint32(buf[off+4:])
+				size := int(binary.NativeEndian.Uint32(buf[off+12:]))
+				start := off + syscall.SizeofInotifyEvent
+				off = start + size
+				if off > n {
+					break
+				}
+				if mask&syscall.IN_Q_OVERFLOW != 0 || string(bytes.TrimRight(buf[start:off], "\x00")) == name {
+					notify(changes)
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
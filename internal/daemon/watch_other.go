@@ -0,0 +1,9 @@
+//go:build !linux
+
+package daemon
+
+import "errors"
+
+func notifyChanges(string, <-chan struct{}) (<-chan struct{}, error) {
+	return nil, errors.New("file notifications are only used on Linux")
+}
@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func setupWatchTest(t *testing.T, polling bool) (string, chan struct{}) {
+	t.Helper()
+	if !polling && runtime.GOOS != "linux" {
+		t.Skip("inotify is only used on Linux")
+	}
+	prevDebounce, prevPoll, prevNotify := watchDebounce, watchPollInterval, notifyChangesFn
+	t.Cleanup(func() {
+		watchDebounce, watchPollInterval, notifyChangesFn = prevDebounce, prevPoll, prevNotify
+		markConfigLoaded(nil)
+	})
+	watchDebounce = 100 * time.Millisecond
+	watchPollInterval = 20 * time.Millisecond
+	if polling {
+		notifyChangesFn = func(string, <-chan struct{}) (<-chan struct{}, error) {
+			return nil, errors.New("unavailable")
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeWatched(t, path, "domains: []\n")
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	return path, stop
+}
+
+func writeWatched(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func expectReloads(t *testing.T, reloads <-chan struct{}, want int) {
+	t.Helper()
+	got := 0
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case <-reloads:
+			got++
+			if got > want {
+				t.Fatalf("expected %d reloads, got more", want)
+			}
+		case <-timeout:
+			if got != want {
+				t.Fatalf("expected %d reloads, got %d", want, got)
+			}
+			return
+		}
+	}
+}
+
+func TestWatchConfigReloadsOnceAfterEditsSettle(t *testing.T) {
+	for _, polling := range []bool{false, true} {
+		name := "inotify"
+		if polling {
+			name = "polling"
+		}
+		t.Run(name, func(t *testing.T) {
+			path, stop := setupWatchTest(t, polling)
+			reloads := make(chan struct{}, 10)
+			go watchConfig(path, stop, func() error {
+				reloads <- struct{}{}
+				return nil
+			})
+			time.Sleep(50 * time.Millisecond)
+
+			writeWatched(t, path, "domains:\n")
+			writeWatched(t, path, "domains:\n  - name: a.test\n")
+			tmp := path + ".swp"
+			writeWatched(t, tmp, "domains:\n  - name: a.test\n    port: 3000\n")
+			if err := os.Rename(tmp, path); err != nil {
+				t.Fatalf("Rename: %v", err)
+			}
+			expectReloads(t, reloads, 1)
+
+			// Touching the file without changing it is not an edit.
+			writeWatched(t, path, "domains:\n  - name: a.test\n    port: 3000\n")
+			expectReloads(t, reloads, 0)
+		})
+	}
+}
+
+func TestWatchConfigSkipsLoadedContent(t *testing.T) {
+	path, stop := setupWatchTest(t, true)
+	reloads := make(chan struct{}, 10)
+	go watchConfig(path, stop, func() error {
+		reloads <- struct{}{}
+		return nil
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	content := "domains:\n  - name: a.test\n    port: 3000\n"
+	markConfigLoaded([]byte(content))
+	writeWatched(t, path, content)
+	expectReloads(t, reloads, 0)
+}
+
+func TestWatchConfigKeepsWatchingAfterFailedReload(t *testing.T) {
+	path, stop := setupWatchTest(t, true)
+	reloads := make(chan struct{}, 10)
+	fail := true
+	go watchConfig(path, stop, func() error {
+		reloads <- struct{}{}
+		if fail {
+			fail = false
+			return errors.New("invalid config")
+		}
+		return nil
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	writeWatched(t, path, "domains: [\n")
+	expectReloads(t, reloads, 1)
+	writeWatched(t, path, "domains: []\n# fixed\n")
+	expectReloads(t, reloads, 1)
+}
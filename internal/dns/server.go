@@ -0,0 +1,231 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Addr is where the resolver listens. It is kept off port 53 so it never
+// clashes with the system resolver, which forwards slim's zones to it.
+var Addr = fmt.Sprintf("127.0.0.1:%d", config.ProxyDNSPort)
+
+const ttl = 5
+
+var (
+	loopbackV4 = [4]byte{127, 0, 0, 1}
+	loopbackV6 = [16]byte{15: 1}
+)
+
+// Server answers queries for the zones of configured domains. Every name at
+// or under a configured domain resolves to loopback, other names in those
+// zones get NXDOMAIN and everything else is refused.
+type Server struct {
+	mu      sync.RWMutex
+	domains []string
+	zones   []string
+
+	lnMu sync.Mutex
+	udp  net.PacketConn
+	tcp  net.Listener
+}
+
+func NewServer() *Server {
+	return &Server{}
+}
+
+// SetDomains sets the names the server answers for and the zones it is
+// authoritative in.
+func (s *Server) SetDomains(domains, zones []string) {
+	s.mu.Lock()
+	s.domains = append([]string(nil), domains...)
+	s.zones = append([]string(nil), zones...)
+	s.mu.Unlock()
+}
+
+func (s *Server) Listening() bool {
+	s.lnMu.Lock()
+	defer s.lnMu.Unlock()
+	return s.udp != nil
+}
+
+// Listen starts serving UDP and TCP on addr in the background.
+func (s *Server) Listen(addr string) error {
+	s.lnMu.Lock()
+	defer s.lnMu.Unlock()
+	if s.udp != nil {
+		return nil
+	}
+
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on udp %s: %w", addr, err)
+	}
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		udp.Close()
+		return fmt.Errorf("listening on tcp %s: %w", addr, err)
+	}
+	s.udp, s.tcp = udp, tcp
+
+	go s.serveUDP(udp)
+	go s.serveTCP(tcp)
+	return nil
+}
+
+func (s *Server) Close() error {
+	s.lnMu.Lock()
+	defer s.lnMu.Unlock()
+	if s.udp == nil {
+		return nil
+	}
+	err := errors.Join(s.udp.Close(), s.tcp.Close())
+	s.udp, s.tcp = nil, nil
+	return err
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if resp, err := s.answer(buf[:n]); err == nil {
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *Server) serveTCP(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go s.handleTCP(conn)
+	}
+}
+
+// handleTCP serves length-prefixed queries until the client goes away.
+func (s *Server) handleTCP(conn net.Conn) {
+	defer conn.Close()
+	for {
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+		var size uint16
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		query := make([]byte, size)
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		resp, err := s.answer(query)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp)))); err != nil {
+			return
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) answer(query []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	resp := dnsmessage.Header{ID: header.ID, Response: true, OpCode: header.OpCode, RecursionDesired: header.RecursionDesired}
+
+	q, err := p.Question()
+	if err != nil || header.OpCode != 0 {
+		resp.RCode = dnsmessage.RCodeFormatError
+		if header.OpCode != 0 {
+			resp.RCode = dnsmessage.RCodeNotImplemented
+		}
+		b := dnsmessage.NewBuilder(nil, resp)
+		return b.Finish()
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+	switch {
+	case !s.inZone(name):
+		resp.RCode = dnsmessage.RCodeRefused
+	case !s.covers(name):
+		resp.Authoritative = true
+		resp.RCode = dnsmessage.RCodeNameError
+	default:
+		resp.Authoritative = true
+	}
+
+	b := dnsmessage.NewBuilder(nil, resp)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if resp.Authoritative && resp.RCode == dnsmessage.RCodeSuccess && q.Class == dnsmessage.ClassINET {
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: ttl}
+		switch q.Type {
+		case dnsmessage.TypeA:
+			err = b.AResource(rh, dnsmessage.AResource{A: loopbackV4})
+		case dnsmessage.TypeAAAA:
+			err = b.AAAAResource(rh, dnsmessage.AAAAResource{AAAA: loopbackV6})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+func (s *Server) inZone(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, z := range s.zones {
+		if name == z || strings.HasSuffix(name, "."+z) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) covers(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, d := range s.domains {
+		if base, ok := strings.CutPrefix(d, config.WildcardPrefix); ok {
+			if strings.HasSuffix(name, "."+base) {
+				return true
+			}
+			continue
+		}
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func query(t *testing.T, s *Server, name string, qtype dnsmessage.Type) dnsmessage.Message {
+	t.Helper()
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := q.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := s.answer(packed)
+	if err != nil {
+		t.Fatalf("answer: %v", err)
+	}
+	var resp dnsmessage.Message
+	if err := resp.Unpack(raw); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if resp.ID != 42 || !resp.Response {
+		t.Fatalf("unexpected header %+v", resp.Header)
+	}
+	return resp
+}
+
+func TestAnswer(t *testing.T) {
+	s := NewServer()
+	s.SetDomains([]string{"myapp.test", "*.tenants.loc", "api.dev"}, []string{"api.dev", "loc", "test"})
+
+	tests := []struct {
+		name    string
+		qname   string
+		qtype   dnsmessage.Type
+		rcode   dnsmessage.RCode
+		answers int
+	}{
+		{"exact A", "myapp.test.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 1},
+		{"exact AAAA", "myapp.test.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess, 1},
+		{"subdomain", "api.myapp.test.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 1},
+		{"case insensitive", "MyApp.Test.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 1},
+		{"wildcard", "a.tenants.loc.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 1},
+		{"wildcard base", "tenants.loc.", dnsmessage.TypeA, dnsmessage.RCodeNameError, 0},
+		{"other type", "myapp.test.", dnsmessage.TypeMX, dnsmessage.RCodeSuccess, 0},
+		{"unknown name in zone", "other.test.", dnsmessage.TypeA, dnsmessage.RCodeNameError, 0},
+		{"other zone", "example.com.", dnsmessage.TypeA, dnsmessage.RCodeRefused, 0},
+		{"public tld domain", "api.dev.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 1},
+		{"public tld subdomain", "v1.api.dev.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 1},
+		{"rest of public tld", "web.dev.", dnsmessage.TypeA, dnsmessage.RCodeRefused, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := query(t, s, tt.qname, tt.qtype)
+			if resp.RCode != tt.rcode || len(resp.Answers) != tt.answers {
+				t.Fatalf("expected %v with %d answers, got %v with %d", tt.rcode, tt.answers, resp.RCode, len(resp.Answers))
+			}
+			if tt.answers == 0 {
+				return
+			}
+			switch body := resp.Answers[0].Body.(type) {
+			case *dnsmessage.AResource:
+				if body.A != loopbackV4 {
+					t.Fatalf("unexpected A %v", body.A)
+				}
+			case *dnsmessage.AAAAResource:
+				if body.AAAA != loopbackV6 {
+					t.Fatalf("unexpected AAAA %v", body.AAAA)
+				}
+			default:
+				t.Fatalf("unexpected answer %T", body)
+			}
+		})
+	}
+}
+
+func TestListenServesUDPAndTCP(t *testing.T) {
+	s := NewServer()
+	s.SetDomains([]string{"myapp.test"}, []string{"test"})
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer s.Close()
+	addr := s.udp.LocalAddr().String()
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.Listen(addr); err != nil {
+		t.Skipf("cannot bind udp and tcp on the same port: %v", err)
+	}
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			r := &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			}
+			addrs, err := r.LookupHost(context.Background(), "api.myapp.test")
+			if err != nil {
+				t.Fatalf("LookupHost: %v", err)
+			}
+			slices.Sort(addrs)
+			if !reflect.DeepEqual(addrs, []string{"127.0.0.1", "::1"}) {
+				t.Fatalf("unexpected addresses %v", addrs)
+			}
+		})
+	}
+}
+
+func TestCloseStopsListening(t *testing.T) {
+	s := NewServer()
+	if err := s.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	if !s.Listening() {
+		t.Fatal("expected server to be listening")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if s.Listening() {
+		t.Fatal("expected server to stop listening")
+	}
+}
@@ -1,12 +1,16 @@
 package doctor
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/cert"
@@ -40,23 +44,48 @@ var (
 	newPortFwdFn      = system.NewPortForwarder
 	configLoadFn      = config.Load
 	dialTimeoutFn     = net.DialTimeout
+	lookupHostFn      = net.DefaultResolver.LookupHost
+	newResolverFn     = system.NewResolver
 )
 
 func Run() Report {
 	cfg, _ := configLoadFn()
 
 	var results []CheckResult
-	results = append(results, checkCACert())
-	results = append(results, checkCATrust())
-	results = append(results, checkPortForwarding())
+	caResult := checkCACert()
+	results = append(results, caResult)
+	if caResult.Status != Fail {
+		results = append(results, checkCAConstraints(cfg))
+		results = append(results, checkCAKey())
+	}
+	if r, ok := checkCARotation(); ok {
+		results = append(results, r)
+	}
+	results = append(results, checkCATrust()...)
+	results = append(results, checkPortForwarding(cfg))
 
 	if cfg != nil {
+		if cfg.DNS {
+			results = append(results, checkResolver(cfg))
+		} else {
+			for _, d := range cfg.Domains {
+				for _, host := range d.HostNames() {
+					results = append(results, checkHostsFile(host))
+				}
+			}
+		}
 		for _, d := range cfg.Domains {
-			results = append(results, checkHostsFile(d.Name))
+			for _, host := range resolveNames(d, cfg.DNS) {
+				results = append(results, checkResolves(host))
+			}
 		}
 	}
 
 	results = append(results, checkDaemon())
+	if daemonIsRunningFn() {
+		results = append(results, checkIngress(cfg, "IPv4", "127.0.0.1"))
+		results = append(results, checkIngress(cfg, "IPv6", "::1"))
+	}
 
 	if cfg != nil {
 		for _, d := range cfg.Domains {
@@ -96,12 +125,73 @@ func checkCACert() CheckResult {
 	return CheckResult{Name: name, Status: Pass, Message: fmt.Sprintf("valid, expires %s", c.NotAfter.Format("2006-01-02"))}
 }
 
-func checkCATrust() CheckResult {
+func checkCAConstraints(cfg *config.Config) CheckResult {
+	name := "CA constraints"
+
+	data, err := readFileFn(cert.CACertPath())
+	if err != nil {
+		return CheckResult{Name: name, Status: Fail, Message: "CA not found"}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return CheckResult{Name: name, Status: Fail, Message: "invalid PEM"}
+	}
+	c, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return CheckResult{Name: name, Status: Fail, Message: "cannot parse: " + err.Error()}
+	}
+
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if reason := cert.ReplacementReason(c, cfg.PermittedCASuffixes()); reason != "" {
+		return CheckResult{Name: name, Status: Warn, Message: reason + " (run: slim cert rotate-ca)"}
+	}
+	return CheckResult{Name: name, Status: Pass, Message: "limited to " + strings.Join(c.PermittedDNSDomains, ", ") + " and loopback IPs"}
+}
+
+func checkCAKey() CheckResult {
+	name := "CA key"
+
+	data, err := readFileFn(cert.CAKeyPath())
+	if err != nil {
+		return CheckResult{Name: name, Status: Fail, Message: "not found"}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return CheckResult{Name: name, Status: Fail, Message: "invalid PEM"}
+	}
+	if block.Type != "ENCRYPTED PRIVATE KEY" {
+		return CheckResult{Name: name, Status: Pass, Message: "not encrypted (optional: slim cert encrypt-key)"}
+	}
+	if _, err := cert.CAPassphrase(); err != nil {
+		return CheckResult{Name: name, Status: Warn, Message: "encrypted, but " + err.Error()}
+	}
+	return CheckResult{Name: name, Status: Pass, Message: "encrypted with a passphrase"}
+}
+
+// checkCARotation reports a CA rotation that was interrupted, if any.
+func checkCARotation() (CheckResult, bool) {
+	name := "CA rotation"
+	stage, err := cert.RotationStage()
+	switch {
+	case err != nil:
+		return CheckResult{Name: name, Status: Fail, Message: err.Error()}, true
+	case stage != "":
+		return CheckResult{Name: name, Status: Warn, Message: fmt.Sprintf("interrupted after %q (run: slim cert rotate-ca to finish)", stage)}, true
+	}
+	return CheckResult{}, false
+}
+
+func checkCATrust() []CheckResult {
 	return verifyCAIsTrusted()
 }
 
-func checkPortForwarding() CheckResult {
+func checkPortForwarding(cfg *config.Config) CheckResult {
 	name := "Port forwarding"
+	if cfg != nil && cfg.NoPortForward {
+		return CheckResult{Name: name, Status: Pass, Message: fmt.Sprintf("not used (rootless mode, https on port %d)", cfg.HTTPSListenPort())}
+	}
 	pf := newPortFwdFn()
 	if !pf.IsEnabled() {
 		return CheckResult{Name: name, Status: Warn, Message: "not configured"}
@@ -113,7 +203,7 @@ func checkPortForwarding() CheckResult {
 		return CheckResult{Name: name, Status: Warn, Message: "configured but inactive (run: sudo pfctl -e && sudo pfctl -f /etc/pf.conf)"}
 	}
 	if daemonIsRunningFn() {
-		missing := missingIngressPorts()
+		missing := missingIngressPorts(cfg)
 		if len(missing) > 0 {
 			return CheckResult{
 				Name:    name,
@@ -122,21 +212,44 @@ func checkPortForwarding() CheckResult {
 			}
 		}
 	}
-	return CheckResult{Name: name, Status: Pass, Message: fmt.Sprintf("active (80→%d, 443→%d)", config.ProxyHTTPPort, config.ProxyHTTPSPort)}
+	return CheckResult{Name: name, Status: Pass, Message: fmt.Sprintf("active (80→%d, 443→%d)", cfg.HTTPListenPort(), cfg.HTTPSListenPort())}
 }
 
-func missingIngressPorts() []string {
-	ports := []int{80, 443}
+func missingIngressPorts(cfg *config.Config) []string {
+	missing, _ := unreachablePorts(cfg, "127.0.0.1")
+	return missing
+}
+
+// unreachablePorts dials the public HTTP and HTTPS ports on a loopback
+// address. The returned error is the last dial failure.
+func unreachablePorts(cfg *config.Config, host string) ([]string, error) {
+	ports := []int{cfg.PublicHTTPPort(), cfg.PublicHTTPSPort()}
 	var missing []string
+	var lastErr error
 	for _, port := range ports {
-		conn, err := dialTimeoutFn("tcp", fmt.Sprintf("127.0.0.1:%d", port), 500*time.Millisecond)
+		conn, err := dialTimeoutFn("tcp", net.JoinHostPort(host, fmt.Sprintf("%d", port)), 500*time.Millisecond)
 		if err != nil {
 			missing = append(missing, fmt.Sprintf("%d", port))
+			lastErr = err
 			continue
 		}
 		_ = conn.Close()
 	}
-	return missing
+	return missing, lastErr
+}
+
+// checkIngress reports whether the proxy answers on its public ports over
+// one loopback family. A host without that family only gets a warning.
+func checkIngress(cfg *config.Config, family, host string) CheckResult {
+	name := "Ingress: " + family
+	missing, err := unreachablePorts(cfg, host)
+	if len(missing) == 0 {
+		return CheckResult{Name: name, Status: Pass, Message: fmt.Sprintf("%s reachable on %d, %d", host, cfg.PublicHTTPPort(), cfg.PublicHTTPSPort())}
+	}
+	if errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, syscall.EAFNOSUPPORT) || errors.Is(err, syscall.ENETUNREACH) {
+		return CheckResult{Name: name, Status: Warn, Message: fmt.Sprintf("%s is not available on this machine", host)}
+	}
+	return CheckResult{Name: name, Status: Fail, Message: fmt.Sprintf("%s unreachable on %s (run: slim start to reload port forwarding)", host, strings.Join(missing, ", "))}
 }
 
 func checkHostsFile(domain string) CheckResult {
@@ -147,10 +260,60 @@ func checkHostsFile(domain string) CheckResult {
 		return CheckResult{Name: name, Status: Fail, Message: "cannot read /etc/hosts"}
 	}
 
-	if system.HasMarkedEntry(string(content), domain) {
-		return CheckResult{Name: name, Status: Pass, Message: "present in /etc/hosts"}
+	if !system.HasMarkedEntry(string(content), domain) {
+		return CheckResult{Name: name, Status: Fail, Message: "missing from /etc/hosts"}
+	}
+	for _, addr := range system.LoopbackAddrs {
+		if !system.HasMarkedEntryFor(string(content), addr, domain) {
+			return CheckResult{Name: name, Status: Warn, Message: fmt.Sprintf("no %s entry in /etc/hosts (run: slim start %s)", addr, domain)}
+		}
+	}
+	return CheckResult{Name: name, Status: Pass, Message: "present in /etc/hosts"}
+}
+
+func checkResolver(cfg *config.Config) CheckResult {
+	name := "DNS resolver"
+	r, err := newResolverFn()
+	if err != nil {
+		return CheckResult{Name: name, Status: Fail, Message: err.Error()}
+	}
+	installed := r.InstalledZones()
+	var missing []string
+	for _, z := range cfg.DNSZones() {
+		if !slices.Contains(installed, z) {
+			missing = append(missing, "."+z)
+		}
+	}
+	if len(missing) > 0 {
+		return CheckResult{Name: name, Status: Fail, Message: fmt.Sprintf("%s not routed via %s (run: slim dns enable)", strings.Join(missing, ", "), r.Name())}
+	}
+	return CheckResult{Name: name, Status: Pass, Message: "routed via " + r.Name()}
+}
+
+// resolveNames picks the names to look up for a domain. A wildcard domain
+// served by the embedded resolver is probed with a made up subdomain.
+func resolveNames(d config.Domain, dnsEnabled bool) []string {
+	if dnsEnabled && config.IsWildcardDomain(d.Name) {
+		return []string{config.QualifyHost(d.Name, "slim-doctor")}
 	}
-	return CheckResult{Name: name, Status: Fail, Message: "missing from /etc/hosts"}
+	return d.HostNames()
+}
+
+func checkResolves(domain string) CheckResult {
+	name := "Resolve: " + domain
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	addrs, err := lookupHostFn(ctx, domain)
+	if err != nil {
+		return CheckResult{Name: name, Status: Fail, Message: "does not resolve"}
+	}
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip == nil || !ip.IsLoopback() {
+			return CheckResult{Name: name, Status: Fail, Message: fmt.Sprintf("resolves to %s, not this machine", a)}
+		}
+	}
+	return CheckResult{Name: name, Status: Pass, Message: "resolves to " + strings.Join(addrs, ", ")}
 }
 
 func checkDaemon() CheckResult {
@@ -163,6 +326,9 @@ func checkDaemon() CheckResult {
 	if err != nil || !resp.OK {
 		return CheckResult{Name: name, Status: Fail, Message: "running but IPC failed"}
 	}
+	if info := (daemon.VersionInfo{Protocol: resp.Protocol, Version: resp.Version}); !info.Current() {
+		return CheckResult{Name: name, Status: Warn, Message: fmt.Sprintf("stale: running %s, CLI is %s (the next slim start restarts it)", info, daemon.Version)}
+	}
 
 	return CheckResult{Name: name, Status: Pass, Message: "running"}
 }
@@ -10,13 +10,13 @@ import (
 
 var execCommandFn = exec.Command
 
-func verifyCAIsTrusted() CheckResult {
+func verifyCAIsTrusted() []CheckResult {
 	name := "CA trust"
 
 	cmd := execCommandFn("security", "verify-cert", "-c", cert.CACertPath())
 	if err := cmd.Run(); err != nil {
-		return CheckResult{Name: name, Status: Fail, Message: "not trusted by OS"}
+		return []CheckResult{{Name: name, Status: Fail, Message: "not trusted by OS"}}
 	}
 
-	return CheckResult{Name: name, Status: Pass, Message: "trusted by OS"}
+	return []CheckResult{{Name: name, Status: Pass, Message: "trusted by OS"}}
 }
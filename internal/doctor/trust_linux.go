@@ -3,6 +3,7 @@
 package doctor
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -15,7 +16,35 @@ var caDirs = []string{
 	"/etc/ca-certificates/trust-source/anchors",
 }
 
-func verifyCAIsTrusted() CheckResult {
+var (
+	nssStoresFn  = cert.NSSStores
+	nssTrustedFn = cert.NSSTrusted
+)
+
+// verifyCAIsTrusted reports the system store and then each NSS database,
+// since Firefox and Chromium don't read the system store.
+func verifyCAIsTrusted() []CheckResult {
+	results := []CheckResult{verifySystemTrust()}
+	for _, store := range nssStoresFn() {
+		name := "CA trust: " + store.Name
+		trusted, err := nssTrustedFn(store)
+		switch {
+		case err != nil:
+			results = append(results, CheckResult{Name: name, Status: Warn, Message: fmt.Sprintf("cannot check: %v", err)})
+		case trusted:
+			results = append(results, CheckResult{Name: name, Status: Pass, Message: "trusted"})
+		default:
+			results = append(results, CheckResult{
+				Name:    name,
+				Status:  Warn,
+				Message: fmt.Sprintf("not trusted (run: certutil -A -d sql:%s -t C,, -n %q -i %s)", store.Dir, cert.NSSNickname, cert.CACertPath()),
+			})
+		}
+	}
+	return results
+}
+
+func verifySystemTrust() CheckResult {
 	name := "CA trust"
 	caBase := filepath.Base(cert.CACertPath())
 
@@ -0,0 +1,53 @@
+//go:build linux
+
+package doctor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+)
+
+func TestVerifyCAIsTrustedReportsEachNSSStore(t *testing.T) {
+	prevStores, prevTrusted := nssStoresFn, nssTrustedFn
+	defer func() { nssStoresFn, nssTrustedFn = prevStores, prevTrusted }()
+
+	nssStoresFn = func() []cert.NSSStore {
+		return []cert.NSSStore{
+			{Name: "Chrome/Chromium", Dir: "/home/u/.pki/nssdb"},
+			{Name: "Firefox default-release", Dir: "/home/u/ff"},
+			{Name: "Firefox (Snap) default", Dir: "/home/u/snap"},
+		}
+	}
+	nssTrustedFn = func(store cert.NSSStore) (bool, error) {
+		switch store.Dir {
+		case "/home/u/.pki/nssdb":
+			return true, nil
+		case "/home/u/ff":
+			return false, nil
+		default:
+			return false, cert.ErrNoCertutil
+		}
+	}
+
+	results := verifyCAIsTrusted()
+	if len(results) != 4 || results[0].Name != "CA trust" {
+		t.Fatalf("expected the system store followed by three NSS stores, got %+v", results)
+	}
+	want := []struct {
+		name    string
+		status  Status
+		message string
+	}{
+		{"CA trust: Chrome/Chromium", Pass, "trusted"},
+		{"CA trust: Firefox default-release", Warn, "certutil -A -d sql:/home/u/ff"},
+		{"CA trust: Firefox (Snap) default", Warn, "certutil is not installed"},
+	}
+	for i, w := range want {
+		r := results[i+1]
+		if r.Name != w.name || r.Status != w.status || !strings.Contains(r.Message, w.message) {
+			t.Fatalf("result %d = %+v, want %s %v containing %q", i+1, r, w.name, w.status, w.message)
+		}
+	}
+}
@@ -2,10 +2,10 @@
 
 package doctor
 
-func verifyCAIsTrusted() CheckResult {
-	return CheckResult{
+func verifyCAIsTrusted() []CheckResult {
+	return []CheckResult{{
 		Name:    "CA trust",
 		Status:  Warn,
 		Message: "trust verification not supported on this platform",
-	}
+	}}
 }
@@ -0,0 +1,144 @@
+// Package event fans out things that happen in the daemon to subscribers
+// on the IPC socket.
+package event
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	RequestCompleted Type = "request_completed"
+	HealthChanged    Type = "health_changed"
+	ConfigReloaded   Type = "config_reloaded"
+	CertIssued       Type = "cert_issued"
+	TunnelState      Type = "tunnel_state"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before it starts missing them. Publishers never block.
+const subscriberBuffer = 256
+
+type Event struct {
+	Type Type            `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type Request struct {
+	Domain   string        `json:"domain"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Upstream string        `json:"upstream"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration"`
+}
+
+type Health struct {
+	Domain   string `json:"domain"`
+	Upstream string `json:"upstream"`
+	Healthy  bool   `json:"healthy"`
+}
+
+type Config struct {
+	Domains []string `json:"domains"`
+}
+
+type Cert struct {
+	Domain   string    `json:"domain"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+type Tunnel struct {
+	State     string `json:"state"`
+	URL       string `json:"url,omitempty"`
+	LocalPort int    `json:"local_port,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Subscription struct {
+	ch    chan Event
+	types []Type
+}
+
+// Events delivers the subscribed events until the subscription is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	mu.Lock()
+	delete(subs, s)
+	mu.Unlock()
+}
+
+func (s *Subscription) wants(t Type) bool {
+	return len(s.types) == 0 || slices.Contains(s.types, t)
+}
+
+var (
+	mu   sync.RWMutex
+	subs = map[*Subscription]struct{}{}
+)
+
+// Subscribe starts receiving events of the given types, or of every type
+// when none are given.
+func Subscribe(types ...Type) *Subscription {
+	s := &Subscription{ch: make(chan Event, subscriberBuffer), types: types}
+	mu.Lock()
+	subs[s] = struct{}{}
+	mu.Unlock()
+	return s
+}
+
+func New(t Type, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: t, Time: time.Now(), Data: raw}, nil
+}
+
+// Publish sends an event to every subscriber that wants it. It does nothing
+// when nobody is listening.
+func Publish(t Type, data any) {
+	if !subscribed(t) {
+		return
+	}
+	e, err := New(t, data)
+	if err != nil {
+		return
+	}
+	Emit(e)
+}
+
+// Emit sends an already built event, such as one relayed from another
+// process, to every subscriber that wants it.
+func Emit(e Event) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for s := range subs {
+		if !s.wants(e.Type) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+}
+
+func subscribed(t Type) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for s := range subs {
+		if s.wants(t) {
+			return true
+		}
+	}
+	return false
+}
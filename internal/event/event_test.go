@@ -0,0 +1,65 @@
+package event
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSubscribeFiltersTypes(t *testing.T) {
+	all := Subscribe()
+	defer all.Close()
+	health := Subscribe(HealthChanged)
+	defer health.Close()
+
+	Publish(RequestCompleted, Request{Domain: "myapp.test", Status: 200})
+	Publish(HealthChanged, Health{Domain: "myapp.test", Upstream: "3000"})
+
+	if got := drain(all); len(got) != 2 || got[0].Type != RequestCompleted || got[1].Type != HealthChanged {
+		t.Fatalf("expected both events in order, got %+v", got)
+	}
+	got := drain(health)
+	if len(got) != 1 || got[0].Type != HealthChanged {
+		t.Fatalf("expected only the health event, got %+v", got)
+	}
+	var h Health
+	if err := json.Unmarshal(got[0].Data, &h); err != nil || h.Upstream != "3000" {
+		t.Fatalf("unexpected payload %s (%v)", got[0].Data, err)
+	}
+}
+
+func TestPublishDropsWhenSubscriberIsFull(t *testing.T) {
+	s := Subscribe()
+	defer s.Close()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		Publish(RequestCompleted, Request{Status: i})
+	}
+	if got := drain(s); len(got) != subscriberBuffer {
+		t.Fatalf("expected %d buffered events, got %d", subscriberBuffer, len(got))
+	}
+}
+
+func TestClosedSubscriptionStopsReceiving(t *testing.T) {
+	s := Subscribe()
+	s.Close()
+
+	Publish(ConfigReloaded, Config{})
+	if got := drain(s); len(got) != 0 {
+		t.Fatalf("expected no events after Close, got %+v", got)
+	}
+	if subscribed(ConfigReloaded) {
+		t.Fatal("expected no subscribers left")
+	}
+}
+
+func drain(s *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case e := <-s.Events():
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Levels of the daemon log.
+const (
+	LevelInfo  = "INFO"
+	LevelWarn  = "WARN"
+	LevelError = "ERROR"
+)
+
+const daemonTimeFormat = "2006-01-02 15:04:05.000"
+
+// The daemon log is rotated once it grows past maxDaemonLogSize, keeping
+// daemonLogBackups older files as daemon.log.1, daemon.log.2 and so on.
+var (
+	maxDaemonLogSize int64 = 5 << 20
+	daemonLogBackups       = 3
+)
+
+var (
+	daemonMu  sync.Mutex
+	daemonOut *rotatingFile
+)
+
+// SetDaemonOutput makes Info, Warn and Error also append timestamped lines
+// to the daemon log at path. The daemon's stdout goes nowhere once it is
+// detached, so this is where its messages end up.
+func SetDaemonOutput(path string) error {
+	f, err := openRotatingFile(path, maxDaemonLogSize, daemonLogBackups)
+	if err != nil {
+		return fmt.Errorf("opening daemon log: %w", err)
+	}
+	daemonMu.Lock()
+	defer daemonMu.Unlock()
+	if daemonOut != nil {
+		_ = daemonOut.Close()
+	}
+	daemonOut = f
+	return nil
+}
+
+func CloseDaemonOutput() {
+	daemonMu.Lock()
+	defer daemonMu.Unlock()
+	if daemonOut != nil {
+		_ = daemonOut.Close()
+		daemonOut = nil
+	}
+}
+
+// FormatDaemonLine renders a daemon log line: time, level and message,
+// separated by tabs.
+func FormatDaemonLine(t time.Time, level, msg string) string {
+	return fmt.Sprintf("%s\t%s\t%s", t.Format(daemonTimeFormat), level, strings.ReplaceAll(msg, "\n", " "))
+}
+
+func writeDaemon(level, msg string) {
+	daemonMu.Lock()
+	defer daemonMu.Unlock()
+	if daemonOut == nil {
+		return
+	}
+	_, _ = daemonOut.Write([]byte(FormatDaemonLine(time.Now(), level, msg) + "\n"))
+}
+
+// rotatingFile appends to a file, moving it aside once a write would take
+// it past max bytes.
+type rotatingFile struct {
+	path    string
+	max     int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, max int64, backups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, max: max, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.max {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.backups > 0 {
+		for i := r.backups - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(r.path, 0); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDaemonOutputWritesLeveledLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := SetDaemonOutput(path); err != nil {
+		t.Fatalf("SetDaemonOutput: %v", err)
+	}
+	t.Cleanup(CloseDaemonOutput)
+
+	Info("listening on %s", "127.0.0.1:10443")
+	Warn("ignoring edit")
+	Error("reload failed:\n%v", "bad yaml")
+	CloseDaemonOutput()
+	Info("not written")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := [][2]string{{LevelInfo, "listening on 127.0.0.1:10443"}, {LevelWarn, "ignoring edit"}, {LevelError, "reload failed: bad yaml"}}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %q", len(want), lines)
+	}
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || fields[1] != want[i][0] || fields[2] != want[i][1] {
+			t.Fatalf("line %d = %q, want level %s and message %q", i, line, want[i][0], want[i][1])
+		}
+	}
+}
+
+func TestRotatingFileKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	r, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		if _, err := fmt.Fprintf(r, "line %d\n", i); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for name, want := range map[string]string{path: "line 4\n", path + ".1": "line 3\n", path + ".2": "line 2\n"} {
+		data, err := os.ReadFile(name)
+		if err != nil || string(data) != want {
+			t.Fatalf("%s = %q (%v), want %q", filepath.Base(name), data, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected only two backups, got %v", err)
+	}
+}
+
+func TestRotatingFileContinuesExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := os.WriteFile(path, []byte("12345678\n"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	r, err := openRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	if _, err := r.Write([]byte("next\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_ = r.Close()
+
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "12345678\n" {
+		t.Fatalf("expected the existing content to be rotated, got %q", data)
+	}
+}
@@ -63,7 +63,7 @@ func Close() {
 	shutdownWriterLocked()
 }
 
-func Request(domain string, method string, path string, upstream int, status int, duration time.Duration) {
+func Request(domain string, method string, path string, upstream string, status int, duration time.Duration) {
 	mu.RLock()
 	mode := logMode
 	ch := logCh
@@ -73,13 +73,10 @@ func Request(domain string, method string, path string, upstream int, status int
 		return
 	}
 
-	ts := time.Now().Format("15:04:05")
-	dur := FormatDuration(duration)
-
-	line := fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
-		ts, domain, method, path, upstream, status, dur)
+	now := time.Now()
+	line := FormatRequest(now, domain, method, path, upstream, status, duration) + "\n"
 	if mode == logModeMinimal {
-		line = fmt.Sprintf("%s\t%s\t%d\t%s\n", ts, domain, status, dur)
+		line = fmt.Sprintf("%s\t%s\t%d\t%s\n", now.Format("15:04:05"), domain, status, FormatDuration(duration))
 	}
 
 	select {
@@ -88,12 +85,28 @@ func Request(domain string, method string, path string, upstream int, status int
 	}
 }
 
+// FormatRequest renders a request as a line of the full access log.
+func FormatRequest(t time.Time, domain string, method string, path string, upstream string, status int, duration time.Duration) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%d\t%s",
+		t.Format("15:04:05"), domain, method, path, upstream, status, FormatDuration(duration))
+}
+
 func Info(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", term.Cyan.Render("[slim]"), fmt.Sprintf(format, args...))
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("%s %s\n", term.Cyan.Render("[slim]"), msg)
+	writeDaemon(LevelInfo, msg)
+}
+
+func Warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("%s %s\n", term.Yellow.Render("[slim]"), msg)
+	writeDaemon(LevelWarn, msg)
 }
 
 func Error(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", term.Red.Render("[slim]"), fmt.Sprintf(format, args...))
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("%s %s\n", term.Red.Render("[slim]"), msg)
+	writeDaemon(LevelError, msg)
 }
 
 func FormatDuration(d time.Duration) string {
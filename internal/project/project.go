@@ -17,15 +17,28 @@ var (
 )
 
 type Service struct {
-	Domain string         `yaml:"domain"`
-	Port   int            `yaml:"port"`
-	Routes []config.Route `yaml:"routes,omitempty"`
+	Domain   string              `yaml:"domain"`
+	Port     int                 `yaml:"port,omitempty"`
+	Ports    []int               `yaml:"ports,omitempty"`
+	Balance  string              `yaml:"balance,omitempty"`
+	Sticky   bool                `yaml:"sticky,omitempty"`
+	Upstream string              `yaml:"upstream,omitempty"`
+	Socket   string              `yaml:"socket,omitempty"`
+	Dir      string              `yaml:"dir,omitempty"`
+	SPA      bool                `yaml:"spa,omitempty"`
+	Listing  bool                `yaml:"listing,omitempty"`
+	Scheme   string              `yaml:"scheme,omitempty"`
+	TLS      *config.UpstreamTLS `yaml:"tls,omitempty"`
+	Headers  *config.Headers     `yaml:"headers,omitempty"`
+	Hosts    []string            `yaml:"hosts,omitempty"`
+	Routes   []config.Route      `yaml:"routes,omitempty"`
 }
 
 type ProjectConfig struct {
-	Services []Service `yaml:"services"`
-	LogMode  string    `yaml:"log_mode,omitempty"`
-	Cors     bool      `yaml:"cors,omitempty"`
+	Services []Service       `yaml:"services"`
+	LogMode  string          `yaml:"log_mode,omitempty"`
+	Cors     bool            `yaml:"cors,omitempty"`
+	Headers  *config.Headers `yaml:"headers,omitempty"`
 }
 
 func Find() (string, error) {
@@ -59,13 +72,38 @@ func Load(path string) (*ProjectConfig, error) {
 		return nil, fmt.Errorf("parsing %s: %w", path, err)
 	}
 
+	dir := filepath.Dir(path)
 	for i, svc := range pc.Services {
 		pc.Services[i].Domain = config.NormalizeDomain(svc.Domain)
+		for j, h := range svc.Hosts {
+			pc.Services[i].Hosts[j] = config.QualifyHost(pc.Services[i].Domain, h)
+		}
+		pc.Services[i].Socket = resolvePath(dir, svc.Socket)
+		pc.Services[i].Dir = resolvePath(dir, svc.Dir)
+		if svc.TLS != nil {
+			svc.TLS.CAFile = resolvePath(dir, svc.TLS.CAFile)
+		}
+		for j, r := range svc.Routes {
+			pc.Services[i].Routes[j].Socket = resolvePath(dir, r.Socket)
+			pc.Services[i].Routes[j].Dir = resolvePath(dir, r.Dir)
+			if r.TLS != nil {
+				r.TLS.CAFile = resolvePath(dir, r.TLS.CAFile)
+			}
+		}
 	}
 
 	return &pc, nil
 }
 
+// resolvePath makes socket, dir and CA paths in .slim.yaml relative to the
+// file itself, so a project can point at ./tmp/app.sock or ./dist.
+func resolvePath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
 func Discover() (*ProjectConfig, string, error) {
 	path, err := Find()
 	if err != nil {
@@ -90,23 +128,41 @@ func (pc *ProjectConfig) Validate() error {
 			return err
 		}
 	}
+	if err := pc.Headers.Validate(); err != nil {
+		return err
+	}
 
 	seen := make(map[string]bool)
 	for _, svc := range pc.Services {
-		if err := config.ValidateDomain(svc.Domain, svc.Port); err != nil {
+		d := svc.ConfigDomain()
+		if err := d.Validate(); err != nil {
 			return fmt.Errorf("service %q: %w", svc.Domain, err)
 		}
 		if seen[svc.Domain] {
 			return fmt.Errorf("duplicate domain %q", svc.Domain)
 		}
 		seen[svc.Domain] = true
-
-		for _, r := range svc.Routes {
-			if err := config.ValidateRoute(r.Path, r.Port); err != nil {
-				return fmt.Errorf("service %q route %q: %w", svc.Domain, r.Path, err)
-			}
-		}
 	}
 
 	return nil
 }
+
+func (s Service) ConfigDomain() config.Domain {
+	return config.Domain{
+		Name:     s.Domain,
+		Port:     s.Port,
+		Ports:    s.Ports,
+		Balance:  s.Balance,
+		Sticky:   s.Sticky,
+		Upstream: s.Upstream,
+		Socket:   s.Socket,
+		Dir:      s.Dir,
+		SPA:      s.SPA,
+		Listing:  s.Listing,
+		Scheme:   s.Scheme,
+		TLS:      s.TLS,
+		Headers:  s.Headers,
+		Hosts:    s.Hosts,
+		Routes:   s.Routes,
+	}
+}
@@ -160,6 +160,124 @@ func TestValidateInvalidRoute(t *testing.T) {
 	}
 }
 
+func TestLoadRoutePathOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, FileName)
+
+	content := `services:
+  - domain: myapp
+    port: 3000
+    routes:
+      - path: /api
+        port: 8080
+        strip_prefix: false
+        rewrite:
+          pattern: ^/api
+          replace: /v2
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	r := pc.Services[0].Routes[0]
+	if r.ShouldStripPrefix() {
+		t.Error("expected strip_prefix: false to be loaded")
+	}
+	if r.Rewrite == nil || r.Rewrite.Pattern != "^/api" || r.Rewrite.Replace != "/v2" {
+		t.Errorf("unexpected rewrite %+v", r.Rewrite)
+	}
+	if err := pc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	pc.Services[0].Routes[0].Rewrite.Pattern = "("
+	if err := pc.Validate(); err == nil {
+		t.Fatal("expected error for invalid rewrite pattern")
+	}
+}
+
+func TestValidateUpstreamService(t *testing.T) {
+	pc := &ProjectConfig{
+		Services: []Service{
+			{Domain: "api.test", Upstream: "http://10.0.0.5:8080", Routes: []config.Route{{Path: "/v2", Upstream: "https://staging-api.internal"}}},
+		},
+	}
+	if err := pc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	pc.Services[0].Port = 3000
+	if err := pc.Validate(); err == nil {
+		t.Fatal("expected error when both port and upstream are set")
+	}
+}
+
+func TestLoadResolvesRelativeSocketPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, FileName)
+
+	content := `services:
+  - domain: myapp
+    socket: tmp/app.sock
+    routes:
+      - path: /api
+        socket: /run/api.sock
+      - path: /docs
+        dir: ./dist
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := filepath.Join(tmpDir, "tmp", "app.sock"); pc.Services[0].Socket != want {
+		t.Errorf("expected socket %q, got %q", want, pc.Services[0].Socket)
+	}
+	if pc.Services[0].Routes[0].Socket != "/run/api.sock" {
+		t.Errorf("expected absolute route socket preserved, got %q", pc.Services[0].Routes[0].Socket)
+	}
+	if want := filepath.Join(tmpDir, "dist"); pc.Services[0].Routes[1].Dir != want {
+		t.Errorf("expected dir %q, got %q", want, pc.Services[0].Routes[1].Dir)
+	}
+	if err := pc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestLoadResolvesRelativeCAFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, FileName)
+
+	content := `services:
+  - domain: myapp
+    port: 8443
+    scheme: https
+    tls:
+      ca_file: certs/ca.pem
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := filepath.Join(tmpDir, "certs", "ca.pem"); pc.Services[0].TLS.CAFile != want {
+		t.Errorf("expected ca_file %q, got %q", want, pc.Services[0].TLS.CAFile)
+	}
+	if err := pc.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
 func TestDiscover(t *testing.T) {
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, FileName)
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+const (
+	stickyCookieName = "slim_upstream"
+	ejectDuration    = 10 * time.Second
+)
+
+var nowFn = time.Now
+
+type triedKey struct{}
+
+type backend struct {
+	label        string
+	proxy        *httputil.ReverseProxy
+	active       atomic.Int64
+	ejectedUntil atomic.Int64
+}
+
+func (b *backend) ejected(now time.Time) bool {
+	return now.UnixNano() < b.ejectedUntil.Load()
+}
+
+// balancer spreads requests across several upstreams. A backend that fails
+// to connect is ejected for ejectDuration and the request is retried on the
+// next one when it is idempotent and has no body.
+type balancer struct {
+	backends   []*backend
+	policy     string
+	sticky     bool
+	cookiePath string
+	next       atomic.Uint64
+}
+
+func newBalancer(targets []*url.URL, policy string, sticky bool, cookiePath string, proxyFor func(*url.URL) *httputil.ReverseProxy) *balancer {
+	lb := &balancer{policy: policy, sticky: sticky, cookiePath: cookiePath}
+	for _, target := range targets {
+		b := &backend{label: upstreamLabel(target), proxy: proxyFor(target)}
+		upstreamDown := b.proxy.ErrorHandler
+		b.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.Canceled) {
+				upstreamDown(w, r, err)
+				return
+			}
+			b.ejectedUntil.Store(nowFn().Add(ejectDuration).UnixNano())
+			markUpstreamFailed(w, b.label)
+			tried, _ := r.Context().Value(triedKey{}).([]*backend)
+			if canRetry(r) && len(tried) < len(lb.backends) {
+				lb.serve(w, r, tried)
+				return
+			}
+			upstreamDown(w, r, err)
+		}
+		lb.backends = append(lb.backends, b)
+	}
+	return lb
+}
+
+func (lb *balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lb.serve(w, r, nil)
+}
+
+func (lb *balancer) serve(w http.ResponseWriter, r *http.Request, tried []*backend) {
+	b := lb.pick(r, tried)
+	b.active.Add(1)
+	defer b.active.Add(-1)
+
+	if lb.sticky {
+		// Set rather than add so a failover replaces the cookie of the
+		// backend that just failed.
+		cookie := &http.Cookie{
+			Name:     stickyCookieName,
+			Value:    b.label,
+			Path:     lb.cookiePath,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}
+		w.Header().Set("Set-Cookie", cookie.String())
+	}
+	if rec, ok := w.(*statusRecorder); ok {
+		rec.upstream = b.label
+	}
+
+	ctx := context.WithValue(r.Context(), triedKey{}, append(tried[:len(tried):len(tried)], b))
+	b.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// pick chooses among backends not tried yet for this request, preferring
+// ones that are not ejected. When every backend is ejected it still picks
+// one so a recovered upstream is noticed.
+func (lb *balancer) pick(r *http.Request, tried []*backend) *backend {
+	now := nowFn()
+	var healthy, untried []*backend
+	for _, b := range lb.backends {
+		if contains(tried, b) {
+			continue
+		}
+		untried = append(untried, b)
+		if !b.ejected(now) {
+			healthy = append(healthy, b)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = untried
+	}
+
+	if lb.sticky {
+		if c, err := r.Cookie(stickyCookieName); err == nil {
+			for _, b := range candidates {
+				if b.label == c.Value {
+					return b
+				}
+			}
+		}
+	}
+
+	switch lb.policy {
+	case config.BalanceLeastConn:
+		best := candidates[0]
+		for _, b := range candidates[1:] {
+			if b.active.Load() < best.active.Load() {
+				best = b
+			}
+		}
+		return best
+	case config.BalanceRandom:
+		return candidates[rand.IntN(len(candidates))]
+	default:
+		start := int(lb.next.Add(1)-1) % len(lb.backends)
+		for i := range lb.backends {
+			b := lb.backends[(start+i)%len(lb.backends)]
+			if contains(candidates, b) {
+				return b
+			}
+		}
+		return candidates[0]
+	}
+}
+
+// canRetry reports whether r can be sent again to another backend. The
+// body itself is no guide: HTTP/2 and request capture both wrap it even
+// when nothing is sent.
+func canRetry(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return r.ContentLength == 0
+	}
+	return false
+}
+
+func contains(backends []*backend, b *backend) bool {
+	for _, c := range backends {
+		if c == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	captureCapacity  = 500
+	captureBodyLimit = 64 << 10
+)
+
+// Capture is one proxied request/response pair kept for the inspector.
+// Bodies are cut off at captureBodyLimit bytes.
+type Capture struct {
+	ID         uint64          `json:"id"`
+	Time       time.Time       `json:"time"`
+	Domain     string          `json:"domain"`
+	Method     string          `json:"method"`
+	URI        string          `json:"uri"`
+	Upstream   string          `json:"upstream"`
+	Status     int             `json:"status"`
+	Duration   time.Duration   `json:"duration"`
+	RemoteAddr string          `json:"remote_addr"`
+	Request    CapturedMessage `json:"request"`
+	Response   CapturedMessage `json:"response"`
+	ReplayOf   uint64          `json:"replay_of,omitempty"`
+}
+
+type CapturedMessage struct {
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// captureLog is a fixed size ring buffer of recent captures.
+type captureLog struct {
+	mu    sync.RWMutex
+	items []*Capture
+	next  int
+	seq   uint64
+}
+
+func newCaptureLog(capacity int) *captureLog {
+	return &captureLog{items: make([]*Capture, capacity)}
+}
+
+func (l *captureLog) add(c *Capture) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	c.ID = l.seq
+	l.items[l.next] = c
+	l.next = (l.next + 1) % len(l.items)
+}
+
+// clear drops every capture. IDs keep counting up, so a client polling
+// since an old ID doesn't see reused ones.
+func (l *captureLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.items)
+	l.next = 0
+}
+
+// since returns captures newer than id, oldest first.
+func (l *captureLog) since(id uint64) []*Capture {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var out []*Capture
+	for i := range l.items {
+		c := l.items[(l.next+i)%len(l.items)]
+		if c != nil && c.ID > id {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (l *captureLog) get(id uint64) (*Capture, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, c := range l.items {
+		if c != nil && c.ID == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// limitedBuffer keeps the first limit bytes written to it and notes whether
+// anything was dropped.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	return bytes.Clone(b.buf.Bytes())
+}
+
+// teeRequestBody copies what the upstream reads from r.Body into a buffer.
+func teeRequestBody(r *http.Request) *limitedBuffer {
+	buf := &limitedBuffer{limit: captureBodyLimit}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, buf), r.Body}
+	}
+	return buf
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import "testing"
+
+func TestCaptureLogKeepsMostRecent(t *testing.T) {
+	l := newCaptureLog(3)
+	for i := 0; i < 5; i++ {
+		l.add(&Capture{URI: "/"})
+	}
+
+	got := l.since(0)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 captures, got %d", len(got))
+	}
+	for i, want := range []uint64{3, 4, 5} {
+		if got[i].ID != want {
+			t.Errorf("capture[%d].ID = %d, want %d", i, got[i].ID, want)
+		}
+	}
+	if got := l.since(4); len(got) != 1 || got[0].ID != 5 {
+		t.Errorf("since(4) = %v, want only capture 5", got)
+	}
+	if _, ok := l.get(1); ok {
+		t.Error("expected evicted capture to be gone")
+	}
+	if c, ok := l.get(4); !ok || c.ID != 4 {
+		t.Errorf("get(4) = %v, %v", c, ok)
+	}
+}
+
+func TestCaptureLogClear(t *testing.T) {
+	l := newCaptureLog(3)
+	l.add(&Capture{URI: "/"})
+	l.add(&Capture{URI: "/"})
+	l.clear()
+	if got := l.since(0); len(got) != 0 {
+		t.Fatalf("expected no captures after clear, got %d", len(got))
+	}
+	l.add(&Capture{URI: "/"})
+	if got := l.since(0); len(got) != 1 || got[0].ID != 3 {
+		t.Fatalf("expected IDs to keep counting after clear, got %v", got)
+	}
+}
+
+func TestLimitedBufferTruncates(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	n, err := b.Write([]byte("hel"))
+	if n != 3 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if n, _ := b.Write([]byte("lo world")); n != 8 {
+		t.Fatalf("expected Write to report the full length, got %d", n)
+	}
+	if string(b.Bytes()) != "hello" || !b.truncated {
+		t.Fatalf("got %q truncated=%v", b.Bytes(), b.truncated)
+	}
+
+	empty := &limitedBuffer{limit: 5}
+	if empty.Bytes() != nil || empty.truncated {
+		t.Fatal("expected empty buffer to report no body")
+	}
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDiffCells bounds the LCS table; larger inputs are shown as a full
+// replacement instead.
+const maxDiffCells = 4_000_000
+
+// responseLines renders the parts of a captured response worth comparing.
+// Date changes on every response, so it is left out.
+func responseLines(c *Capture) []string {
+	lines := []string{fmt.Sprintf("HTTP %d %s", c.Status, http.StatusText(c.Status))}
+
+	names := make([]string, 0, len(c.Response.Header))
+	for name := range c.Response.Header {
+		if name != "Date" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, v := range c.Response.Header[name] {
+			lines = append(lines, name+": "+v)
+		}
+	}
+
+	body := c.Response.Body
+	if len(body) == 0 {
+		return lines
+	}
+	lines = append(lines, "")
+	if !utf8.Valid(body) {
+		return append(lines, fmt.Sprintf("(binary body, %d bytes)", len(body)))
+	}
+	var pretty bytes.Buffer
+	if json.Valid(body) && json.Indent(&pretty, body, "", "  ") == nil {
+		body = pretty.Bytes()
+	}
+	lines = append(lines, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+	if c.Response.Truncated {
+		lines = append(lines, "(truncated)")
+	}
+	return lines
+}
+
+// diffLines returns a line diff of a against b, each line prefixed with
+// "  ", "- " or "+ ", and whether the two differ.
+func diffLines(a, b []string) ([]string, bool) {
+	if slices.Equal(a, b) {
+		return prefixLines("  ", a), false
+	}
+	if len(a)*len(b) > maxDiffCells {
+		return append(prefixLines("- ", a), prefixLines("+ ", b)...), true
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	out := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	out = append(out, prefixLines("- ", a[i:])...)
+	out = append(out, prefixLines("+ ", b[j:])...)
+	return out, true
+}
+
+func prefixLines(prefix string, lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = prefix + l
+	}
+	return out
+}
@@ -2,45 +2,61 @@ package proxy
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 	"github.com/kamranahmedse/slim/internal/log"
 )
 
 type pathRoute struct {
-	prefix  string
-	port    int
-	handler http.Handler
+	prefix   string
+	upstream string
+	handler  http.Handler
 }
 
 type domainRouter struct {
-	defaultPort    int
-	defaultHandler http.Handler
-	pathRoutes     []pathRoute // sorted by prefix length descending
+	defaultUpstream string
+	defaultHandler  http.Handler
+	pathRoutes      []pathRoute // sorted by prefix length descending
 }
 
-func (dr *domainRouter) match(reqPath string) (int, http.Handler) {
+func (dr *domainRouter) match(reqPath string) (string, http.Handler) {
 	for _, pr := range dr.pathRoutes {
-		if reqPath == pr.prefix || (strings.HasPrefix(reqPath, pr.prefix) && (pr.prefix[len(pr.prefix)-1] == '/' || (len(reqPath) > len(pr.prefix) && reqPath[len(pr.prefix)] == '/'))) {
-			return pr.port, pr.handler
+		if config.RouteMatches(pr.prefix, reqPath) {
+			return pr.upstream, pr.handler
 		}
 	}
-	return dr.defaultPort, dr.defaultHandler
+	return dr.defaultUpstream, dr.defaultHandler
 }
 
 func buildHandler(s *Server) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		host := normalizeHost(r.Host)
+		if host == config.InspectorDomain && s.inspector != nil {
+			s.inspector.ServeHTTP(w, r)
+			return
+		}
 
 		s.cfgMu.RLock()
 		router, found := s.routes[host]
+		if !found {
+			if wildcard, ok := config.WildcardFor(host); ok {
+				router, found = s.routes[wildcard]
+			}
+		}
 		cors := s.cfg.Cors
+		capturing := s.capturingLocked()
 		s.cfgMu.RUnlock()
 		if !found {
 			http.NotFound(w, r)
@@ -57,12 +73,82 @@ func buildHandler(s *Server) http.Handler {
 			}
 		}
 
-		port, handler := router.match(r.URL.Path)
+		upstream, handler := router.match(r.URL.Path)
 		start := time.Now()
 		recorder := &statusRecorder{ResponseWriter: w, status: 200}
+
+		var capture *Capture
+		var reqBody *limitedBuffer
+		if capturing {
+			capture = &Capture{
+				Time:       start,
+				Domain:     host,
+				Method:     r.Method,
+				URI:        r.URL.RequestURI(),
+				RemoteAddr: r.RemoteAddr,
+				Request:    CapturedMessage{Header: r.Header.Clone()},
+			}
+			reqBody = teeRequestBody(r)
+			recorder.body = &limitedBuffer{limit: captureBodyLimit}
+		}
+
 		handler.ServeHTTP(recorder, r)
+		if recorder.upstream != "" {
+			upstream = recorder.upstream
+		}
+		duration := time.Since(start)
 
-		log.Request(host, r.Method, r.URL.RequestURI(), port, recorder.status, time.Since(start))
+		log.Request(host, r.Method, r.URL.RequestURI(), upstream, recorder.status, duration)
+		event.Publish(event.RequestCompleted, event.Request{
+			Domain:   host,
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Upstream: upstream,
+			Status:   recorder.status,
+			Duration: duration,
+		})
+		for _, failed := range recorder.failed {
+			s.observeUpstream(host, failed, false)
+		}
+		if upstream != "" && !slices.Contains(recorder.failed, upstream) {
+			s.observeUpstream(host, upstream, true)
+		}
+
+		if capture != nil {
+			capture.Upstream = upstream
+			capture.Status = recorder.status
+			capture.Duration = duration
+			capture.Request.Body, capture.Request.Truncated = reqBody.Bytes(), reqBody.truncated
+			capture.Response = CapturedMessage{
+				Header:    w.Header().Clone(),
+				Body:      recorder.body.Bytes(),
+				Truncated: recorder.body.truncated,
+			}
+			if hook, ok := r.Context().Value(replayKey{}).(*replayHook); ok {
+				capture.ReplayOf = hook.of
+				hook.capture = capture
+			}
+			s.captures.add(capture)
+		}
+	})
+}
+
+// rewritePath replaces matches of re in the request path before handing it
+// to next, in the same way http.StripPrefix swaps the URL.
+func rewritePath(re *regexp.Regexp, replacement string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := re.ReplaceAllString(r.URL.Path, replacement)
+		if p == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
 	})
 }
 
@@ -74,35 +160,83 @@ func setCORSHeaders(w http.ResponseWriter, origin string) {
 	w.Header().Set("Access-Control-Max-Age", "86400")
 }
 
-func newDomainProxy(port int, transport *http.Transport, cors bool) *httputil.ReverseProxy {
-	target := &url.URL{
-		Scheme: "http",
-		Host:   fmt.Sprintf("localhost:%d", port),
+// newDomainProxy forwards to target. Local upstreams keep the incoming Host
+// header so dev servers see the slim domain; remote ones get their own host
+// with the original passed along in X-Forwarded-Host. Header rules run last
+// so they can override either.
+func newDomainProxy(target *url.URL, transport *http.Transport, cors bool, headers *headerRewriter) *httputil.ReverseProxy {
+	local := isLocalUpstream(target)
+	dest := target
+	if target.Scheme == "unix" {
+		dest = &url.URL{Scheme: "http", Host: socketHost(target.Path)}
 	}
 
 	proxy := &httputil.ReverseProxy{
 		Transport: transport,
 		Rewrite: func(pr *httputil.ProxyRequest) {
-			pr.SetURL(target)
-			pr.Out.Host = pr.In.Host
+			pr.SetURL(dest)
+			if local {
+				pr.Out.Host = pr.In.Host
+			} else {
+				pr.Out.Header.Set("X-Forwarded-Host", pr.In.Host)
+			}
+			if headers != nil {
+				headers.rewriteRequest(pr)
+			}
 		},
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if !errors.Is(err, context.Canceled) {
+				markUpstreamFailed(w, upstreamLabel(target))
+			}
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusBadGateway)
 			_ = upstreamDownTmpl.Execute(w, upstreamDownData{
-				Host: normalizeHost(r.Host),
-				Port: port,
+				Host:   normalizeHost(r.Host),
+				Target: upstreamLabel(target),
 			})
 		},
 	}
 
-	if cors {
-		proxy.ModifyResponse = stripCORSHeaders
+	if cors || headers != nil {
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			if cors {
+				stripCORSHeaders(resp)
+			}
+			if headers != nil {
+				return headers.modifyResponse(resp)
+			}
+			return nil
+		}
 	}
 
 	return proxy
 }
 
+func isLocalUpstream(target *url.URL) bool {
+	if target.Scheme == "unix" {
+		return true
+	}
+	host := target.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func upstreamLabel(target *url.URL) string {
+	switch {
+	case target.Scheme == "unix":
+		return "unix:" + target.Path
+	case target.Scheme == "file":
+		return "dir:" + target.Path
+	case target.Scheme == "http" && target.Hostname() == "localhost":
+		return target.Port()
+	default:
+		return target.String()
+	}
+}
+
 func stripCORSHeaders(resp *http.Response) error {
 	h := resp.Header
 	h.Del("Access-Control-Allow-Origin")
@@ -132,8 +266,11 @@ func normalizeHost(host string) string {
 
 type statusRecorder struct {
 	http.ResponseWriter
-	status  int
-	written bool
+	status   int
+	written  bool
+	upstream string         // set by handlers that pick the upstream per request
+	failed   []string       // upstreams that could not be reached
+	body     *limitedBuffer // captures the response body for the inspector
 }
 
 func (r *statusRecorder) WriteHeader(code int) {
@@ -144,6 +281,13 @@ func (r *statusRecorder) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.body != nil {
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
 func (r *statusRecorder) Flush() {
 	if f, ok := r.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
@@ -156,4 +300,3 @@ func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	}
 	return nil, nil, fmt.Errorf("upstream ResponseWriter does not support hijacking")
 }
-
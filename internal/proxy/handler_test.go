@@ -1,10 +1,16 @@
 package proxy
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -23,7 +29,7 @@ func TestBuildHandlerRoutesKnownDomain(t *testing.T) {
 	port := mustPortFromURL(t, upstream.URL)
 	s := &Server{
 		cfg:    &config.Config{},
-		routes: map[string]*domainRouter{"myapp.test": {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), false)}},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)}},
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/health?x=1", nil)
@@ -60,9 +66,9 @@ func TestBuildHandlerRoutesCustomTLD(t *testing.T) {
 	s := &Server{
 		cfg: &config.Config{},
 		routes: map[string]*domainRouter{
-			"app.loc":     {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), false)},
-			"my.dev":      {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), false)},
-			"a.b.c":       {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), false)},
+			"app.loc": {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)},
+			"my.dev":  {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)},
+			"a.b.c":   {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)},
 		},
 	}
 
@@ -82,6 +88,95 @@ func TestBuildHandlerRoutesCustomTLD(t *testing.T) {
 	}
 }
 
+func TestBuildHandlerRemoteUpstreamUsesUpstreamHost(t *testing.T) {
+	type seen struct{ host, forwardedHost string }
+	seenCh := make(chan seen, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenCh <- seen{host: r.Host, forwardedHost: r.Header.Get("X-Forwarded-Host")}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	remote := &url.URL{Scheme: "http", Host: "remote.internal"}
+	transport := newUpstreamTransport()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, upstream.Listener.Addr().String())
+	}
+
+	s := &Server{
+		cfg:    &config.Config{},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: remote.String(), defaultHandler: newDomainProxy(remote, transport, false, nil)}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/", nil)
+	req.Host = "myapp.test"
+	rr := httptest.NewRecorder()
+
+	buildHandler(s).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rr.Code)
+	}
+	got := <-seenCh
+	if got.host != "remote.internal" {
+		t.Fatalf("expected upstream host %q, got %q", "remote.internal", got.host)
+	}
+	if got.forwardedHost != "myapp.test" {
+		t.Fatalf("expected X-Forwarded-Host %q, got %q", "myapp.test", got.forwardedHost)
+	}
+}
+
+func TestBuildHandlerRoutesUnixSocketUpstream(t *testing.T) {
+	dir, err := os.MkdirTemp("", "slim-sock-")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockPath := filepath.Join(dir, "app.sock")
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	hostCh := make(chan string, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostCh <- r.Host
+		_, _ = w.Write([]byte("socket"))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	target := &url.URL{Scheme: "unix", Path: sockPath}
+	s := &Server{
+		cfg:    &config.Config{},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: upstreamLabel(target), defaultHandler: newDomainProxy(target, newUpstreamTransport(), false, nil)}},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/", nil)
+	req.Host = "myapp.test"
+	rr := httptest.NewRecorder()
+
+	buildHandler(s).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "socket" {
+		t.Fatalf("expected 200 %q, got %d %q", "socket", rr.Code, rr.Body.String())
+	}
+	if gotHost := <-hostCh; gotHost != "myapp.test" {
+		t.Fatalf("expected upstream host %q, got %q", "myapp.test", gotHost)
+	}
+}
+
+func TestSocketHostRoundTrip(t *testing.T) {
+	path := "/var/run/" + strings.Repeat("deep/", 20) + "app.sock"
+	got, ok := socketPathFromAddr(socketHost(path) + ":80")
+	if !ok || got != path {
+		t.Fatalf("socketPathFromAddr round trip = %q, %v; want %q", got, ok, path)
+	}
+	if _, ok := socketPathFromAddr("localhost:3000"); ok {
+		t.Fatal("expected regular host not to decode as a socket")
+	}
+}
+
 func TestBuildHandlerUnknownDomainReturnsNotFound(t *testing.T) {
 	s := &Server{
 		cfg:    &config.Config{},
@@ -106,7 +201,7 @@ func TestBuildHandlerUpstreamDownReturnsBadGateway(t *testing.T) {
 	port := freeTCPPort(t)
 	s := &Server{
 		cfg:    &config.Config{},
-		routes: map[string]*domainRouter{"myapp.test": {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), false)}},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)}},
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/", nil)
@@ -125,37 +220,37 @@ func TestBuildHandlerUpstreamDownReturnsBadGateway(t *testing.T) {
 
 func TestDomainRouterMatch(t *testing.T) {
 	transport := newUpstreamTransport()
-	proxy := newDomainProxy(3000, transport, false)
+	proxy := newDomainProxy(localUpstream(3000), transport, false, nil)
 	router := &domainRouter{
-		defaultPort:    3000,
-		defaultHandler: proxy,
+		defaultUpstream: "3000",
+		defaultHandler:  proxy,
 		pathRoutes: []pathRoute{
-			{prefix: "/api/v2", port: 9090, handler: http.StripPrefix("/api/v2", newDomainProxy(9090, transport, false))},
-			{prefix: "/api", port: 8080, handler: http.StripPrefix("/api", newDomainProxy(8080, transport, false))},
-			{prefix: "/ws", port: 9000, handler: http.StripPrefix("/ws", newDomainProxy(9000, transport, false))},
+			{prefix: "/api/v2", upstream: "9090", handler: http.StripPrefix("/api/v2", newDomainProxy(localUpstream(9090), transport, false, nil))},
+			{prefix: "/api", upstream: "8080", handler: http.StripPrefix("/api", newDomainProxy(localUpstream(8080), transport, false, nil))},
+			{prefix: "/ws", upstream: "9000", handler: http.StripPrefix("/ws", newDomainProxy(localUpstream(9000), transport, false, nil))},
 		},
 	}
 
 	tests := []struct {
-		path     string
-		wantPort int
+		path         string
+		wantUpstream string
 	}{
-		{"/", 3000},
-		{"/about", 3000},
-		{"/api", 8080},
-		{"/api/users", 8080},
-		{"/api/v2", 9090},
-		{"/api/v2/items", 9090},
-		{"/apikeys", 3000},
-		{"/ws", 9000},
-		{"/ws/chat", 9000},
-		{"/other", 3000},
+		{"/", "3000"},
+		{"/about", "3000"},
+		{"/api", "8080"},
+		{"/api/users", "8080"},
+		{"/api/v2", "9090"},
+		{"/api/v2/items", "9090"},
+		{"/apikeys", "3000"},
+		{"/ws", "9000"},
+		{"/ws/chat", "9000"},
+		{"/other", "3000"},
 	}
 
 	for _, tt := range tests {
-		port, _ := router.match(tt.path)
-		if port != tt.wantPort {
-			t.Errorf("match(%q) = %d, want %d", tt.path, port, tt.wantPort)
+		upstream, _ := router.match(tt.path)
+		if upstream != tt.wantUpstream {
+			t.Errorf("match(%q) = %s, want %s", tt.path, upstream, tt.wantUpstream)
 		}
 	}
 }
@@ -173,10 +268,10 @@ func TestPathRouteStripsPrefix(t *testing.T) {
 		cfg: &config.Config{},
 		routes: map[string]*domainRouter{
 			"myapp.test": {
-				defaultPort:    3000,
-				defaultHandler: newDomainProxy(3000, newUpstreamTransport(), false),
+				defaultUpstream: "3000",
+				defaultHandler:  newDomainProxy(localUpstream(3000), newUpstreamTransport(), false, nil),
 				pathRoutes: []pathRoute{
-					{prefix: "/api", port: apiPort, handler: http.StripPrefix("/api", newDomainProxy(apiPort, newUpstreamTransport(), false))},
+					{prefix: "/api", upstream: strconv.Itoa(apiPort), handler: http.StripPrefix("/api", newDomainProxy(localUpstream(apiPort), newUpstreamTransport(), false, nil))},
 				},
 			},
 		},
@@ -209,6 +304,62 @@ func TestPathRouteStripsPrefix(t *testing.T) {
 	}
 }
 
+func TestApplyConfigRoutePathRewriting(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	pathCh := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pathCh <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	keep := false
+	cfg := &config.Config{Domains: []config.Domain{{
+		Name: "myapp.test",
+		Port: 3000,
+		Routes: []config.Route{
+			{Path: "/api", Upstream: upstream.URL, StripPrefix: &keep},
+			{Path: "/legacy", Upstream: upstream.URL, StripPrefix: &keep, Rewrite: &config.PathRewrite{Pattern: "^/legacy", Replace: "/v2"}},
+			{Path: "/old", Upstream: upstream.URL, Rewrite: &config.PathRewrite{Pattern: "^/users/([0-9]+)$", Replace: "/members/$1"}},
+		},
+	}}}
+
+	s := NewServer(&config.Config{})
+	if err := s.applyConfig(cfg); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+
+	tests := []struct {
+		reqPath  string
+		wantPath string
+	}{
+		{"/api/users", "/api/users"},
+		{"/api", "/api"},
+		{"/legacy/orders/7", "/v2/orders/7"},
+		{"/old/users/42", "/members/42"},
+		{"/old/users/42/posts", "/users/42/posts"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "https://myapp.test"+tt.reqPath, nil)
+		req.Host = "myapp.test"
+		buildHandler(s).ServeHTTP(httptest.NewRecorder(), req)
+
+		select {
+		case gotPath := <-pathCh:
+			if gotPath != tt.wantPath {
+				t.Errorf("request %s: upstream got path %q, want %q", tt.reqPath, gotPath, tt.wantPath)
+			}
+		default:
+			t.Errorf("request %s: upstream was not called", tt.reqPath)
+		}
+	}
+}
+
 func TestCORSHeadersNotAddedByDefault(t *testing.T) {
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://example.com")
@@ -220,7 +371,7 @@ func TestCORSHeadersNotAddedByDefault(t *testing.T) {
 	port := mustPortFromURL(t, upstream.URL)
 	s := &Server{
 		cfg:    &config.Config{},
-		routes: map[string]*domainRouter{"myapp.test": {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), false)}},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)}},
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/api", nil)
@@ -251,7 +402,7 @@ func TestCORSEnabledStripsUpstreamHeaders(t *testing.T) {
 	port := mustPortFromURL(t, upstream.URL)
 	s := &Server{
 		cfg:    &config.Config{Cors: true},
-		routes: map[string]*domainRouter{"myapp.test": {defaultPort: port, defaultHandler: newDomainProxy(port, newUpstreamTransport(), true)}},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: strconv.Itoa(port), defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), true, nil)}},
 	}
 
 	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/api", nil)
@@ -280,7 +431,7 @@ func TestCORSEnabledStripsUpstreamHeaders(t *testing.T) {
 func TestCORSEnabledHandlesPreflight(t *testing.T) {
 	s := &Server{
 		cfg:    &config.Config{Cors: true},
-		routes: map[string]*domainRouter{"myapp.test": {defaultPort: 3000, defaultHandler: newDomainProxy(3000, newUpstreamTransport(), true)}},
+		routes: map[string]*domainRouter{"myapp.test": {defaultUpstream: "3000", defaultHandler: newDomainProxy(localUpstream(3000), newUpstreamTransport(), true, nil)}},
 	}
 
 	req := httptest.NewRequest(http.MethodOptions, "https://myapp.test/api", nil)
@@ -298,6 +449,10 @@ func TestCORSEnabledHandlesPreflight(t *testing.T) {
 	}
 }
 
+func localUpstream(port int) *url.URL {
+	return &url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", port)}
+}
+
 func mustPortFromURL(t *testing.T, raw string) int {
 	t.Helper()
 
@@ -314,3 +469,45 @@ func mustPortFromURL(t *testing.T, raw string) int {
 	}
 	return port
 }
+
+func TestApplyConfigRoutesWildcardDomains(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	var certs []string
+	ensureLeafCertFn = func(name string) error { certs = append(certs, name); return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	s := NewServer(&config.Config{})
+	cfg := &config.Config{Domains: []config.Domain{
+		{Name: "*.myapp.test", Port: startNamedUpstream(t, "tenant")},
+		{Name: "admin.myapp.test", Port: startNamedUpstream(t, "admin")},
+	}}
+	if err := s.applyConfig(cfg); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if strings.Join(certs, ",") != "*.myapp.test,admin.myapp.test" {
+		t.Fatalf("unexpected certificates %v", certs)
+	}
+
+	tests := []struct {
+		host string
+		code int
+		body string
+	}{
+		{"tenant1.myapp.test", http.StatusOK, "tenant"},
+		{"tenant2.myapp.test", http.StatusOK, "tenant"},
+		{"admin.myapp.test", http.StatusOK, "admin"},
+		{"a.tenant1.myapp.test", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://"+tt.host+"/", nil)
+			req.Host = tt.host
+			rr := httptest.NewRecorder()
+			buildHandler(s).ServeHTTP(rr, req)
+			if rr.Code != tt.code || (tt.body != "" && rr.Body.String() != tt.body) {
+				t.Fatalf("expected %d %q, got %d %q", tt.code, tt.body, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+type headerVarsKey struct{}
+
+// headerRewriter applies header rules from the global config, the domain and
+// the route in that order, so the most specific layer wins.
+type headerRewriter struct {
+	layers []*config.Headers
+}
+
+func newHeaderRewriter(layers ...*config.Headers) *headerRewriter {
+	h := &headerRewriter{}
+	for _, l := range layers {
+		if l != nil {
+			h.layers = append(h.layers, l)
+		}
+	}
+	if len(h.layers) == 0 {
+		return nil
+	}
+	return h
+}
+
+func (h *headerRewriter) rewriteRequest(pr *httputil.ProxyRequest) {
+	vars := headerVars(pr.In)
+	for _, l := range h.layers {
+		for _, name := range l.Request.Remove {
+			pr.Out.Header.Del(name)
+		}
+		for name, value := range l.Request.Set {
+			value = vars.Replace(value)
+			if http.CanonicalHeaderKey(name) == "Host" {
+				pr.Out.Host = value
+				continue
+			}
+			pr.Out.Header.Set(name, value)
+		}
+	}
+	// The response hook only sees the outgoing request, so carry the values
+	// of the incoming one along for its templates.
+	pr.Out = pr.Out.WithContext(context.WithValue(pr.Out.Context(), headerVarsKey{}, vars))
+}
+
+func (h *headerRewriter) modifyResponse(resp *http.Response) error {
+	vars, _ := resp.Request.Context().Value(headerVarsKey{}).(*strings.Replacer)
+	if vars == nil {
+		vars = headerVars(resp.Request)
+	}
+	for _, l := range h.layers {
+		for _, name := range l.Response.Remove {
+			resp.Header.Del(name)
+		}
+		for name, value := range l.Response.Set {
+			resp.Header.Set(name, vars.Replace(value))
+		}
+	}
+	return nil
+}
+
+func headerVars(r *http.Request) *strings.Replacer {
+	remoteAddr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	return strings.NewReplacer(
+		"{host}", normalizeHost(r.Host),
+		"{remote_addr}", remoteAddr,
+	)
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func TestApplyConfigHeaderRules(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	seenCh := make(chan http.Header, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenCh <- r.Header.Clone()
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		w.Header().Set("X-Powered-By", "express")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Headers: &config.Headers{
+			Request:  config.HeaderRules{Set: map[string]string{"X-Env": "dev", "X-Forwarded-User": "global"}},
+			Response: config.HeaderRules{Remove: []string{"Strict-Transport-Security"}},
+		},
+		Domains: []config.Domain{{
+			Name:     "myapp.test",
+			Upstream: upstream.URL,
+			Headers: &config.Headers{
+				Request:  config.HeaderRules{Set: map[string]string{"X-Forwarded-User": "dev", "X-Client": "{remote_addr} via {host}"}, Remove: []string{"Cookie"}},
+				Response: config.HeaderRules{Set: map[string]string{"X-Served-By": "{host}"}},
+			},
+			Routes: []config.Route{{
+				Path:     "/api",
+				Upstream: upstream.URL,
+				Headers: &config.Headers{
+					Request:  config.HeaderRules{Remove: []string{"X-Env"}},
+					Response: config.HeaderRules{Remove: []string{"X-Powered-By"}},
+				},
+			}},
+		}},
+	}
+
+	s := NewServer(&config.Config{})
+	if err := s.applyConfig(cfg); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+
+	serve := func(path string) (*httptest.ResponseRecorder, http.Header) {
+		req := httptest.NewRequest(http.MethodGet, "https://myapp.test"+path, nil)
+		req.Host = "myapp.test"
+		req.RemoteAddr = "192.0.2.10:51234"
+		req.Header.Set("Cookie", "session=abc")
+		rr := httptest.NewRecorder()
+		buildHandler(s).ServeHTTP(rr, req)
+		return rr, <-seenCh
+	}
+
+	rr, seen := serve("/")
+	if got := seen.Get("X-Forwarded-User"); got != "dev" {
+		t.Errorf("expected domain rule to override global, got X-Forwarded-User %q", got)
+	}
+	if got := seen.Get("X-Env"); got != "dev" {
+		t.Errorf("expected global X-Env, got %q", got)
+	}
+	if got := seen.Get("X-Client"); got != "192.0.2.10 via myapp.test" {
+		t.Errorf("expected templated X-Client, got %q", got)
+	}
+	if seen.Get("Cookie") != "" {
+		t.Errorf("expected Cookie to be removed, got %q", seen.Get("Cookie"))
+	}
+	if rr.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("expected Strict-Transport-Security to be removed")
+	}
+	if got := rr.Header().Get("X-Served-By"); got != "myapp.test" {
+		t.Errorf("expected X-Served-By myapp.test, got %q", got)
+	}
+	if rr.Header().Get("X-Powered-By") == "" {
+		t.Errorf("expected X-Powered-By outside /api")
+	}
+
+	rr, seen = serve("/api/users")
+	if seen.Get("X-Env") != "" {
+		t.Errorf("expected route rule to remove X-Env, got %q", seen.Get("X-Env"))
+	}
+	if got := seen.Get("X-Forwarded-User"); got != "dev" {
+		t.Errorf("expected domain rules to apply to routes, got %q", got)
+	}
+	if rr.Header().Get("X-Powered-By") != "" {
+		t.Errorf("expected route rule to remove X-Powered-By")
+	}
+}
+
+func TestHeaderRuleSetsHost(t *testing.T) {
+	seenCh := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenCh <- r.Host
+	}))
+	defer upstream.Close()
+
+	port := upstream.Listener.Addr().(*net.TCPAddr).Port
+	headers := newHeaderRewriter(&config.Headers{Request: config.HeaderRules{Set: map[string]string{"Host": "api.{host}"}}})
+	handler := newDomainProxy(localUpstream(port), newUpstreamTransport(), false, headers)
+
+	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/", nil)
+	req.Host = "myapp.test"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := <-seenCh; got != "api.myapp.test" {
+		t.Fatalf("expected Host api.myapp.test, got %q", got)
+	}
+}
+
+func TestNewHeaderRewriterSkipsEmptyLayers(t *testing.T) {
+	if newHeaderRewriter(nil, nil) != nil {
+		t.Fatal("expected nil rewriter without any rules")
+	}
+}
@@ -3,14 +3,29 @@ package proxy
 import (
 	"fmt"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 )
 
 const upstreamPollInterval = 200 * time.Millisecond
 
-func CheckUpstream(port int) bool {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", port), 1*time.Second)
+func CheckUpstream(target *url.URL) bool {
+	if target == nil {
+		return false
+	}
+	if target.Scheme == "file" {
+		info, err := os.Stat(target.Path)
+		return err == nil && info.IsDir()
+	}
+	network, addr := upstreamDialAddr(target)
+	conn, err := net.DialTimeout(network, addr, 1*time.Second)
 	if err != nil {
 		return false
 	}
@@ -18,29 +33,51 @@ func CheckUpstream(port int) bool {
 	return true
 }
 
-func CheckUpstreams(ports []int) []bool {
-	results := make([]bool, len(ports))
+func CheckUpstreams(targets []*url.URL) []bool {
+	results := make([]bool, len(targets))
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 16)
-	for i, port := range ports {
+	for i, target := range targets {
 		wg.Add(1)
-		go func(idx int, p int) {
+		go func(idx int, u *url.URL) {
 			defer wg.Done()
 			sem <- struct{}{}
-			results[idx] = CheckUpstream(p)
+			results[idx] = CheckUpstream(u)
 			<-sem
-		}(i, port)
+		}(i, target)
 	}
 	wg.Wait()
 	return results
 }
 
-func WaitForUpstream(port int, timeout time.Duration) error {
+// CheckTargets reports the reachability of every upstream of each target,
+// with balanced targets expanded to one result per port.
+func CheckTargets(targets []config.Target) [][]bool {
+	var upstreams []*url.URL
+	for _, t := range targets {
+		for _, e := range t.Expand() {
+			u, _ := e.URL()
+			upstreams = append(upstreams, u)
+		}
+	}
+
+	health := CheckUpstreams(upstreams)
+	results := make([][]bool, len(targets))
+	idx := 0
+	for i, t := range targets {
+		n := len(t.Expand())
+		results[i] = health[idx : idx+n]
+		idx += n
+	}
+	return results
+}
+
+func WaitForUpstream(target *url.URL, timeout time.Duration) error {
 	if timeout <= 0 {
 		return fmt.Errorf("timeout must be greater than 0")
 	}
 
-	if CheckUpstream(port) {
+	if CheckUpstream(target) {
 		return nil
 	}
 
@@ -53,11 +90,46 @@ func WaitForUpstream(port int, timeout time.Duration) error {
 	for {
 		select {
 		case <-ticker.C:
-			if CheckUpstream(port) {
+			if CheckUpstream(target) {
 				return nil
 			}
 		case <-timer.C:
-			return fmt.Errorf("upstream localhost:%d did not become reachable within %s", port, timeout)
+			_, addr := upstreamDialAddr(target)
+			return fmt.Errorf("upstream %s did not become reachable within %s", addr, timeout)
 		}
 	}
 }
+
+func upstreamDialAddr(target *url.URL) (string, string) {
+	switch {
+	case target.Scheme == "unix":
+		return "unix", target.Path
+	case target.Scheme == "file":
+		return "file", target.Path
+	case target.Port() != "":
+		return "tcp", target.Host
+	case target.Scheme == "https":
+		return "tcp", net.JoinHostPort(target.Hostname(), "443")
+	default:
+		return "tcp", net.JoinHostPort(target.Hostname(), "80")
+	}
+}
+
+// markUpstreamFailed notes on the request's recorder that an upstream could
+// not be reached, so the handler can report the change in health.
+func markUpstreamFailed(w http.ResponseWriter, label string) {
+	if rec, ok := w.(*statusRecorder); ok && !slices.Contains(rec.failed, label) {
+		rec.failed = append(rec.failed, label)
+	}
+}
+
+// observeUpstream records how a request to an upstream went and publishes
+// an event when that flips its health. Upstreams start out healthy.
+func (s *Server) observeUpstream(domain, label string, healthy bool) {
+	prev, loaded := s.upstreamDown.Swap(label, !healthy)
+	wasHealthy := !loaded || !prev.(bool)
+	if wasHealthy == healthy {
+		return
+	}
+	event.Publish(event.HealthChanged, event.Health{Domain: domain, Upstream: label, Healthy: healthy})
+}
@@ -1,10 +1,21 @@
 package proxy
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/event"
 )
 
 func TestWaitForUpstreamReadyImmediately(t *testing.T) {
@@ -15,7 +26,7 @@ func TestWaitForUpstreamReadyImmediately(t *testing.T) {
 	defer ln.Close()
 
 	port := ln.Addr().(*net.TCPAddr).Port
-	if err := WaitForUpstream(port, 500*time.Millisecond); err != nil {
+	if err := WaitForUpstream(localUpstream(port), 500*time.Millisecond); err != nil {
 		t.Fatalf("WaitForUpstream unexpected error: %v", err)
 	}
 }
@@ -38,7 +49,7 @@ func TestWaitForUpstreamBecomesReady(t *testing.T) {
 		time.Sleep(250 * time.Millisecond)
 	}()
 
-	if err := WaitForUpstream(port, 2*time.Second); err != nil {
+	if err := WaitForUpstream(localUpstream(port), 2*time.Second); err != nil {
 		t.Fatalf("WaitForUpstream unexpected error: %v", err)
 	}
 }
@@ -51,13 +62,108 @@ func TestWaitForUpstreamTimeout(t *testing.T) {
 	port := ln.Addr().(*net.TCPAddr).Port
 	ln.Close()
 
-	if err := WaitForUpstream(port, 300*time.Millisecond); err == nil {
+	if err := WaitForUpstream(localUpstream(port), 300*time.Millisecond); err == nil {
 		t.Fatal("expected timeout error, got nil")
 	}
 }
 
 func TestWaitForUpstreamInvalidTimeout(t *testing.T) {
-	if err := WaitForUpstream(3000, 0); err == nil {
+	if err := WaitForUpstream(localUpstream(3000), 0); err == nil {
 		t.Fatal("expected error for invalid timeout")
 	}
 }
+
+func TestCheckUpstreamUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "slim-sock-")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := &url.URL{Scheme: "unix", Path: filepath.Join(dir, "app.sock")}
+	if CheckUpstream(target) {
+		t.Fatal("expected missing socket to be unreachable")
+	}
+
+	ln, err := net.Listen("unix", target.Path)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	defer ln.Close()
+
+	if err := WaitForUpstream(target, 500*time.Millisecond); err != nil {
+		t.Fatalf("WaitForUpstream unexpected error: %v", err)
+	}
+}
+
+func TestCheckUpstreamStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	if !CheckUpstream(&url.URL{Scheme: "file", Path: dir}) {
+		t.Fatal("expected existing dir to be healthy")
+	}
+	if CheckUpstream(&url.URL{Scheme: "file", Path: filepath.Join(dir, "missing")}) {
+		t.Fatal("expected missing dir to be unhealthy")
+	}
+}
+
+func TestHandlerPublishesUpstreamHealthChanges(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	s := NewServer(&config.Config{})
+	if err := s.applyConfig(&config.Config{Domains: []config.Domain{{Name: "myapp.test", Socket: sock}}}); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+
+	sub := event.Subscribe(event.HealthChanged)
+	defer sub.Close()
+	send := func() int {
+		req := httptest.NewRequest(http.MethodGet, "https://myapp.test/", nil)
+		rr := httptest.NewRecorder()
+		buildHandler(s).ServeHTTP(rr, req)
+		return rr.Code
+	}
+	healthEvents := func() []event.Health {
+		var got []event.Health
+		for {
+			select {
+			case e := <-sub.Events():
+				var h event.Health
+				if err := json.Unmarshal(e.Data, &h); err != nil {
+					t.Fatalf("Unmarshal: %v", err)
+				}
+				got = append(got, h)
+			default:
+				return got
+			}
+		}
+	}
+
+	if code := send(); code != http.StatusBadGateway {
+		t.Fatalf("expected 502 while the upstream is down, got %d", code)
+	}
+	send()
+	want := []event.Health{{Domain: "myapp.test", Upstream: "unix:" + sock, Healthy: false}}
+	if got := healthEvents(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected one down event, got %+v", got)
+	}
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	upstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	go upstream.Serve(ln)
+	defer upstream.Close()
+
+	if code := send(); code != http.StatusOK {
+		t.Fatalf("expected 200 once the upstream is up, got %d", code)
+	}
+	want[0].Healthy = true
+	if got := healthEvents(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected one recovery event, got %+v", got)
+	}
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:embed inspector.html
+var inspectorHTML []byte
+
+type captureSummary struct {
+	ID       uint64        `json:"id"`
+	Time     time.Time     `json:"time"`
+	Domain   string        `json:"domain"`
+	Method   string        `json:"method"`
+	URI      string        `json:"uri"`
+	Upstream string        `json:"upstream"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration"`
+	ReplayOf uint64        `json:"replay_of,omitempty"`
+}
+
+// newInspectorHandler serves the inspector UI and its JSON API. Captured
+// traffic can contain credentials, so only loopback clients are served.
+func newInspectorHandler(captures *captureLog, replay func(uint64, ReplayOptions) (*ReplayResult, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(inspectorHTML)
+	})
+	mux.HandleFunc("GET /api/requests", func(w http.ResponseWriter, r *http.Request) {
+		since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		summaries := []captureSummary{}
+		for _, c := range captures.since(since) {
+			summaries = append(summaries, captureSummary{
+				ID:       c.ID,
+				Time:     c.Time,
+				Domain:   c.Domain,
+				Method:   c.Method,
+				URI:      c.URI,
+				Upstream: c.Upstream,
+				Status:   c.Status,
+				Duration: c.Duration,
+				ReplayOf: c.ReplayOf,
+			})
+		}
+		writeJSON(w, http.StatusOK, summaries)
+	})
+	mux.HandleFunc("GET /api/requests/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid request id", http.StatusBadRequest)
+			return
+		}
+		c, ok := captures.get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+	})
+	mux.HandleFunc("POST /api/requests/{id}/replay", func(w http.ResponseWriter, r *http.Request) {
+		// Replays have side effects, so refuse anything a cross-site page
+		// could send: a JSON body forces a preflight we never answer.
+		if origin := r.Header.Get("Origin"); origin != "" && origin != "https://"+r.Host {
+			http.Error(w, "cross-origin replay is not allowed", http.StatusForbidden)
+			return
+		}
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+			http.Error(w, "expected a JSON body", http.StatusUnsupportedMediaType)
+			return
+		}
+		if replay == nil {
+			http.NotFound(w, r)
+			return
+		}
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid request id", http.StatusBadRequest)
+			return
+		}
+		var opts ReplayOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+			http.Error(w, "invalid replay options: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := replay(id, opts)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			http.Error(w, "the inspector is only available from this machine", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
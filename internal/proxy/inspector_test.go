@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func TestBuildHandlerCapturesTraffic(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("got " + string(body)))
+	}))
+	defer upstream.Close()
+
+	port := mustPortFromURL(t, upstream.URL)
+	captures := newCaptureLog(10)
+	s := &Server{
+		cfg:       &config.Config{},
+		routes:    map[string]*domainRouter{"myapp.test": {defaultUpstream: "app", defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)}},
+		captures:  captures,
+		inspector: newInspectorHandler(captures, nil),
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "https://myapp.test/hooks?x=1", strings.NewReader(`{"event":"push"}`))
+	req.Host = "myapp.test"
+	req.Header.Set("X-Signature", "abc")
+	rr := httptest.NewRecorder()
+	buildHandler(s).ServeHTTP(rr, req)
+
+	if rr.Body.String() != `got {"event":"push"}` {
+		t.Fatalf("expected upstream to receive the full body, got %q", rr.Body.String())
+	}
+
+	got := captures.since(0)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 capture, got %d", len(got))
+	}
+	c := got[0]
+	if c.Domain != "myapp.test" || c.Method != http.MethodPost || c.URI != "/hooks?x=1" || c.Upstream != "app" || c.Status != http.StatusCreated {
+		t.Fatalf("unexpected capture %+v", c)
+	}
+	if c.Request.Header.Get("X-Signature") != "abc" || string(c.Request.Body) != `{"event":"push"}` {
+		t.Fatalf("unexpected captured request %+v", c.Request)
+	}
+	if c.Response.Header.Get("X-Upstream") != "yes" || string(c.Response.Body) != `got {"event":"push"}` {
+		t.Fatalf("unexpected captured response %+v", c.Response)
+	}
+}
+
+func TestBuildHandlerSkipsCaptureWhenLogModeOff(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("got " + string(body)))
+	}))
+	defer upstream.Close()
+
+	port := mustPortFromURL(t, upstream.URL)
+	captures := newCaptureLog(10)
+	s := &Server{
+		cfg:      &config.Config{LogMode: config.LogModeOff},
+		routes:   map[string]*domainRouter{"myapp.test": {defaultUpstream: "app", defaultHandler: newDomainProxy(localUpstream(port), newUpstreamTransport(), false, nil)}},
+		captures: captures,
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "https://myapp.test/hooks", strings.NewReader("secret"))
+	req.Host = "myapp.test"
+	rr := httptest.NewRecorder()
+	buildHandler(s).ServeHTTP(rr, req)
+
+	if rr.Body.String() != "got secret" {
+		t.Fatalf("expected the request to be proxied, got %q", rr.Body.String())
+	}
+	if got := captures.since(0); len(got) != 0 {
+		t.Fatalf("expected nothing to be captured, got %d", len(got))
+	}
+	if _, err := s.Replay(1, ReplayOptions{}); err == nil || !strings.Contains(err.Error(), "capture is disabled") {
+		t.Fatalf("expected replay to be disabled, got %v", err)
+	}
+}
+
+func TestInspectorAPI(t *testing.T) {
+	captures := newCaptureLog(10)
+	captures.add(&Capture{Domain: "myapp.test", Method: http.MethodGet, URI: "/", Status: 200, Response: CapturedMessage{Body: []byte("hello")}})
+	captures.add(&Capture{Domain: "api.test", Method: http.MethodPost, URI: "/users", Status: 500})
+	s := &Server{cfg: &config.Config{}, captures: captures, inspector: newInspectorHandler(captures, nil)}
+	handler := buildHandler(s)
+
+	serve := func(path string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "https://"+config.InspectorDomain+path, nil)
+		req.Host = config.InspectorDomain
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("/", "127.0.0.1:5000"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "slim inspector") {
+		t.Fatalf("expected inspector page, got %d", rr.Code)
+	}
+
+	rr := serve("/api/requests?since=1", "127.0.0.1:5000")
+	var list []captureSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decoding list: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != 2 || list[0].Domain != "api.test" {
+		t.Fatalf("unexpected list %+v", list)
+	}
+
+	rr = serve("/api/requests/1", "[::1]:5000")
+	var detail Capture
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decoding detail: %v", err)
+	}
+	if string(detail.Response.Body) != "hello" {
+		t.Fatalf("unexpected detail %+v", detail)
+	}
+
+	if rr := serve("/api/requests/99", "127.0.0.1:5000"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for unknown id, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := serve("/api/requests", "192.168.1.20:5000"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected %d for remote clients, got %d", http.StatusForbidden, rr.Code)
+	}
+	if len(captures.since(0)) != 2 {
+		t.Fatal("expected inspector requests not to be captured")
+	}
+}
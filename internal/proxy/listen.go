@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+var loopbackProbes = []struct {
+	family string
+	ip     net.IP
+}{
+	{"IPv4", net.IPv4(127, 0, 0, 1)},
+	{"IPv6", net.IPv6loopback},
+}
+
+// unreachableLoopbacks dials a listener bound to all interfaces over each
+// loopback family and reports the ones that fail. Listeners bound to a
+// specific address are not probed.
+func unreachableLoopbacks(addr net.Addr) map[string]error {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return nil
+	}
+
+	failed := map[string]error{}
+	for _, probe := range loopbackProbes {
+		target := net.JoinHostPort(probe.ip.String(), fmt.Sprintf("%d", tcpAddr.Port))
+		conn, err := net.DialTimeout("tcp", target, 500*time.Millisecond)
+		if err != nil {
+			failed[probe.family] = err
+			continue
+		}
+		_ = conn.Close()
+	}
+	return failed
+}
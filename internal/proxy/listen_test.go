@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestUnreachableLoopbacks(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	failed := unreachableLoopbacks(ln.Addr())
+	if err, ok := failed["IPv4"]; ok {
+		t.Fatalf("expected IPv4 loopback to reach a wildcard listener: %v", err)
+	}
+	if v6, err := net.Listen("tcp6", "[::1]:0"); err == nil {
+		v6.Close()
+		if err, ok := failed["IPv6"]; ok {
+			t.Fatalf("expected IPv6 loopback to reach a wildcard listener: %v", err)
+		}
+	}
+
+	v4, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer v4.Close()
+	if failed := unreachableLoopbacks(v4.Addr()); failed != nil {
+		t.Fatalf("expected listeners on a specific address to be skipped, got %v", failed)
+	}
+}
@@ -11,6 +11,6 @@ var upstreamDownHTML string
 var upstreamDownTmpl = template.Must(template.New("upstream_down").Parse(upstreamDownHTML))
 
 type upstreamDownData struct {
-	Host string
-	Port int
+	Host   string
+	Target string
 }
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const replayTimeout = 25 * time.Second
+
+// ReplayOptions edits a captured request before it is sent again. Header,
+// when set, replaces the captured headers; Set and Remove then adjust
+// single headers. Body, when set, replaces the captured body.
+type ReplayOptions struct {
+	Header http.Header       `json:"header,omitempty"`
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+	Body   *[]byte           `json:"body,omitempty"`
+}
+
+type ReplayResult struct {
+	Original *Capture `json:"original"`
+	Replay   *Capture `json:"replay"`
+	Diff     []string `json:"diff"`
+	Changed  bool     `json:"changed"`
+}
+
+type replayKey struct{}
+
+// replayHook links the capture of a replayed request back to the original.
+type replayHook struct {
+	of      uint64
+	capture *Capture
+}
+
+type replayWriter struct {
+	header http.Header
+}
+
+func (w *replayWriter) Header() http.Header         { return w.header }
+func (w *replayWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *replayWriter) WriteHeader(int)             {}
+
+// Replay re-issues a captured request through the same routing as live
+// traffic and diffs the new response against the original one.
+func (s *Server) Replay(id uint64, opts ReplayOptions) (*ReplayResult, error) {
+	s.cfgMu.RLock()
+	capturing := s.capturingLocked()
+	s.cfgMu.RUnlock()
+	if !capturing {
+		return nil, fmt.Errorf("request capture is disabled")
+	}
+	original, ok := s.captures.get(id)
+	if !ok {
+		return nil, fmt.Errorf("request #%d is no longer in the buffer", id)
+	}
+
+	body := original.Request.Body
+	if opts.Body != nil {
+		body = *opts.Body
+	} else if original.Request.Truncated {
+		return nil, fmt.Errorf("the body of request #%d was truncated when captured; pass a body to replay it", id)
+	}
+
+	hook := &replayHook{of: id}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), replayKey{}, hook), replayTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, original.Method, "https://"+original.Domain+original.URI, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building replay request: %w", err)
+	}
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	req.Host = original.Domain
+	req.RemoteAddr = original.RemoteAddr
+	req.RequestURI = original.URI
+	req.TLS = &tls.ConnectionState{ServerName: original.Domain}
+
+	req.Header = original.Request.Header.Clone()
+	if opts.Header != nil {
+		req.Header = opts.Header.Clone()
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for name, value := range opts.Set {
+		req.Header.Set(name, value)
+	}
+	for _, name := range opts.Remove {
+		req.Header.Del(name)
+	}
+	req.Header.Del("Content-Length")
+	req.Header.Del("Transfer-Encoding")
+
+	buildHandler(s).ServeHTTP(&replayWriter{header: http.Header{}}, req)
+	if hook.capture == nil {
+		return nil, fmt.Errorf("%s is no longer configured", original.Domain)
+	}
+
+	diff, changed := diffLines(responseLines(original), responseLines(hook.capture))
+	return &ReplayResult{Original: original, Replay: hook.capture, Diff: diff, Changed: changed}, nil
+}
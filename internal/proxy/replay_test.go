@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func replayServer(t *testing.T, handler http.HandlerFunc) *Server {
+	t.Helper()
+	upstream := httptest.NewServer(handler)
+	t.Cleanup(upstream.Close)
+
+	s := &Server{
+		cfg:      &config.Config{},
+		routes:   map[string]*domainRouter{"myapp.test": {defaultUpstream: "app", defaultHandler: newDomainProxy(localUpstream(mustPortFromURL(t, upstream.URL)), newUpstreamTransport(), false, nil)}},
+		captures: newCaptureLog(10),
+	}
+	s.inspector = newInspectorHandler(s.captures, s.Replay)
+	return s
+}
+
+func sendCaptured(s *Server, method, target, body string) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Host = "myapp.test"
+	req.Header.Set("X-Token", "one")
+	buildHandler(s).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestReplayReissuesCapturedRequest(t *testing.T) {
+	calls := 0
+	s := replayServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s token=%s body=%s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Token"), body)
+	})
+	sendCaptured(s, http.MethodPost, "https://myapp.test/hooks?x=1", "payload")
+
+	result, err := s.Replay(1, ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected the upstream to be called twice, got %d", calls)
+	}
+	if result.Changed {
+		t.Fatalf("expected identical responses, got diff %v", result.Diff)
+	}
+	if result.Replay.ReplayOf != 1 || result.Replay.ID != 2 {
+		t.Fatalf("expected replay #2 of #1, got %+v", result.Replay)
+	}
+	if got := string(result.Replay.Response.Body); got != "POST /hooks?x=1 token=one body=payload" {
+		t.Fatalf("unexpected replayed response %q", got)
+	}
+	if c, ok := s.captures.get(2); !ok || c != result.Replay {
+		t.Fatal("expected the replay to be captured")
+	}
+}
+
+func TestReplayWithEdits(t *testing.T) {
+	s := replayServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "token=%s extra=%s body=%s", r.Header.Get("X-Token"), r.Header.Get("X-Extra"), body)
+	})
+	sendCaptured(s, http.MethodPost, "https://myapp.test/", "old")
+
+	body := []byte("new")
+	result, err := s.Replay(1, ReplayOptions{
+		Set:    map[string]string{"X-Extra": "yes"},
+		Remove: []string{"X-Token"},
+		Body:   &body,
+	})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if got := string(result.Replay.Response.Body); got != "token= extra=yes body=new" {
+		t.Fatalf("unexpected replayed response %q", got)
+	}
+	if !result.Changed {
+		t.Fatal("expected the response to differ")
+	}
+	if !slices.Contains(result.Diff, "- token=one extra= body=old") || !slices.Contains(result.Diff, "+ token= extra=yes body=new") {
+		t.Fatalf("unexpected diff %v", result.Diff)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	s := replayServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	s.captures.add(&Capture{Domain: "myapp.test", Method: http.MethodPost, URI: "/", Request: CapturedMessage{Truncated: true}})
+	s.captures.add(&Capture{Domain: "gone.test", Method: http.MethodGet, URI: "/"})
+
+	tests := []struct {
+		name string
+		id   uint64
+		want string
+	}{
+		{"unknown id", 99, "no longer in the buffer"},
+		{"truncated body", 1, "was truncated"},
+		{"removed domain", 2, "no longer configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Replay(tt.id, ReplayOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    []string
+		want    []string
+		changed bool
+	}{
+		{"equal", []string{"x", "y"}, []string{"x", "y"}, []string{"  x", "  y"}, false},
+		{"changed line", []string{"x", "y", "z"}, []string{"x", "Y", "z"}, []string{"  x", "- y", "+ Y", "  z"}, true},
+		{"added line", []string{"x"}, []string{"x", "y"}, []string{"  x", "+ y"}, true},
+		{"removed line", []string{"x", "y"}, []string{"y"}, []string{"- x", "  y"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := diffLines(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) || changed != tt.changed {
+				t.Fatalf("got %v (%v), want %v (%v)", got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestResponseLinesSkipsDateAndIndentsJSON(t *testing.T) {
+	c := &Capture{Status: 200, Response: CapturedMessage{
+		Header: http.Header{"Date": {"now"}, "Content-Type": {"application/json"}},
+		Body:   []byte(`{"a":1}`),
+	}}
+	want := []string{"HTTP 200 OK", "Content-Type: application/json", "", "{", `  "a": 1`, "}"}
+	if got := responseLines(c); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestInspectorReplayAPI(t *testing.T) {
+	s := replayServer(t, func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "ok") })
+	sendCaptured(s, http.MethodGet, "https://myapp.test/", "")
+
+	tests := []struct {
+		name        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"same origin", "https://slim.test", "application/json", http.StatusOK},
+		{"no origin", "", "application/json", http.StatusOK},
+		{"cross origin", "https://evil.example", "application/json", http.StatusForbidden},
+		{"form post", "", "text/plain", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://slim.test/api/requests/1/replay", strings.NewReader("{}"))
+			req.Host = config.InspectorDomain
+			req.RemoteAddr = "127.0.0.1:5000"
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+			buildHandler(s).ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -3,24 +3,28 @@ package proxy
 import (
 	"context"
 	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/cert"
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/dns"
 	"github.com/kamranahmedse/slim/internal/log"
 	"golang.org/x/net/http2"
 	"golang.org/x/sync/singleflight"
 )
 
 var (
-	HTTPAddr  = fmt.Sprintf(":%d", config.ProxyHTTPPort)
-	HTTPSAddr = fmt.Sprintf(":%d", config.ProxyHTTPSPort)
-
 	ensureLeafCertFn = cert.EnsureLeafCert
 	loadLeafTLSFn    = cert.LoadLeafTLS
 )
@@ -36,36 +40,93 @@ type Server struct {
 	httpServer    *http.Server
 	tlsServer     *http.Server
 	transport     *http.Transport
+	tlsTransports map[tlsProfile]*http.Transport
 	certCache     map[string]*tls.Certificate
 	certMu        sync.RWMutex
 	certGroup     singleflight.Group
+	captures      *captureLog
+	inspector     http.Handler
+	resolver      *dns.Server
+	dnsAddr       string
+	upstreamDown  sync.Map // upstream label → whether its last request failed
+	unknownMu     sync.Mutex
+	loggedUnknown map[string]struct{} // unknown SNI names already logged
 }
 
 func NewServer(cfg *config.Config) *Server {
-	return &Server{
+	s := &Server{
 		cfg:          cfg,
-		httpAddr:     HTTPAddr,
-		httpsAddr:    HTTPSAddr,
+		httpAddr:     fmt.Sprintf(":%d", cfg.HTTPListenPort()),
+		httpsAddr:    fmt.Sprintf(":%d", cfg.HTTPSListenPort()),
 		transport:    newUpstreamTransport(),
 		routes:       make(map[string]*domainRouter),
 		knownDomains: make(map[string]struct{}),
 		certCache:    make(map[string]*tls.Certificate),
+		captures:     newCaptureLog(captureCapacity),
+		resolver:     dns.NewServer(),
+		dnsAddr:      dns.Addr,
 	}
+	s.inspector = newInspectorHandler(s.captures, s.Replay)
+	return s
 }
 
+// maxLoggedUnknownNames bounds the unknown names remembered as logged.
+// Past it the set starts over, so scanners trying many names can't grow it
+// without limit.
+const maxLoggedUnknownNames = 1000
+
+var errNotConfigured = errors.New("not configured")
+
+// getCertificate serves the certificate for a TLS handshake, logging why
+// it can't, since the client only sees a failed handshake.
 func (s *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	tlsCert, err := s.certificateFor(hello)
+	if err != nil && s.shouldLogHandshakeError(hello.ServerName, err) {
+		log.Error("TLS handshake for %q from %s failed: %v", hello.ServerName, remoteAddr(hello), err)
+	}
+	return tlsCert, err
+}
+
+// shouldLogHandshakeError logs a name that isn't configured only once until
+// the config changes. Scanners, typos and IP-only connections would
+// otherwise add a line per handshake.
+func (s *Server) shouldLogHandshakeError(name string, err error) bool {
+	if !errors.Is(err, errNotConfigured) {
+		return true
+	}
+	s.unknownMu.Lock()
+	defer s.unknownMu.Unlock()
+	if _, ok := s.loggedUnknown[name]; ok {
+		return false
+	}
+	if s.loggedUnknown == nil || len(s.loggedUnknown) >= maxLoggedUnknownNames {
+		s.loggedUnknown = make(map[string]struct{})
+	}
+	s.loggedUnknown[name] = struct{}{}
+	return true
+}
+
+func remoteAddr(hello *tls.ClientHelloInfo) string {
+	if hello.Conn == nil {
+		return "unknown"
+	}
+	return hello.Conn.RemoteAddr().String()
+}
+
+func (s *Server) certificateFor(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	var name string
 	if hello.ServerName == "" {
 		name = s.defaultConfiguredDomain()
 		if name == "" {
-			return nil, fmt.Errorf("no domains configured")
+			return nil, fmt.Errorf("no domains %w", errNotConfigured)
 		}
 	} else {
 		name = normalizeHost(hello.ServerName)
 	}
 
-	if !s.isKnownDomain(name) {
-		return nil, fmt.Errorf("domain %s is not configured", name)
+	name, ok := s.resolveDomain(name)
+	if !ok {
+		return nil, fmt.Errorf("domain %s is %w", normalizeHost(hello.ServerName), errNotConfigured)
 	}
 
 	if tlsCert := s.cachedCertificate(name); tlsCert != nil {
@@ -117,8 +178,7 @@ func (s *Server) Start() error {
 		WriteTimeout:      10 * time.Second,
 		IdleTimeout:       2 * time.Hour,
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			target := "https://" + r.Host + r.URL.RequestURI()
-			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			http.Redirect(w, r, s.httpsRedirectURL(r), http.StatusMovedPermanently)
 		}),
 	}
 
@@ -151,15 +211,20 @@ func (s *Server) Start() error {
 
 	log.Info("HTTP  listening on %s (redirects to HTTPS)", s.httpAddr)
 	log.Info("HTTPS listening on %s", s.httpsAddr)
+	// Probing the plain HTTP listener keeps handshake errors out of the log;
+	// both listeners are bound the same way.
+	for family, err := range unreachableLoopbacks(httpLn.Addr()) {
+		log.Error("%s loopback cannot reach %s: %v", family, s.httpAddr, err)
+	}
 
 	s.cfgMu.RLock()
 	domains := append([]config.Domain(nil), s.cfg.Domains...)
 	s.cfgMu.RUnlock()
 
 	for _, d := range domains {
-		log.Info("  %s → localhost:%d", d.Name, d.Port)
+		log.Info("  %s → %s", d.Name, d.Target())
 		for _, r := range d.Routes {
-			log.Info("    %s → localhost:%d", r.Path, r.Port)
+			log.Info("    %s → %s", r.Path, r.Target())
 		}
 	}
 
@@ -176,6 +241,15 @@ func (s *Server) Start() error {
 	return retErr
 }
 
+// httpsRedirectURL points a plain HTTP request at the same URL over HTTPS,
+// on whichever port clients reach the HTTPS listener.
+func (s *Server) httpsRedirectURL(r *http.Request) string {
+	s.cfgMu.RLock()
+	cfg := s.cfg
+	s.cfgMu.RUnlock()
+	return cfg.URL(normalizeHost(r.Host)) + r.URL.RequestURI()
+}
+
 func (s *Server) Shutdown(ctx context.Context) error {
 	var firstErr error
 
@@ -189,6 +263,11 @@ func (s *Server) Shutdown(ctx context.Context) error {
 			firstErr = err
 		}
 	}
+	if s.resolver != nil {
+		if err := s.resolver.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 
 	return firstErr
 }
@@ -198,18 +277,26 @@ func (s *Server) ReloadConfig() (*config.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	if err := s.applyConfig(cfg); err != nil {
+	if err := s.ApplyConfig(cfg); err != nil {
 		return nil, err
 	}
-
 	return cfg, nil
 }
 
+// ApplyConfig serves cfg without reading the config file, so a change can
+// be tried before it is saved.
+func (s *Server) ApplyConfig(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	return s.applyConfig(cfg)
+}
+
 func (s *Server) applyConfig(cfg *config.Config) error {
 	routes := make(map[string]*domainRouter, len(cfg.Domains))
 	knownDomains := make(map[string]struct{}, len(cfg.Domains))
 	certCache := make(map[string]*tls.Certificate, len(cfg.Domains))
+	transports := make(map[tlsProfile]*http.Transport)
 	defaultDomain := ""
 
 	for i, d := range cfg.Domains {
@@ -225,16 +312,34 @@ func (s *Server) applyConfig(cfg *config.Config) error {
 			return fmt.Errorf("loading cert for %s: %w", d.Name, err)
 		}
 
+		label, handler, err := s.targetHandler(d.Target(), "/", transports, cfg.Cors, newHeaderRewriter(cfg.Headers, d.Headers))
+		if err != nil {
+			return fmt.Errorf("domain %s: %w", d.Name, err)
+		}
 		router := &domainRouter{
-			defaultPort:    d.Port,
-			defaultHandler: newDomainProxy(d.Port, s.transport, cfg.Cors),
+			defaultUpstream: label,
+			defaultHandler:  handler,
 		}
 
 		for _, r := range d.Routes {
+			routeLabel, routeHandler, err := s.targetHandler(r.Target(), r.Path, transports, cfg.Cors, newHeaderRewriter(cfg.Headers, d.Headers, r.Headers))
+			if err != nil {
+				return fmt.Errorf("domain %s route %s: %w", d.Name, r.Path, err)
+			}
+			if r.Rewrite != nil {
+				re, err := r.Rewrite.Compile()
+				if err != nil {
+					return fmt.Errorf("domain %s route %s: %w", d.Name, r.Path, err)
+				}
+				routeHandler = rewritePath(re, r.Rewrite.Replace, routeHandler)
+			}
+			if r.ShouldStripPrefix() {
+				routeHandler = http.StripPrefix(r.Path, routeHandler)
+			}
 			router.pathRoutes = append(router.pathRoutes, pathRoute{
-				prefix:  r.Path,
-				port:    r.Port,
-				handler: http.StripPrefix(r.Path, newDomainProxy(r.Port, s.transport, cfg.Cors)),
+				prefix:   r.Path,
+				upstream: routeLabel,
+				handler:  routeHandler,
 			})
 		}
 		sort.Slice(router.pathRoutes, func(i, j int) bool {
@@ -251,15 +356,115 @@ func (s *Server) applyConfig(cfg *config.Config) error {
 	s.routes = routes
 	s.knownDomains = knownDomains
 	s.defaultDomain = defaultDomain
+	previous := s.tlsTransports
+	s.tlsTransports = transports
 	s.cfgMu.Unlock()
 
+	for _, t := range previous {
+		t.CloseIdleConnections()
+	}
+
 	s.certMu.Lock()
 	s.certCache = certCache
 	s.certMu.Unlock()
 
+	s.unknownMu.Lock()
+	s.loggedUnknown = nil
+	s.unknownMu.Unlock()
+
+	if s.captures != nil && cfg.EffectiveLogMode() == config.LogModeOff {
+		s.captures.clear()
+	}
+
+	return s.syncResolver(cfg)
+}
+
+// capturingLocked reports whether requests are kept for the inspector.
+// log_mode: off turns that off along with the access log. Callers hold
+// cfgMu.
+func (s *Server) capturingLocked() bool {
+	return s.captures != nil && s.cfg.EffectiveLogMode() != config.LogModeOff
+}
+
+// syncResolver starts or stops the embedded DNS resolver to match cfg and
+// hands it the current domains.
+func (s *Server) syncResolver(cfg *config.Config) error {
+	if s.resolver == nil {
+		return nil
+	}
+	if !cfg.DNS {
+		return s.resolver.Close()
+	}
+	s.resolver.SetDomains(append(cfg.DomainNames(), config.InspectorDomain), cfg.DNSZones())
+	if s.resolver.Listening() {
+		return nil
+	}
+	if err := s.resolver.Listen(s.dnsAddr); err != nil {
+		return fmt.Errorf("starting DNS resolver: %w", err)
+	}
+	log.Info("DNS   listening on %s", s.dnsAddr)
 	return nil
 }
 
+// targetHandler builds the handler serving a domain or route target along
+// with the label used for it in access logs. Balanced targets are labelled
+// by the backend that served each request instead.
+func (s *Server) targetHandler(t config.Target, cookiePath string, transports map[tlsProfile]*http.Transport, cors bool, headers *headerRewriter) (string, http.Handler, error) {
+	transport, err := s.transportFor(t.TLS, transports)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(t.Ports) > 0 {
+		var targets []*url.URL
+		for _, e := range t.Expand() {
+			u, err := e.URL()
+			if err != nil {
+				return "", nil, err
+			}
+			targets = append(targets, u)
+		}
+		lb := newBalancer(targets, t.Balance, t.Sticky, cookiePath, func(u *url.URL) *httputil.ReverseProxy {
+			return newDomainProxy(u, transport, cors, headers)
+		})
+		return t.String(), lb, nil
+	}
+
+	target, err := t.URL()
+	if err != nil {
+		return "", nil, err
+	}
+	if target.Scheme == "file" {
+		return upstreamLabel(target), newStaticHandler(target.Path, t.SPA, t.Listing), nil
+	}
+	return upstreamLabel(target), newDomainProxy(target, transport, cors, headers), nil
+}
+
+// ReloadCertificates reads every cached certificate from disk again, such
+// as after the CA was rotated, leaving routes and connections alone.
+func (s *Server) ReloadCertificates() (int, error) {
+	s.certMu.RLock()
+	names := make([]string, 0, len(s.certCache))
+	for name := range s.certCache {
+		names = append(names, name)
+	}
+	s.certMu.RUnlock()
+
+	reloaded := make(map[string]*tls.Certificate, len(names))
+	for _, name := range names {
+		tlsCert, err := loadLeafTLSFn(name)
+		if err != nil {
+			return 0, err
+		}
+		reloaded[name] = tlsCert
+	}
+
+	s.certMu.Lock()
+	maps.Copy(s.certCache, reloaded)
+	s.certMu.Unlock()
+	return len(reloaded), nil
+}
+
 func (s *Server) cachedCertificate(name string) *tls.Certificate {
 	s.certMu.RLock()
 	defer s.certMu.RUnlock()
@@ -267,10 +472,27 @@ func (s *Server) cachedCertificate(name string) *tls.Certificate {
 }
 
 func (s *Server) isKnownDomain(name string) bool {
+	_, ok := s.resolveDomain(name)
+	return ok
+}
+
+// resolveDomain returns the configured domain serving host. An exact domain
+// wins over a wildcard covering it.
+func (s *Server) resolveDomain(host string) (string, bool) {
+	if host == config.InspectorDomain && s.inspector != nil {
+		return host, true
+	}
 	s.cfgMu.RLock()
 	defer s.cfgMu.RUnlock()
-	_, ok := s.knownDomains[name]
-	return ok
+	if _, ok := s.knownDomains[host]; ok {
+		return host, true
+	}
+	if wildcard, ok := config.WildcardFor(host); ok {
+		if _, ok := s.knownDomains[wildcard]; ok {
+			return wildcard, true
+		}
+	}
+	return "", false
 }
 
 func (s *Server) defaultConfiguredDomain() string {
@@ -281,9 +503,40 @@ func (s *Server) defaultConfiguredDomain() string {
 
 func newUpstreamTransport() *http.Transport {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if path, ok := socketPathFromAddr(addr); ok {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
 	transport.MaxIdleConns = 512
 	transport.MaxIdleConnsPerHost = 128
 	transport.MaxConnsPerHost = 256
 	transport.IdleConnTimeout = 2 * time.Hour
 	return transport
 }
+
+// Socket upstreams are proxied as http://<hex path>.sock.slim so they share
+// the pooled transport; the dialer decodes the host back into the path.
+const socketHostSuffix = ".sock.slim"
+
+func socketHost(path string) string {
+	return hex.EncodeToString([]byte(path)) + socketHostSuffix
+}
+
+func socketPathFromAddr(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	encoded, ok := strings.CutSuffix(host, socketHostSuffix)
+	if !ok {
+		return "", false
+	}
+	path, err := hex.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(path), true
+}
@@ -165,3 +165,54 @@ func initProxyTestConfig(t *testing.T) {
 		t.Fatalf("config.Init: %v", err)
 	}
 }
+
+func TestApplyConfigStartsAndStopsResolver(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	s := NewServer(&config.Config{})
+	s.dnsAddr = "127.0.0.1:0"
+	defer s.resolver.Close()
+
+	cfg := &config.Config{DNS: true, Domains: []config.Domain{{Name: "myapp.test", Port: 3000}}}
+	if err := s.applyConfig(cfg); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if !s.resolver.Listening() {
+		t.Fatal("expected resolver to be listening")
+	}
+
+	if err := s.applyConfig(&config.Config{Domains: cfg.Domains}); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if s.resolver.Listening() {
+		t.Fatal("expected resolver to stop when dns is disabled")
+	}
+}
+
+func TestReloadConfigRejectsInvalidConfig(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	initProxyTestConfig(t)
+
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	s := NewServer(&config.Config{})
+	if err := s.applyConfig(&config.Config{Domains: []config.Domain{{Name: "myapp.test", Port: 3000}}}); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	bad := &config.Config{Domains: []config.Domain{{Name: "api.test", Port: 3000}, {Name: "api.test", Port: 4000}}}
+	if err := bad.Save(); err != nil {
+		t.Fatalf("Save config: %v", err)
+	}
+
+	if _, err := s.ReloadConfig(); err == nil || !strings.Contains(err.Error(), "duplicate domain") {
+		t.Fatalf("expected the duplicate domain to be rejected, got %v", err)
+	}
+	if !s.isKnownDomain("myapp.test") || s.isKnownDomain("api.test") {
+		t.Fatal("expected the previous config to keep serving")
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"crypto/tls"
 	"errors"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -11,6 +15,7 @@ import (
 	"time"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/log"
 )
 
 func TestGetCertificateRejectsUnknownSNI(t *testing.T) {
@@ -29,6 +34,85 @@ func TestGetCertificateRejectsUnknownSNI(t *testing.T) {
 	}
 }
 
+func TestGetCertificateLogsHandshakeFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := log.SetDaemonOutput(path); err != nil {
+		t.Fatalf("SetDaemonOutput: %v", err)
+	}
+	t.Cleanup(log.CloseDaemonOutput)
+
+	s := &Server{cfg: &config.Config{}, knownDomains: map[string]struct{}{}, certCache: map[string]*tls.Certificate{}}
+	if _, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: "other.test"}); err == nil {
+		t.Fatal("expected error for unknown SNI")
+	}
+	log.CloseDaemonOutput()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read daemon log: %v", err)
+	}
+	if line := string(data); !strings.Contains(line, "ERROR") || !strings.Contains(line, `"other.test"`) || !strings.Contains(line, "not configured") {
+		t.Fatalf("expected the failed handshake to be logged, got %q", line)
+	}
+}
+
+func TestGetCertificateLogsUnknownNamesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := log.SetDaemonOutput(path); err != nil {
+		t.Fatalf("SetDaemonOutput: %v", err)
+	}
+	t.Cleanup(log.CloseDaemonOutput)
+
+	s := &Server{cfg: &config.Config{}, knownDomains: map[string]struct{}{}, certCache: map[string]*tls.Certificate{}}
+	for _, name := range []string{"other.test", "other.test", "typo.test", "other.test"} {
+		if _, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: name}); err == nil {
+			t.Fatal("expected error for unknown SNI")
+		}
+	}
+	log.CloseDaemonOutput()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read daemon log: %v", err)
+	}
+	if n := strings.Count(string(data), `"other.test"`); n != 1 {
+		t.Fatalf("expected other.test to be logged once, got %d:\n%s", n, data)
+	}
+	if n := strings.Count(string(data), `"typo.test"`); n != 1 {
+		t.Fatalf("expected typo.test to be logged once, got %d:\n%s", n, data)
+	}
+}
+
+func TestReloadCertificates(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+
+	old, fresh := &tls.Certificate{}, &tls.Certificate{}
+	s := &Server{certCache: map[string]*tls.Certificate{"myapp.test": old, "api.test": old}}
+	var loaded []string
+	loadLeafTLSFn = func(name string) (*tls.Certificate, error) {
+		loaded = append(loaded, name)
+		return fresh, nil
+	}
+
+	n, err := s.ReloadCertificates()
+	if err != nil || n != 2 {
+		t.Fatalf("ReloadCertificates = %d, %v; want 2", n, err)
+	}
+	if len(loaded) != 2 || s.cachedCertificate("myapp.test") != fresh || s.cachedCertificate("api.test") != fresh {
+		t.Fatalf("expected both certificates to be reloaded, loaded %v", loaded)
+	}
+
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return nil, errors.New("missing key") }
+	s.certCache["myapp.test"] = old
+	if _, err := s.ReloadCertificates(); err == nil {
+		t.Fatal("expected a load failure to be reported")
+	}
+	if s.cachedCertificate("myapp.test") != old {
+		t.Fatal("expected the cache to be left alone when a certificate fails to load")
+	}
+}
+
 func TestGetCertificateUsesDefaultDomainWhenSNIEmpty(t *testing.T) {
 	cert := &tls.Certificate{}
 	s := &Server{
@@ -47,6 +131,65 @@ func TestGetCertificateUsesDefaultDomainWhenSNIEmpty(t *testing.T) {
 	}
 }
 
+func TestGetCertificateServesInspectorDomain(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+
+	cert := &tls.Certificate{}
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(name string) (*tls.Certificate, error) {
+		if name != config.InspectorDomain {
+			return nil, errors.New("unexpected name")
+		}
+		return cert, nil
+	}
+
+	s := NewServer(&config.Config{})
+	got, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: config.InspectorDomain})
+	if err != nil {
+		t.Fatalf("getCertificate: %v", err)
+	}
+	if got != cert {
+		t.Fatal("expected inspector certificate")
+	}
+}
+
+func TestGetCertificateMatchesWildcardDomains(t *testing.T) {
+	exact, wildcard := &tls.Certificate{}, &tls.Certificate{}
+	s := &Server{
+		cfg:          &config.Config{},
+		knownDomains: map[string]struct{}{"admin.myapp.test": {}, "*.myapp.test": {}},
+		certCache:    map[string]*tls.Certificate{"admin.myapp.test": exact, "*.myapp.test": wildcard},
+	}
+
+	tests := []struct {
+		serverName string
+		want       *tls.Certificate
+	}{
+		{"admin.myapp.test", exact},
+		{"tenant1.myapp.test", wildcard},
+		{"a.tenant1.myapp.test", nil},
+		{"myapp.test", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			got, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+			if tt.want == nil {
+				if err == nil {
+					t.Fatal("expected error for uncovered name")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getCertificate: %v", err)
+			}
+			if got != tt.want {
+				t.Fatal("got the wrong certificate")
+			}
+		})
+	}
+}
+
 func TestGetCertificateUsesSingleflightOnCacheMiss(t *testing.T) {
 	origEnsure := ensureLeafCertFn
 	origLoad := loadLeafTLSFn
@@ -127,3 +270,37 @@ func freeTCPPort(t *testing.T) int {
 
 	return ln.Addr().(*net.TCPAddr).Port
 }
+
+func TestNewServerListensOnConfiguredPorts(t *testing.T) {
+	s := NewServer(&config.Config{HTTPPort: 8080, HTTPSPort: 8443})
+	if s.httpAddr != ":8080" || s.httpsAddr != ":8443" {
+		t.Fatalf("expected :8080/:8443, got %s/%s", s.httpAddr, s.httpsAddr)
+	}
+
+	s = NewServer(&config.Config{})
+	if s.httpAddr != ":10080" || s.httpsAddr != ":10443" {
+		t.Fatalf("expected default ports, got %s/%s", s.httpAddr, s.httpsAddr)
+	}
+}
+
+func TestHTTPSRedirectURL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		host string
+		want string
+	}{
+		{"forwarded", &config.Config{}, "myapp.test", "https://myapp.test/a?b=1"},
+		{"rootless", &config.Config{NoPortForward: true, HTTPSPort: 8443}, "myapp.test:8080", "https://myapp.test:8443/a?b=1"},
+		{"rootless default port", &config.Config{NoPortForward: true}, "myapp.test:10080", "https://myapp.test:10443/a?b=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{cfg: tt.cfg}
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/a?b=1", nil)
+			if got := s.httpsRedirectURL(req); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
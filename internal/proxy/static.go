@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// staticHandler serves files from a directory. Missing paths fall back to
+// index.html in SPA mode when the client asks for a page, and directories
+// without an index are only listed when listing is enabled.
+type staticHandler struct {
+	root    http.Dir
+	spa     bool
+	listing bool
+	files   http.Handler
+}
+
+func newStaticHandler(dir string, spa bool, listing bool) *staticHandler {
+	return &staticHandler{
+		root:    http.Dir(dir),
+		spa:     spa,
+		listing: listing,
+		files:   http.FileServer(http.Dir(dir)),
+	}
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	f, info, err := h.open(name)
+	if err == nil && info.IsDir() {
+		f.Close()
+		if name != "/" && !strings.HasSuffix(r.URL.Path, "/") {
+			target := path.Base(name) + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if f, info, err = h.open(path.Join(name, "index.html")); err == nil && info.IsDir() {
+			f.Close()
+			err = fs.ErrNotExist
+		}
+		if err != nil && h.listing {
+			w.Header().Set("Cache-Control", "no-cache")
+			h.files.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	if err != nil && h.spa && wantsHTML(r) {
+		f, info, err = h.open("/index.html")
+		if err == nil && info.IsDir() {
+			f.Close()
+			err = fs.ErrNotExist
+		}
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	// Files change on every rebuild, so let the browser cache them but make
+	// it revalidate each time.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (h *staticHandler) open(name string) (http.File, fs.FileInfo, error) {
+	f, err := h.root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, info, nil
+}
+
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
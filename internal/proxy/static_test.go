@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func writeStaticFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":        "<h1>home</h1>",
+		"app.js":            "console.log('hello')",
+		"docs/index.html":   "<h1>docs</h1>",
+		"assets/logo.svg":   "<svg></svg>",
+		"assets/styles.css": "body{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestStaticHandler(t *testing.T) {
+	dir := writeStaticFixture(t)
+
+	tests := []struct {
+		name       string
+		spa        bool
+		listing    bool
+		method     string
+		path       string
+		accept     string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "file", path: "/app.js", wantStatus: http.StatusOK, wantBody: "console.log('hello')"},
+		{name: "root index", path: "/", wantStatus: http.StatusOK, wantBody: "<h1>home</h1>"},
+		{name: "dir index", path: "/docs/", wantStatus: http.StatusOK, wantBody: "<h1>docs</h1>"},
+		{name: "dir redirect", path: "/docs", wantStatus: http.StatusMovedPermanently},
+		{name: "missing file", path: "/missing.js", wantStatus: http.StatusNotFound},
+		{name: "no listing", path: "/assets/", wantStatus: http.StatusNotFound},
+		{name: "listing", listing: true, path: "/assets/", wantStatus: http.StatusOK, wantBody: "logo.svg"},
+		{name: "spa page", spa: true, path: "/settings/profile", accept: "text/html,*/*", wantStatus: http.StatusOK, wantBody: "<h1>home</h1>"},
+		{name: "spa missing asset", spa: true, path: "/missing.js", accept: "*/*", wantStatus: http.StatusNotFound},
+		{name: "traversal", path: "/../../etc/passwd", wantStatus: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, path: "/app.js", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "https://docs.test"+tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			newStaticHandler(dir, tt.spa, tt.listing).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantBody != "" && !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Fatalf("expected body to contain %q, got %q", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestStaticHandlerCachingAndRanges(t *testing.T) {
+	h := newStaticHandler(writeStaticFixture(t), false, false)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "https://docs.test/app.js", nil))
+	if got := rr.Header().Get("Cache-Control"); got != "no-cache" {
+		t.Fatalf("expected Cache-Control no-cache, got %q", got)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" || rr.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected ETag and Last-Modified, got %v", rr.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://docs.test/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("expected %d for matching ETag, got %d", http.StatusNotModified, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "https://docs.test/app.js", nil)
+	req.Header.Set("Range", "bytes=0-6")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent {
+		t.Fatalf("expected %d for range request, got %d", http.StatusPartialContent, rr.Code)
+	}
+	if rr.Body.String() != "console" {
+		t.Fatalf("expected range body %q, got %q", "console", rr.Body.String())
+	}
+}
+
+func TestApplyConfigServesStaticRoute(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	dir := writeStaticFixture(t)
+	s := NewServer(&config.Config{})
+	cfg := &config.Config{Domains: []config.Domain{{
+		Name:   "myapp.test",
+		Port:   3000,
+		Routes: []config.Route{{Path: "/static", Dir: dir}},
+	}}}
+	if err := s.applyConfig(cfg); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://myapp.test/static/assets/styles.css", nil)
+	req.Host = "myapp.test"
+	rr := httptest.NewRecorder()
+	buildHandler(s).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Body.String() != "body{}" {
+		t.Fatalf("expected stylesheet body, got %q", rr.Body.String())
+	}
+}
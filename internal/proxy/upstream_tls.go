@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+var caCertPathFn = cert.CACertPath
+
+// tlsProfile identifies a distinct upstream TLS setup. Routes that share a
+// profile share one pooled transport.
+type tlsProfile struct {
+	insecure bool
+	caFile   string
+	slimCA   bool
+}
+
+func profileFor(opts *config.UpstreamTLS) tlsProfile {
+	if opts == nil {
+		return tlsProfile{}
+	}
+	return tlsProfile{insecure: opts.Insecure, caFile: opts.CAFile, slimCA: opts.SlimCA}
+}
+
+// transportFor returns the transport for opts, creating it in transports on
+// first use. The zero profile always maps to the server's default transport.
+func (s *Server) transportFor(opts *config.UpstreamTLS, transports map[tlsProfile]*http.Transport) (*http.Transport, error) {
+	profile := profileFor(opts)
+	if profile == (tlsProfile{}) {
+		return s.transport, nil
+	}
+	if t, ok := transports[profile]; ok {
+		return t, nil
+	}
+
+	tlsConfig, err := upstreamTLSConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	t := newUpstreamTransport()
+	t.TLSClientConfig = tlsConfig
+	transports[profile] = t
+	return t, nil
+}
+
+func upstreamTLSConfig(profile tlsProfile) (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: profile.insecure}
+	if profile.caFile == "" && !profile.slimCA {
+		return cfg, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	var files []string
+	if profile.caFile != "" {
+		files = append(files, profile.caFile)
+	}
+	if profile.slimCA {
+		files = append(files, caCertPathFn())
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading upstream CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+	}
+
+	cfg.RootCAs = pool
+	return cfg, nil
+}
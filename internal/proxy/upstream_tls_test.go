@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func TestApplyConfigHTTPSUpstreamVerification(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tls  *config.UpstreamTLS
+		want int
+	}{
+		{"system roots", nil, http.StatusBadGateway},
+		{"insecure", &config.UpstreamTLS{Insecure: true}, http.StatusOK},
+		{"ca file", &config.UpstreamTLS{CAFile: caFile}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(&config.Config{})
+			cfg := &config.Config{Domains: []config.Domain{{Name: "myapp.test", Upstream: upstream.URL, TLS: tt.tls}}}
+			if err := s.applyConfig(cfg); err != nil {
+				t.Fatalf("applyConfig: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "https://myapp.test/", nil)
+			req.Host = "myapp.test"
+			rr := httptest.NewRecorder()
+			buildHandler(s).ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestApplyConfigSharesTransportPerTLSProfile(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	insecure := &config.UpstreamTLS{Insecure: true}
+	cfg := &config.Config{
+		Domains: []config.Domain{
+			{
+				Name:     "myapp.test",
+				Upstream: "https://10.0.0.5",
+				TLS:      insecure,
+				Routes: []config.Route{
+					{Path: "/api", Upstream: "https://10.0.0.6", TLS: &config.UpstreamTLS{Insecure: true}},
+					{Path: "/ws", Port: 3001},
+				},
+			},
+			{Name: "other.test", Port: 3000},
+		},
+	}
+
+	s := NewServer(&config.Config{})
+	if err := s.applyConfig(cfg); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+	if len(s.tlsTransports) != 1 {
+		t.Fatalf("expected 1 TLS transport, got %d", len(s.tlsTransports))
+	}
+}
+
+func TestApplyConfigRejectsUnreadableCAFile(t *testing.T) {
+	restore := snapshotProxyCertHooks()
+	defer restore()
+	ensureLeafCertFn = func(string) error { return nil }
+	loadLeafTLSFn = func(string) (*tls.Certificate, error) { return &tls.Certificate{}, nil }
+
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	cfg := &config.Config{Domains: []config.Domain{{Name: "myapp.test", Upstream: "https://10.0.0.5", TLS: &config.UpstreamTLS{CAFile: missing}}}}
+
+	if err := NewServer(&config.Config{}).applyConfig(cfg); err == nil {
+		t.Fatal("expected applyConfig to fail for a missing CA file")
+	}
+}
@@ -1,16 +1,32 @@
 package setup
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
 
 	"github.com/kamranahmedse/slim/internal/cert"
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
 	"github.com/kamranahmedse/slim/internal/system"
 	"github.com/kamranahmedse/slim/internal/term"
 )
 
-func EnsureFirstRun() error {
+var (
+	isInteractiveFn = term.IsInteractive
+	confirmFn       = term.ConfirmPrompt
+	runStepsFn      = term.RunSteps
+	addHostFn       = system.AddHost
+
+	daemonIsRunningFn = daemon.IsRunning
+	daemonSendIPCFn   = daemon.SendIPC
+)
+
+// EnsureFirstRun creates and trusts the CA, then sets up port forwarding
+// and, without the built-in resolver, the inspector's hosts entry. Rootless
+// mode stops after the CA.
+func EnsureFirstRun(cfg *config.Config) error {
 	if !cert.CAExists() {
 		err := term.RunSteps([]term.Step{
 			{
@@ -30,13 +46,19 @@ func EnsureFirstRun() error {
 		if err != nil {
 			return err
 		}
+	} else {
+		offerCAReplacement(cfg)
+	}
+
+	if cfg.NoPortForward {
+		return nil
 	}
 
 	pf := system.NewPortForwarder()
 	if !pf.IsEnabled() {
 		err := term.RunSteps([]term.Step{
 			{
-				Name: fmt.Sprintf("Setting up port forwarding (80→%d, 443→%d)", config.ProxyHTTPPort, config.ProxyHTTPSPort),
+				Name: fmt.Sprintf("Setting up port forwarding (80→%d, 443→%d)", cfg.HTTPListenPort(), cfg.HTTPSListenPort()),
 				Run: func() (string, error) {
 					if err := pf.Enable(); err != nil {
 						return fmt.Sprintf("skipped (%v)", err), nil
@@ -50,13 +72,143 @@ func EnsureFirstRun() error {
 		}
 	}
 
+	return addInspectorHost(cfg)
+}
+
+// addInspectorHost adds the inspector's hosts entry, which the built-in
+// resolver makes unnecessary.
+func addInspectorHost(cfg *config.Config) error {
+	if cfg.DNS {
+		return nil
+	}
+	if err := addHostFn(config.InspectorDomain); err != nil {
+		return fmt.Errorf("updating /etc/hosts: %w", err)
+	}
 	return nil
 }
 
-func EnsureProxyPortsAvailable() error {
+// offerCAReplacement asks to replace a CA made before name constraints,
+// or one that doesn't cover suffixes added to ca_suffixes since. When a
+// configured domain falls outside those suffixes it only says how to fix
+// that. A "no" is remembered, and nothing here stops the caller.
+func offerCAReplacement(cfg *config.Config) {
+	caCert, err := cert.LoadCACert()
+	if err != nil {
+		return
+	}
+	suffixes := cfg.PermittedCASuffixes()
+	reason := cert.ReplacementReason(caCert, suffixes)
+	if reason == "" || !isInteractiveFn() || cert.ReplacementDeclined(reason, suffixes) {
+		return
+	}
+
+	fmt.Printf("%s your root CA %s.\n", term.Yellow.Render("Warning:"), reason)
+	if err := cert.CheckNextCA(cfg); err != nil {
+		fmt.Printf("It can't be replaced with one that only signs your local domains yet: %v\n", err)
+		declineCAReplacement(reason, suffixes)
+		return
+	}
+	if !confirmFn("Replace it with one that only signs your local domains?") {
+		declineCAReplacement(reason, suffixes)
+		return
+	}
+	if err := RotateCA(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s replacing the root CA stopped: %v (finish with: slim cert rotate-ca)\n", term.Yellow.Render("Warning:"), err)
+	}
+}
+
+func declineCAReplacement(reason string, suffixes []string) {
+	if err := cert.DeclineReplacement(reason, suffixes); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", term.Yellow.Render("Warning:"), err)
+	}
+}
+
+// RotateCA replaces the root CA without breaking HTTPS along the way: the
+// new CA is trusted next to the old one until every certificate has been
+// reissued and the daemon serves them. Each finished stage is recorded, so
+// running it again after an interruption resumes where it stopped.
+func RotateCA() error {
+	stage, err := cert.RotationStage()
+	if err != nil {
+		return err
+	}
+
+	stages := []struct {
+		stage string
+		step  term.Step
+	}{
+		{cert.RotateGenerated, term.Step{Name: "Generating new root CA", Run: done(cert.GenerateNextCA)}},
+		{cert.RotateTrusted, term.Step{
+			Name:        "Trusting new root CA alongside the old one (you may be prompted for your password)",
+			Interactive: true,
+			Run:         done(cert.TrustNextCA),
+		}},
+		{cert.RotateReissued, term.Step{Name: "Reissuing certificates", Run: func() (string, error) {
+			n, err := cert.ReissueLeaves()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d reissued", n), nil
+		}}},
+		{cert.RotateSwapped, term.Step{Name: "Serving the new certificates", Run: reloadDaemonCerts}},
+		{cert.RotateOldUntrusted, term.Step{Name: "Removing old root CA from trust store", Interactive: true, Run: done(cert.UntrustOldCA)}},
+		{cert.RotatePromoted, term.Step{Name: "Trusting new root CA in its place", Interactive: true, Run: done(cert.PromoteNextCA)}},
+		{"", term.Step{Name: "Deleting old root CA", Run: done(cert.InstallNextCA)}},
+	}
+
+	resuming := stage != ""
+	var steps []term.Step
+	for _, s := range stages {
+		if resuming {
+			resuming = s.stage != stage
+			continue
+		}
+		step := s.step
+		step.Run = func() (string, error) {
+			result, err := s.step.Run()
+			if err != nil || s.stage == "" {
+				return result, err
+			}
+			return result, cert.SetRotationStage(s.stage)
+		}
+		steps = append(steps, step)
+	}
+	return runStepsFn(steps)
+}
+
+func done(fn func() error) func() (string, error) {
+	return func() (string, error) {
+		return "done", fn()
+	}
+}
+
+// reloadDaemonCerts has a running daemon serve the reissued certificates.
+// A daemon from an older build doesn't know the message, but reloading its
+// config picks them up too.
+func reloadDaemonCerts() (string, error) {
+	if !daemonIsRunningFn() {
+		return "skipped (daemon not running)", nil
+	}
+	resp, err := daemonSendIPCFn(daemon.Request{Type: daemon.MsgReloadCerts})
+	if err == nil && !resp.OK && resp.Protocol != daemon.ProtocolVersion {
+		resp, err = daemonSendIPCFn(daemon.Request{Type: daemon.MsgReload})
+	}
+	if err != nil {
+		return "", err
+	}
+	if !resp.OK {
+		return "", errors.New(resp.Error)
+	}
+	return "done", nil
+}
+
+func EnsureProxyPortsAvailable(cfg *config.Config) error {
+	if err := cfg.ValidatePorts(); err != nil {
+		return err
+	}
 	addrs := []string{
-		fmt.Sprintf(":%d", config.ProxyHTTPPort),
-		fmt.Sprintf(":%d", config.ProxyHTTPSPort),
+		fmt.Sprintf(":%d", cfg.HTTPListenPort()),
+		fmt.Sprintf(":%d", cfg.HTTPSListenPort()),
 	}
 	for _, addr := range addrs {
 		if err := ensurePortAvailable(addr); err != nil {
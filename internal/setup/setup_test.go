@@ -1,9 +1,18 @@
 package setup
 
 import (
+	"errors"
 	"net"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/kamranahmedse/slim/internal/cert"
+	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/daemon"
+	"github.com/kamranahmedse/slim/internal/term"
 )
 
 func TestEnsureProxyPortsAvailableFailsWhenInUse(t *testing.T) {
@@ -34,3 +43,181 @@ func TestEnsurePortAvailableSuccess(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestAddInspectorHost(t *testing.T) {
+	prev := addHostFn
+	t.Cleanup(func() { addHostFn = prev })
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		want []string
+	}{
+		{"hosts file", &config.Config{}, []string{config.InspectorDomain}},
+		{"built-in resolver", &config.Config{DNS: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added []string
+			addHostFn = func(host string) error { added = append(added, host); return nil }
+			if err := addInspectorHost(tt.cfg); err != nil {
+				t.Fatalf("addInspectorHost: %v", err)
+			}
+			if !reflect.DeepEqual(added, tt.want) {
+				t.Fatalf("added %v, want %v", added, tt.want)
+			}
+		})
+	}
+}
+
+func TestOfferCAReplacement(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := config.Init(); err != nil {
+		t.Fatalf("config.Init: %v", err)
+	}
+	if err := cert.GenerateCA(); err != nil {
+		t.Fatalf("GenerateCA: %v", err)
+	}
+
+	prevInteractive, prevConfirm, prevRunSteps := isInteractiveFn, confirmFn, runStepsFn
+	t.Cleanup(func() { isInteractiveFn, confirmFn, runStepsFn = prevInteractive, prevConfirm, prevRunSteps })
+
+	demo := []string{"demo"}
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		interactive bool
+		confirm     bool
+		rotateErr   error
+		asked       bool
+		replaced    bool
+		askedAgain  bool
+	}{
+		{"covered", &config.Config{}, true, true, nil, false, false, false},
+		{"not interactive", &config.Config{CASuffixes: demo}, false, true, nil, false, false, false},
+		{"declined", &config.Config{CASuffixes: demo}, true, false, nil, true, false, false},
+		{"accepted", &config.Config{CASuffixes: demo}, true, true, nil, true, true, true},
+		{"rotation fails", &config.Config{CASuffixes: demo}, true, true, errors.New("sudo: a password is required"), true, true, true},
+		{"domain outside suffixes", &config.Config{CASuffixes: demo, Domains: []config.Domain{{Name: "app.example.com", Port: 3000}}}, true, true, nil, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { os.Remove(filepath.Join(cert.CADir(), "replace-declined")) })
+			asked, replaced := false, false
+			isInteractiveFn = func() bool { return tt.interactive }
+			confirmFn = func(string) bool { asked = true; return tt.confirm }
+			runStepsFn = func(steps []term.Step) error { replaced = len(steps) > 0; return tt.rotateErr }
+
+			offerCAReplacement(tt.cfg)
+			if asked != tt.asked || replaced != tt.replaced {
+				t.Fatalf("asked=%v replaced=%v, want asked=%v replaced=%v", asked, replaced, tt.asked, tt.replaced)
+			}
+
+			asked = false
+			offerCAReplacement(tt.cfg)
+			if asked != tt.askedAgain {
+				t.Fatalf("asked again=%v, want %v", asked, tt.askedAgain)
+			}
+		})
+	}
+
+	// A declined replacement is offered again once ca_suffixes changes.
+	isInteractiveFn = func() bool { return true }
+	asked := false
+	confirmFn = func(string) bool { asked = true; return false }
+	offerCAReplacement(&config.Config{CASuffixes: demo})
+	asked = false
+	offerCAReplacement(&config.Config{CASuffixes: []string{"demo", "corp"}})
+	if !asked {
+		t.Fatal("expected a change to ca_suffixes to ask again")
+	}
+}
+
+func TestRotateCAResumesAfterLastStage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.Init(); err != nil {
+		t.Fatalf("config.Init: %v", err)
+	}
+	if err := os.MkdirAll(cert.CADir(), 0700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	prev := runStepsFn
+	t.Cleanup(func() { runStepsFn = prev })
+	var names []string
+	runStepsFn = func(steps []term.Step) error {
+		names = nil
+		for _, s := range steps {
+			names = append(names, s.Name)
+		}
+		return nil
+	}
+
+	if err := RotateCA(); err != nil {
+		t.Fatalf("RotateCA: %v", err)
+	}
+	if len(names) != 7 || names[0] != "Generating new root CA" {
+		t.Fatalf("expected a fresh rotation to run every step, got %v", names)
+	}
+
+	if err := cert.SetRotationStage(cert.RotateReissued); err != nil {
+		t.Fatalf("SetRotationStage: %v", err)
+	}
+	if err := RotateCA(); err != nil {
+		t.Fatalf("RotateCA: %v", err)
+	}
+	want := []string{"Serving the new certificates", "Removing old root CA from trust store", "Trusting new root CA in its place", "Deleting old root CA"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("resumed steps = %v, want %v", names, want)
+	}
+}
+
+func TestReloadDaemonCerts(t *testing.T) {
+	prevRunning, prevSend := daemonIsRunningFn, daemonSendIPCFn
+	t.Cleanup(func() { daemonIsRunningFn, daemonSendIPCFn = prevRunning, prevSend })
+
+	tests := []struct {
+		name    string
+		running bool
+		replies map[daemon.MessageType]daemon.Response
+		want    []daemon.MessageType
+		result  string
+		wantErr string
+	}{
+		{"not running", false, nil, nil, "skipped (daemon not running)", ""},
+		{"current daemon", true, map[daemon.MessageType]daemon.Response{
+			daemon.MsgReloadCerts: {OK: true, Protocol: daemon.ProtocolVersion},
+		}, []daemon.MessageType{daemon.MsgReloadCerts}, "done", ""},
+		{"older daemon", true, map[daemon.MessageType]daemon.Response{
+			daemon.MsgReloadCerts: {Error: "unknown message type: reload_certs", Protocol: 2},
+			daemon.MsgReload:      {OK: true, Protocol: 2},
+		}, []daemon.MessageType{daemon.MsgReloadCerts, daemon.MsgReload}, "done", ""},
+		{"failed reload", true, map[daemon.MessageType]daemon.Response{
+			daemon.MsgReloadCerts: {Error: "loading cert for myapp.test: missing", Protocol: daemon.ProtocolVersion},
+		}, []daemon.MessageType{daemon.MsgReloadCerts}, "", "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []daemon.MessageType
+			daemonIsRunningFn = func() bool { return tt.running }
+			daemonSendIPCFn = func(req daemon.Request) (*daemon.Response, error) {
+				sent = append(sent, req.Type)
+				resp := tt.replies[req.Type]
+				return &resp, nil
+			}
+
+			result, err := reloadDaemonCerts()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil || result != tt.result {
+				t.Fatalf("reloadDaemonCerts = %q, %v; want %q", result, err, tt.result)
+			}
+			if !reflect.DeepEqual(sent, tt.want) {
+				t.Fatalf("sent %v, want %v", sent, tt.want)
+			}
+		})
+	}
+}
@@ -14,19 +14,28 @@ var (
 	writeFileElevatedHostFn = writeFileElevated
 )
 
-func AddHost(name string) error {
-	entry := fmt.Sprintf("127.0.0.1 %s %s", name, marker)
+// LoopbackAddrs are the addresses each name is mapped to, so it resolves
+// to this machine whichever family the client tries first.
+var LoopbackAddrs = []string{"127.0.0.1", "::1"}
 
+func AddHost(name string) error {
 	content, err := readFileHostFn(hostsPath)
 	if err != nil {
 		return fmt.Errorf("reading hosts file: %w", err)
 	}
 
-	if HasMarkedEntry(string(content), name) {
+	updated := strings.TrimRight(string(content), "\n") + "\n"
+	added := false
+	for _, addr := range LoopbackAddrs {
+		if HasMarkedEntryFor(string(content), addr, name) {
+			continue
+		}
+		updated += fmt.Sprintf("%s %s %s\n", addr, name, marker)
+		added = true
+	}
+	if !added {
 		return nil
 	}
-
-	updated := strings.TrimRight(string(content), "\n") + "\n" + entry + "\n"
 	return writeFileElevatedHostFn(hostsPath, updated)
 }
 
@@ -75,6 +84,17 @@ func HasMarkedEntry(content, hostname string) bool {
 	return false
 }
 
+// HasMarkedEntryFor reports whether slim maps hostname to addr.
+func HasMarkedEntryFor(content, addr, hostname string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == addr && lineHasHost(line, hostname) && strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 func lineHasHost(line, hostname string) bool {
 	for _, field := range strings.Fields(line) {
 		if field == hostname {
@@ -27,8 +27,33 @@ func TestAddHostAppendsMarkedEntry(t *testing.T) {
 	if wrotePath != hostsPath {
 		t.Fatalf("expected write path %q, got %q", hostsPath, wrotePath)
 	}
-	if !strings.Contains(wroteContent, "myapp.test") || !strings.Contains(wroteContent, marker) {
-		t.Fatalf("expected hosts entry to be appended, got %q", wroteContent)
+	for _, entry := range []string{"127.0.0.1 myapp.test # slim", "::1 myapp.test # slim"} {
+		if !strings.Contains(wroteContent, entry) {
+			t.Fatalf("expected %q to be appended, got %q", entry, wroteContent)
+		}
+	}
+}
+
+func TestAddHostAddsMissingIPv6Entry(t *testing.T) {
+	restore := snapshotHostFileHooks()
+	defer restore()
+
+	readFileHostFn = func(string) ([]byte, error) {
+		return []byte("127.0.0.1 localhost\n127.0.0.1 myapp.test # slim\n"), nil
+	}
+
+	var wrote string
+	writeFileElevatedHostFn = func(path string, content string) error {
+		wrote = content
+		return nil
+	}
+
+	if err := AddHost("myapp.test"); err != nil {
+		t.Fatalf("AddHost: %v", err)
+	}
+	want := "127.0.0.1 localhost\n127.0.0.1 myapp.test # slim\n::1 myapp.test # slim\n"
+	if wrote != want {
+		t.Fatalf("expected only the ::1 entry to be appended, got %q", wrote)
 	}
 }
 
@@ -37,7 +62,7 @@ func TestAddHostNoopWhenEntryAlreadyExists(t *testing.T) {
 	defer restore()
 
 	readFileHostFn = func(string) ([]byte, error) {
-		return []byte("127.0.0.1 myapp.test # slim\n"), nil
+		return []byte("127.0.0.1 myapp.test # slim\n::1 myapp.test # slim\n"), nil
 	}
 
 	called := false
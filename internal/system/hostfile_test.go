@@ -37,3 +37,23 @@ func TestHasMarkedEntry(t *testing.T) {
 		t.Error("did not expect to find entry in empty content")
 	}
 }
+
+func TestHasMarkedEntryFor(t *testing.T) {
+	content := "127.0.0.1 myapp.test # slim\n::1 localhost\n::1 other.test # slim\n"
+
+	tests := []struct {
+		addr     string
+		hostname string
+		want     bool
+	}{
+		{"127.0.0.1", "myapp.test", true},
+		{"::1", "myapp.test", false},
+		{"::1", "other.test", true},
+		{"::1", "localhost", false},
+	}
+	for _, tt := range tests {
+		if got := HasMarkedEntryFor(content, tt.addr, tt.hostname); got != tt.want {
+			t.Errorf("HasMarkedEntryFor(%q, %q) = %v, want %v", tt.addr, tt.hostname, got, tt.want)
+		}
+	}
+}
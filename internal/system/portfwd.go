@@ -1,5 +1,7 @@
 package system
 
+import "github.com/kamranahmedse/slim/internal/config"
+
 type PortForwarder interface {
 	Enable() error
 	Disable() error
@@ -7,3 +9,13 @@ type PortForwarder interface {
 	IsLoaded() bool
 	EnsureLoaded() error
 }
+
+// forwardPorts are the proxy listener ports that 80 and 443 redirect to.
+type forwardPorts struct {
+	http  int
+	https int
+}
+
+func portsFor(cfg *config.Config) forwardPorts {
+	return forwardPorts{http: cfg.HTTPListenPort(), https: cfg.HTTPSListenPort()}
+}
@@ -14,8 +14,13 @@ import (
 const anchorName = "com.slim"
 const anchorFile = "/etc/pf.anchors/com.slim"
 
-var pfRules = fmt.Sprintf("rdr pass on lo0 inet proto tcp from any to 127.0.0.1 port 80 -> 127.0.0.1 port %d\nrdr pass on lo0 inet proto tcp from any to 127.0.0.1 port 443 -> 127.0.0.1 port %d\n",
-	config.ProxyHTTPPort, config.ProxyHTTPSPort)
+func pfRules(p forwardPorts) string {
+	return fmt.Sprintf("rdr pass on lo0 inet proto tcp from any to 127.0.0.1 port 80 -> 127.0.0.1 port %[1]d\n"+
+		"rdr pass on lo0 inet proto tcp from any to 127.0.0.1 port 443 -> 127.0.0.1 port %[2]d\n"+
+		"rdr pass on lo0 inet6 proto tcp from any to ::1 port 80 -> ::1 port %[1]d\n"+
+		"rdr pass on lo0 inet6 proto tcp from any to ::1 port 443 -> ::1 port %[2]d\n",
+		p.http, p.https)
+}
 
 var (
 	readPFTokenFn   = os.ReadFile
@@ -23,14 +28,20 @@ var (
 	removePFTokenFn = os.Remove
 )
 
-type darwinPortFwd struct{}
+type darwinPortFwd struct {
+	ports forwardPorts
+}
 
 func NewPortForwarder() PortForwarder {
-	return &darwinPortFwd{}
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = nil
+	}
+	return &darwinPortFwd{ports: portsFor(cfg)}
 }
 
 func (d *darwinPortFwd) Enable() error {
-	if err := writeFileElevated(anchorFile, pfRules); err != nil {
+	if err := writeFileElevated(anchorFile, pfRules(d.ports)); err != nil {
 		return fmt.Errorf("writing pf anchor: %w", err)
 	}
 
@@ -143,7 +154,7 @@ func (d *darwinPortFwd) IsLoaded() bool {
 		return false
 	}
 	out := strings.TrimSpace(string(output))
-	return strings.Contains(out, "rdr pass") && strings.Contains(out, "port = 443")
+	return strings.Contains(out, "rdr pass") && strings.Contains(out, "port = 443") && strings.Contains(out, "::1")
 }
 
 func isPFAlreadyEnabledOutput(out string) bool {
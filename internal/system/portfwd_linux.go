@@ -9,10 +9,13 @@ import (
 	"strings"
 
 	"github.com/kamranahmedse/slim/internal/config"
+	"github.com/kamranahmedse/slim/internal/log"
 	"github.com/kamranahmedse/slim/internal/osutil"
 )
 
-type linuxPortFwd struct{}
+type linuxPortFwd struct {
+	ports forwardPorts
+}
 
 const linuxChainName = "SLIM"
 
@@ -20,10 +23,69 @@ var (
 	commandExistsLinuxFn = osutil.CommandExists
 	runPrivilegedLinuxFn = osutil.RunPrivileged
 	execCommandLinuxFn   = exec.Command
+	loadConfigLinuxFn    = config.Load
 )
 
+// NewPortForwarder returns the backend set with port_forward in the config,
+// or picks one: a backend that already holds slim's rules wins, then
+// iptables, then nftables.
 func NewPortForwarder() PortForwarder {
-	return &linuxPortFwd{}
+	cfg, err := loadConfigLinuxFn()
+	if err != nil {
+		cfg = nil
+	}
+	ports := portsFor(cfg)
+	iptables, nftables := &linuxPortFwd{ports: ports}, &nftPortFwd{ports: ports}
+
+	backend := config.PortForwardAuto
+	if cfg != nil && cfg.PortForward != "" && config.ValidatePortForward(cfg.PortForward) == nil {
+		backend = cfg.PortForward
+	}
+	switch backend {
+	case config.PortForwardIPTables:
+		return iptables
+	case config.PortForwardNFTables:
+		return nftables
+	}
+
+	switch {
+	case iptables.IsEnabled():
+		return iptables
+	case nftables.IsEnabled():
+		return nftables
+	case !commandExistsLinuxFn("iptables") && commandExistsLinuxFn("nft"):
+		return nftables
+	default:
+		return iptables
+	}
+}
+
+// iptablesFamily is one iptables frontend and the loopback address its
+// redirect rules match. A best effort family is skipped when its rules
+// can't be installed.
+type iptablesFamily struct {
+	bin        string
+	loopback   string
+	bestEffort bool
+}
+
+// IPv6 nat may be missing even with ip6tables installed, for example with
+// IPv6 disabled or no ip6table_nat module.
+var iptablesFamilies = []iptablesFamily{
+	{bin: "iptables", loopback: "127.0.0.1/32"},
+	{bin: "ip6tables", loopback: "::1/128", bestEffort: true},
+}
+
+// families returns the frontends to manage, leaving out ones whose binary
+// isn't installed.
+func (l *linuxPortFwd) families() []iptablesFamily {
+	var out []iptablesFamily
+	for _, f := range iptablesFamilies {
+		if commandExistsLinuxFn(f.bin) {
+			out = append(out, f)
+		}
+	}
+	return out
 }
 
 func (l *linuxPortFwd) Enable() error {
@@ -31,23 +93,40 @@ func (l *linuxPortFwd) Enable() error {
 		return errors.New("iptables not found (install iptables)")
 	}
 
-	if err := l.ensureChain(); err != nil {
+	for _, f := range l.families() {
+		err := l.enableFamily(f)
+		if err == nil {
+			continue
+		}
+		if !f.bestEffort {
+			return err
+		}
+		log.Warn("Skipping %s port forwarding: %v", f.bin, err)
+		// Leave no half-installed chain behind, or IsEnabled would keep
+		// expecting its jump.
+		_ = l.disableFamily(f.bin)
+	}
+	return nil
+}
+
+func (l *linuxPortFwd) enableFamily(f iptablesFamily) error {
+	if err := l.ensureChain(f.bin); err != nil {
 		return err
 	}
-	if err := l.ensureRedirectRule(80, config.ProxyHTTPPort); err != nil {
+	if err := l.ensureRedirectRule(f, 80, l.ports.http); err != nil {
 		return err
 	}
-	if err := l.ensureRedirectRule(443, config.ProxyHTTPSPort); err != nil {
+	if err := l.ensureRedirectRule(f, 443, l.ports.https); err != nil {
 		return err
 	}
 
-	exists, err := l.ruleExists("OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName)
+	exists, err := l.ruleExists(f.bin, "OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		if output, err := runPrivilegedLinuxFn("iptables", "-t", "nat", "-I", "OUTPUT", "1", "-o", "lo", "-p", "tcp", "-j", linuxChainName); err != nil {
-			return fmt.Errorf("installing OUTPUT jump rule: %s: %w", strings.TrimSpace(string(output)), err)
+		if output, err := runPrivilegedLinuxFn(f.bin, "-t", "nat", "-I", "OUTPUT", "1", "-o", "lo", "-p", "tcp", "-j", linuxChainName); err != nil {
+			return fmt.Errorf("installing %s OUTPUT jump rule: %s: %w", f.bin, strings.TrimSpace(string(output)), err)
 		}
 	}
 	return nil
@@ -58,28 +137,36 @@ func (l *linuxPortFwd) EnsureLoaded() error {
 }
 
 func (l *linuxPortFwd) Disable() error {
-	if !commandExistsLinuxFn("iptables") {
-		return nil
+	for _, f := range l.families() {
+		if f.bestEffort && !l.chainInstalled(f.bin) {
+			continue
+		}
+		if err := l.disableFamily(f.bin); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+func (l *linuxPortFwd) disableFamily(bin string) error {
 	for {
-		exists, err := l.ruleExists("OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName)
+		exists, err := l.ruleExists(bin, "OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName)
 		if err != nil {
 			return err
 		}
 		if !exists {
 			break
 		}
-		if output, err := runPrivilegedLinuxFn("iptables", "-t", "nat", "-D", "OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName); err != nil {
-			return fmt.Errorf("removing OUTPUT jump rule: %s: %w", strings.TrimSpace(string(output)), err)
+		if output, err := runPrivilegedLinuxFn(bin, "-t", "nat", "-D", "OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName); err != nil {
+			return fmt.Errorf("removing %s OUTPUT jump rule: %s: %w", bin, strings.TrimSpace(string(output)), err)
 		}
 	}
 
-	if output, err := runPrivilegedLinuxFn("iptables", "-t", "nat", "-F", linuxChainName); err != nil && !iptablesChainMissing(output) {
-		return fmt.Errorf("flushing chain %s: %s: %w", linuxChainName, strings.TrimSpace(string(output)), err)
+	if output, err := runPrivilegedLinuxFn(bin, "-t", "nat", "-F", linuxChainName); err != nil && !iptablesChainMissing(output) {
+		return fmt.Errorf("flushing %s chain %s: %s: %w", bin, linuxChainName, strings.TrimSpace(string(output)), err)
 	}
-	if output, err := runPrivilegedLinuxFn("iptables", "-t", "nat", "-X", linuxChainName); err != nil && !iptablesChainMissing(output) {
-		return fmt.Errorf("deleting chain %s: %s: %w", linuxChainName, strings.TrimSpace(string(output)), err)
+	if output, err := runPrivilegedLinuxFn(bin, "-t", "nat", "-X", linuxChainName); err != nil && !iptablesChainMissing(output) {
+		return fmt.Errorf("deleting %s chain %s: %s: %w", bin, linuxChainName, strings.TrimSpace(string(output)), err)
 	}
 	return nil
 }
@@ -88,43 +175,58 @@ func (l *linuxPortFwd) IsLoaded() bool {
 	return l.IsEnabled()
 }
 
+// IsEnabled reports whether the OUTPUT jump is installed for IPv4, and for
+// IPv6 when its chain was installed.
 func (l *linuxPortFwd) IsEnabled() bool {
 	if !commandExistsLinuxFn("iptables") {
 		return false
 	}
-	cmd := execCommandLinuxFn("iptables", "-t", "nat", "-C", "OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName)
-	return cmd.Run() == nil
+	for _, f := range l.families() {
+		if f.bestEffort && execCommandLinuxFn(f.bin, "-t", "nat", "-S", linuxChainName).Run() != nil {
+			continue
+		}
+		cmd := execCommandLinuxFn(f.bin, "-t", "nat", "-C", "OUTPUT", "-o", "lo", "-p", "tcp", "-j", linuxChainName)
+		if cmd.Run() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *linuxPortFwd) chainInstalled(bin string) bool {
+	_, err := runPrivilegedLinuxFn(bin, "-t", "nat", "-S", linuxChainName)
+	return err == nil
 }
 
-func (l *linuxPortFwd) ensureChain() error {
-	if output, err := runPrivilegedLinuxFn("iptables", "-t", "nat", "-N", linuxChainName); err != nil && !iptablesChainAlreadyExists(output) {
-		return fmt.Errorf("creating chain %s: %s: %w", linuxChainName, strings.TrimSpace(string(output)), err)
+func (l *linuxPortFwd) ensureChain(bin string) error {
+	if output, err := runPrivilegedLinuxFn(bin, "-t", "nat", "-N", linuxChainName); err != nil && !iptablesChainAlreadyExists(output) {
+		return fmt.Errorf("creating %s chain %s: %s: %w", bin, linuxChainName, strings.TrimSpace(string(output)), err)
 	}
-	if output, err := runPrivilegedLinuxFn("iptables", "-t", "nat", "-F", linuxChainName); err != nil {
-		return fmt.Errorf("flushing chain %s: %s: %w", linuxChainName, strings.TrimSpace(string(output)), err)
+	if output, err := runPrivilegedLinuxFn(bin, "-t", "nat", "-F", linuxChainName); err != nil {
+		return fmt.Errorf("flushing %s chain %s: %s: %w", bin, linuxChainName, strings.TrimSpace(string(output)), err)
 	}
 	return nil
 }
 
-func (l *linuxPortFwd) ensureRedirectRule(fromPort int, toPort int) error {
+func (l *linuxPortFwd) ensureRedirectRule(f iptablesFamily, fromPort int, toPort int) error {
 	args := []string{
 		"-t", "nat",
 		"-A", linuxChainName,
 		"-p", "tcp",
-		"-d", "127.0.0.1/32",
+		"-d", f.loopback,
 		"--dport", fmt.Sprintf("%d", fromPort),
 		"-j", "REDIRECT",
 		"--to-ports", fmt.Sprintf("%d", toPort),
 	}
-	if output, err := runPrivilegedLinuxFn("iptables", args...); err != nil {
-		return fmt.Errorf("adding redirect rule %d->%d: %s: %w", fromPort, toPort, strings.TrimSpace(string(output)), err)
+	if output, err := runPrivilegedLinuxFn(f.bin, args...); err != nil {
+		return fmt.Errorf("adding %s redirect rule %d->%d: %s: %w", f.bin, fromPort, toPort, strings.TrimSpace(string(output)), err)
 	}
 	return nil
 }
 
-func (l *linuxPortFwd) ruleExists(chain string, ruleArgs ...string) (bool, error) {
+func (l *linuxPortFwd) ruleExists(bin string, chain string, ruleArgs ...string) (bool, error) {
 	args := append([]string{"-t", "nat", "-C", chain}, ruleArgs...)
-	output, err := runPrivilegedLinuxFn("iptables", args...)
+	output, err := runPrivilegedLinuxFn(bin, args...)
 	if err == nil {
 		return true, nil
 	}
@@ -132,7 +234,7 @@ func (l *linuxPortFwd) ruleExists(chain string, ruleArgs ...string) (bool, error
 	if strings.Contains(msg, "bad rule") || strings.Contains(msg, "no chain/target/match by that name") || strings.Contains(msg, "does a matching rule exist") || strings.Contains(msg, "not found") {
 		return false, nil
 	}
-	return false, fmt.Errorf("checking iptables rule: %s: %w", strings.TrimSpace(string(output)), err)
+	return false, fmt.Errorf("checking %s rule: %s: %w", bin, strings.TrimSpace(string(output)), err)
 }
 
 func iptablesChainAlreadyExists(output []byte) bool {
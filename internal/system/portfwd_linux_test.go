@@ -6,6 +6,7 @@ import (
 	"errors"
 	"os/exec"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -18,7 +19,7 @@ func TestLinuxPortForwardEnableTwiceInstallsOutputJumpOnce(t *testing.T) {
 	commandExistsLinuxFn = func(name string) bool { return name == "iptables" }
 	runPrivilegedLinuxFn = mock.run
 
-	pf := &linuxPortFwd{}
+	pf := &linuxPortFwd{ports: portsFor(nil)}
 	if err := pf.Enable(); err != nil {
 		t.Fatalf("first Enable: %v", err)
 	}
@@ -34,6 +35,54 @@ func TestLinuxPortForwardEnableTwiceInstallsOutputJumpOnce(t *testing.T) {
 	}
 }
 
+func TestLinuxPortForwardEnableInstallsRulesPerFamily(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed []string
+		want      map[string]string
+	}{
+		{"ipv4 only", []string{"iptables"}, map[string]string{"iptables": "127.0.0.1/32"}},
+		{"ipv4 and ipv6", []string{"iptables", "ip6tables"}, map[string]string{"iptables": "127.0.0.1/32", "ip6tables": "::1/128"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := snapshotLinuxPortFwdHooks()
+			defer restore()
+
+			mocks := map[string]*iptablesMock{"iptables": {}, "ip6tables": {}}
+			commandExistsLinuxFn = func(name string) bool { return slices.Contains(tt.installed, name) }
+			runPrivilegedLinuxFn = func(name string, args ...string) ([]byte, error) {
+				return mocks[name].run(name, args...)
+			}
+
+			if err := (&linuxPortFwd{ports: portsFor(nil)}).Enable(); err != nil {
+				t.Fatalf("Enable: %v", err)
+			}
+			for bin, mock := range mocks {
+				loopback, ok := tt.want[bin]
+				if !ok {
+					if len(mock.commands) != 0 {
+						t.Fatalf("expected no %s commands, got %v", bin, mock.commands)
+					}
+					continue
+				}
+				if !mock.outputJump {
+					t.Fatalf("expected %s OUTPUT jump to be installed", bin)
+				}
+				redirects := 0
+				for _, cmd := range mock.commands {
+					if slices.Contains(cmd, "REDIRECT") && slices.Contains(cmd, loopback) {
+						redirects++
+					}
+				}
+				if redirects != 2 {
+					t.Fatalf("expected two %s redirects for %s, got %d", bin, loopback, redirects)
+				}
+			}
+		})
+	}
+}
+
 func TestLinuxPortForwardDisableRemovesRules(t *testing.T) {
 	restore := snapshotLinuxPortFwdHooks()
 	defer restore()
@@ -45,7 +94,7 @@ func TestLinuxPortForwardDisableRemovesRules(t *testing.T) {
 	commandExistsLinuxFn = func(name string) bool { return name == "iptables" }
 	runPrivilegedLinuxFn = mock.run
 
-	pf := &linuxPortFwd{}
+	pf := &linuxPortFwd{ports: portsFor(nil)}
 	if err := pf.Disable(); err != nil {
 		t.Fatalf("Disable: %v", err)
 	}
@@ -66,7 +115,7 @@ func TestLinuxPortForwardEnableFailsWhenIptablesMissing(t *testing.T) {
 	defer restore()
 
 	commandExistsLinuxFn = func(string) bool { return false }
-	pf := &linuxPortFwd{}
+	pf := &linuxPortFwd{ports: portsFor(nil)}
 
 	if err := pf.Enable(); err == nil {
 		t.Fatal("expected Enable to fail when iptables is missing")
@@ -82,7 +131,7 @@ func TestLinuxPortForwardIsEnabledCheck(t *testing.T) {
 		return exec.Command("sh", "-c", "exit 0")
 	}
 
-	pf := &linuxPortFwd{}
+	pf := &linuxPortFwd{ports: portsFor(nil)}
 	if !pf.IsEnabled() {
 		t.Fatal("expected IsEnabled true when iptables check command succeeds")
 	}
@@ -95,10 +144,62 @@ func TestLinuxPortForwardIsEnabledCheck(t *testing.T) {
 	}
 }
 
+func TestLinuxPortForwardSkipsIPv6WithoutNat(t *testing.T) {
+	restore := snapshotLinuxPortFwdHooks()
+	defer restore()
+
+	v4, v6 := &iptablesMock{}, &iptablesMock{noNat: true}
+	commandExistsLinuxFn = func(string) bool { return true }
+	runPrivilegedLinuxFn = func(name string, args ...string) ([]byte, error) {
+		if name == "ip6tables" {
+			return v6.run(name, args...)
+		}
+		return v4.run(name, args...)
+	}
+	execCommandLinuxFn = func(name string, args ...string) *exec.Cmd {
+		mock := v4
+		if name == "ip6tables" {
+			mock = v6
+		}
+		if _, err := mock.run(name, args...); err != nil {
+			return exec.Command("sh", "-c", "exit 1")
+		}
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	pf := &linuxPortFwd{ports: portsFor(nil)}
+	if err := pf.Enable(); err != nil {
+		t.Fatalf("expected Enable to skip IPv6, got %v", err)
+	}
+	if !v4.outputJump {
+		t.Fatal("expected the IPv4 OUTPUT jump to be installed")
+	}
+	if !pf.IsEnabled() {
+		t.Fatal("expected IsEnabled to ignore IPv6 rules that were never installed")
+	}
+	if err := pf.Disable(); err != nil {
+		t.Fatalf("expected Disable to skip IPv6, got %v", err)
+	}
+	if v4.outputJump || v4.chainExists {
+		t.Fatal("expected the IPv4 rules to be removed")
+	}
+
+	// Once IPv6 rules exist, a missing IPv6 jump means forwarding is off.
+	v6.noNat = false
+	if err := pf.Enable(); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	v6.outputJump = false
+	if pf.IsEnabled() {
+		t.Fatal("expected IsEnabled false without the installed IPv6 jump")
+	}
+}
+
 type iptablesMock struct {
 	commands    [][]string
 	chainExists bool
 	outputJump  bool
+	noNat       bool
 }
 
 func (m *iptablesMock) run(name string, args ...string) ([]byte, error) {
@@ -108,8 +209,16 @@ func (m *iptablesMock) run(name string, args ...string) ([]byte, error) {
 	if len(args) < 4 || args[0] != "-t" || args[1] != "nat" {
 		return []byte("invalid"), errors.New("invalid command")
 	}
+	if m.noNat {
+		return []byte("can't initialize " + name + " table `nat': Table does not exist"), errors.New("exit 3")
+	}
 
 	switch {
+	case matchPrefix(args, "-S", linuxChainName):
+		if !m.chainExists {
+			return []byte("No chain/target/match by that name"), errors.New("exit 1")
+		}
+		return nil, nil
 	case matchPrefix(args, "-C", "OUTPUT"):
 		if m.outputJump {
 			return nil, nil
@@ -168,8 +277,10 @@ func snapshotLinuxPortFwdHooks() func() {
 	prevExists := commandExistsLinuxFn
 	prevRun := runPrivilegedLinuxFn
 	prevExec := execCommandLinuxFn
+	prevLoad := loadConfigLinuxFn
 
 	return func() {
+		loadConfigLinuxFn = prevLoad
 		commandExistsLinuxFn = prevExists
 		runPrivilegedLinuxFn = prevRun
 		execCommandLinuxFn = prevExec
@@ -0,0 +1,89 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	nftFamily    = "inet"
+	nftTableName = "slim"
+)
+
+// nftPortFwd redirects 80 and 443 on loopback with a dedicated nftables
+// table, for systems that ship nft without the iptables frontend. The table
+// is in the inet family so one set of chains covers IPv4 and IPv6.
+type nftPortFwd struct {
+	ports forwardPorts
+}
+
+func (n *nftPortFwd) Enable() error {
+	if !commandExistsLinuxFn("nft") {
+		return errors.New("nft not found (install nftables)")
+	}
+
+	if output, err := runPrivilegedLinuxFn("nft", "add", "table", nftFamily, nftTableName); err != nil {
+		return fmt.Errorf("creating table %s: %s: %w", nftTableName, strings.TrimSpace(string(output)), err)
+	}
+	if output, err := runPrivilegedLinuxFn("nft", "flush", "table", nftFamily, nftTableName); err != nil {
+		return fmt.Errorf("flushing table %s: %s: %w", nftTableName, strings.TrimSpace(string(output)), err)
+	}
+	chain := []string{"add", "chain", nftFamily, nftTableName, "output", "{", "type", "nat", "hook", "output", "priority", "-100", ";", "policy", "accept", ";", "}"}
+	if output, err := runPrivilegedLinuxFn("nft", chain...); err != nil {
+		return fmt.Errorf("creating output chain: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	for _, match := range [][]string{{"ip", "daddr", "127.0.0.1"}, {"ip6", "daddr", "::1"}} {
+		if err := n.addRedirectRule(match, 80, n.ports.http); err != nil {
+			return err
+		}
+		if err := n.addRedirectRule(match, 443, n.ports.https); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *nftPortFwd) EnsureLoaded() error {
+	return n.Enable()
+}
+
+func (n *nftPortFwd) Disable() error {
+	if !commandExistsLinuxFn("nft") {
+		return nil
+	}
+	if output, err := runPrivilegedLinuxFn("nft", "delete", "table", nftFamily, nftTableName); err != nil && !nftTableMissing(output) {
+		return fmt.Errorf("deleting table %s: %s: %w", nftTableName, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func (n *nftPortFwd) IsLoaded() bool {
+	return n.IsEnabled()
+}
+
+func (n *nftPortFwd) IsEnabled() bool {
+	if !commandExistsLinuxFn("nft") {
+		return false
+	}
+	return execCommandLinuxFn("nft", "list", "table", nftFamily, nftTableName).Run() == nil
+}
+
+func (n *nftPortFwd) addRedirectRule(match []string, fromPort int, toPort int) error {
+	args := append([]string{"add", "rule", nftFamily, nftTableName, "output", "oifname", "lo"}, match...)
+	args = append(args,
+		"tcp", "dport", fmt.Sprintf("%d", fromPort),
+		"redirect", "to", fmt.Sprintf(":%d", toPort),
+	)
+	if output, err := runPrivilegedLinuxFn("nft", args...); err != nil {
+		return fmt.Errorf("adding redirect rule %s %d->%d: %s: %w", match[0], fromPort, toPort, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func nftTableMissing(output []byte) bool {
+	msg := strings.ToLower(strings.TrimSpace(string(output)))
+	return strings.Contains(msg, "no such file or directory") || strings.Contains(msg, "does not exist")
+}
@@ -0,0 +1,215 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+func TestNFTPortForwardEnableCreatesTable(t *testing.T) {
+	restore := snapshotLinuxPortFwdHooks()
+	defer restore()
+
+	mock := &nftMock{}
+	commandExistsLinuxFn = func(name string) bool { return name == "nft" }
+	runPrivilegedLinuxFn = mock.run
+
+	pf := &nftPortFwd{ports: portsFor(nil)}
+	if err := pf.Enable(); err != nil {
+		t.Fatalf("first Enable: %v", err)
+	}
+	if err := pf.Enable(); err != nil {
+		t.Fatalf("second Enable: %v", err)
+	}
+
+	if got := mock.count("flush table inet slim"); got != 2 {
+		t.Fatalf("expected the table to be flushed on each enable, got %d", got)
+	}
+	want := []string{
+		"nft add rule inet slim output oifname lo ip daddr 127.0.0.1 tcp dport 80 redirect to :10080",
+		"nft add rule inet slim output oifname lo ip daddr 127.0.0.1 tcp dport 443 redirect to :10443",
+		"nft add rule inet slim output oifname lo ip6 daddr ::1 tcp dport 80 redirect to :10080",
+		"nft add rule inet slim output oifname lo ip6 daddr ::1 tcp dport 443 redirect to :10443",
+	}
+	for _, rule := range want {
+		if got := mock.count(strings.TrimPrefix(rule, "nft ")); got != 2 {
+			t.Fatalf("expected %q twice (once per enable), got %d", rule, got)
+		}
+	}
+}
+
+func TestNFTPortForwardDisable(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   bool
+		wantErr bool
+	}{
+		{"table present", true, false},
+		{"table missing", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := snapshotLinuxPortFwdHooks()
+			defer restore()
+
+			mock := &nftMock{table: tt.table}
+			commandExistsLinuxFn = func(name string) bool { return name == "nft" }
+			runPrivilegedLinuxFn = mock.run
+
+			err := (&nftPortFwd{ports: portsFor(nil)}).Disable()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Disable error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if mock.table {
+				t.Fatal("expected the table to be deleted")
+			}
+		})
+	}
+}
+
+func TestNFTPortForwardEnableFailsWhenNFTMissing(t *testing.T) {
+	restore := snapshotLinuxPortFwdHooks()
+	defer restore()
+
+	commandExistsLinuxFn = func(string) bool { return false }
+	if err := (&nftPortFwd{ports: portsFor(nil)}).Enable(); err == nil {
+		t.Fatal("expected Enable to fail when nft is missing")
+	}
+}
+
+func TestNFTPortForwardIsEnabledCheck(t *testing.T) {
+	restore := snapshotLinuxPortFwdHooks()
+	defer restore()
+
+	commandExistsLinuxFn = func(name string) bool { return name == "nft" }
+	var gotArgs []string
+	execCommandLinuxFn = func(name string, args ...string) *exec.Cmd {
+		gotArgs = append([]string{name}, args...)
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	pf := &nftPortFwd{ports: portsFor(nil)}
+	if !pf.IsEnabled() {
+		t.Fatal("expected IsEnabled true when the slim table exists")
+	}
+	if want := []string{"nft", "list", "table", "inet", "slim"}; !reflect.DeepEqual(gotArgs, want) {
+		t.Fatalf("expected %v, got %v", want, gotArgs)
+	}
+
+	execCommandLinuxFn = func(name string, args ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", "exit 1")
+	}
+	if pf.IsEnabled() {
+		t.Fatal("expected IsEnabled false when the slim table is missing")
+	}
+}
+
+func TestNewPortForwarderSelectsBackend(t *testing.T) {
+	tests := []struct {
+		name      string
+		override  string
+		installed []string
+		enabled   string
+		want      PortForwarder
+	}{
+		{"override iptables", config.PortForwardIPTables, []string{"nft"}, "", &linuxPortFwd{ports: portsFor(nil)}},
+		{"override nftables", config.PortForwardNFTables, []string{"iptables", "nft"}, "", &nftPortFwd{ports: portsFor(nil)}},
+		{"invalid override falls back to auto", "pf", []string{"nft"}, "", &nftPortFwd{ports: portsFor(nil)}},
+		{"auto prefers iptables", "", []string{"iptables", "nft"}, "", &linuxPortFwd{ports: portsFor(nil)}},
+		{"auto uses nft without iptables", config.PortForwardAuto, []string{"nft"}, "", &nftPortFwd{ports: portsFor(nil)}},
+		{"auto keeps existing nft rules", "", []string{"iptables", "nft"}, "nft", &nftPortFwd{ports: portsFor(nil)}},
+		{"auto keeps existing iptables rules", "", []string{"iptables", "nft"}, "iptables", &linuxPortFwd{ports: portsFor(nil)}},
+		{"auto with neither installed", "", nil, "", &linuxPortFwd{ports: portsFor(nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := snapshotLinuxPortFwdHooks()
+			defer restore()
+
+			loadConfigLinuxFn = func() (*config.Config, error) {
+				return &config.Config{PortForward: tt.override}, nil
+			}
+			commandExistsLinuxFn = func(name string) bool { return slices.Contains(tt.installed, name) }
+			execCommandLinuxFn = func(name string, args ...string) *exec.Cmd {
+				if name == tt.enabled {
+					return exec.Command("sh", "-c", "exit 0")
+				}
+				return exec.Command("sh", "-c", "exit 1")
+			}
+
+			got := NewPortForwarder()
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+				t.Fatalf("expected %T, got %T", tt.want, got)
+			}
+		})
+	}
+}
+
+type nftMock struct {
+	commands []string
+	table    bool
+}
+
+func (m *nftMock) run(name string, args ...string) ([]byte, error) {
+	if name != "nft" {
+		return []byte("invalid"), errors.New("invalid command")
+	}
+	joined := strings.Join(args, " ")
+	m.commands = append(m.commands, joined)
+
+	switch {
+	case joined == "add table inet slim":
+		m.table = true
+	case joined == "delete table inet slim":
+		if !m.table {
+			return []byte("Error: Could not process rule: No such file or directory"), errors.New("exit 1")
+		}
+		m.table = false
+	case strings.HasPrefix(joined, "flush table inet slim"), strings.HasPrefix(joined, "add chain inet slim"), strings.HasPrefix(joined, "add rule inet slim"):
+		if !m.table {
+			return []byte("Error: No such file or directory"), errors.New("exit 1")
+		}
+	}
+	return nil, nil
+}
+
+func (m *nftMock) count(command string) int {
+	count := 0
+	for _, c := range m.commands {
+		if c == command {
+			count++
+		}
+	}
+	return count
+}
+
+func TestNewPortForwarderUsesConfiguredListenerPorts(t *testing.T) {
+	restore := snapshotLinuxPortFwdHooks()
+	defer restore()
+
+	loadConfigLinuxFn = func() (*config.Config, error) {
+		return &config.Config{PortForward: config.PortForwardNFTables, HTTPPort: 8080, HTTPSPort: 8443}, nil
+	}
+	mock := &nftMock{}
+	commandExistsLinuxFn = func(name string) bool { return name == "nft" }
+	runPrivilegedLinuxFn = mock.run
+
+	if err := NewPortForwarder().Enable(); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	for _, rule := range []string{
+		"add rule inet slim output oifname lo ip daddr 127.0.0.1 tcp dport 80 redirect to :8080",
+		"add rule inet slim output oifname lo ip6 daddr ::1 tcp dport 443 redirect to :8443",
+	} {
+		if mock.count(rule) != 1 {
+			t.Fatalf("expected %q, got %v", rule, mock.commands)
+		}
+	}
+}
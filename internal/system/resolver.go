@@ -0,0 +1,26 @@
+package system
+
+import (
+	"fmt"
+
+	"github.com/kamranahmedse/slim/internal/config"
+)
+
+// Resolver routes lookups for slim's zones to the embedded DNS resolver so
+// domains resolve without /etc/hosts entries.
+type Resolver interface {
+	Name() string
+	Install(zones []string) error
+	Uninstall() error
+	InstalledZones() []string
+}
+
+const resolverHost = "127.0.0.1"
+
+func resolverPort() int {
+	return config.ProxyDNSPort
+}
+
+func resolverAddr() string {
+	return fmt.Sprintf("%s:%d", resolverHost, resolverPort())
+}
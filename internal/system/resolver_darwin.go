@@ -0,0 +1,95 @@
+//go:build darwin
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/osutil"
+)
+
+const resolverDir = "/etc/resolver"
+
+var (
+	readDirResolverFn       = os.ReadDir
+	readFileResolverFn      = os.ReadFile
+	writeResolverFileFn     = writeFileElevated
+	runPrivilegedResolverFn = osutil.RunPrivileged
+)
+
+// darwinResolver writes one /etc/resolver file per zone, which macOS uses
+// to send lookups for that zone to the embedded resolver.
+type darwinResolver struct{}
+
+func NewResolver() (Resolver, error) {
+	return &darwinResolver{}, nil
+}
+
+func (d *darwinResolver) Name() string {
+	return "/etc/resolver"
+}
+
+func (d *darwinResolver) Install(zones []string) error {
+	if output, err := runPrivilegedResolverFn("mkdir", "-p", resolverDir); err != nil {
+		return fmt.Errorf("creating %s: %s: %w", resolverDir, strings.TrimSpace(string(output)), err)
+	}
+	content := fmt.Sprintf("%s\nnameserver %s\nport %d\n", marker, resolverHost, resolverPort())
+	for _, z := range zones {
+		if err := writeResolverFileFn(filepath.Join(resolverDir, z), content); err != nil {
+			return fmt.Errorf("writing resolver for .%s: %w", z, err)
+		}
+	}
+	for _, z := range d.InstalledZones() {
+		if !slices.Contains(zones, z) {
+			if err := d.remove(z); err != nil {
+				return err
+			}
+		}
+	}
+	return d.flush()
+}
+
+func (d *darwinResolver) Uninstall() error {
+	for _, z := range d.InstalledZones() {
+		if err := d.remove(z); err != nil {
+			return err
+		}
+	}
+	return d.flush()
+}
+
+// InstalledZones lists resolver files written by slim, leaving ones that
+// belong to other tools alone.
+func (d *darwinResolver) InstalledZones() []string {
+	entries, err := readDirResolverFn(resolverDir)
+	if err != nil {
+		return nil
+	}
+	var zones []string
+	for _, e := range entries {
+		data, err := readFileResolverFn(filepath.Join(resolverDir, e.Name()))
+		if err == nil && strings.HasPrefix(string(data), marker) {
+			zones = append(zones, e.Name())
+		}
+	}
+	return zones
+}
+
+func (d *darwinResolver) remove(zone string) error {
+	path := filepath.Join(resolverDir, zone)
+	if output, err := runPrivilegedResolverFn("rm", "-f", path); err != nil {
+		return fmt.Errorf("removing %s: %s: %w", path, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func (d *darwinResolver) flush() error {
+	if output, err := runPrivilegedResolverFn("killall", "-HUP", "mDNSResponder"); err != nil {
+		return fmt.Errorf("flushing DNS cache: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
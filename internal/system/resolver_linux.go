@@ -0,0 +1,144 @@
+//go:build linux
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/osutil"
+)
+
+const (
+	resolvedRunDir     = "/run/systemd/resolve"
+	resolvedDropInPath = "/etc/systemd/resolved.conf.d/slim.conf"
+	dnsmasqDropInPath  = "/etc/NetworkManager/dnsmasq.d/slim.conf"
+)
+
+var (
+	statResolverFn          = os.Stat
+	readFileResolverFn      = os.ReadFile
+	writeResolverFileFn     = writeFileElevated
+	runPrivilegedResolverFn = osutil.RunPrivileged
+)
+
+// linuxResolver writes a drop-in for systemd-resolved, or for the dnsmasq
+// instance NetworkManager runs when resolved is not in use.
+type linuxResolver struct {
+	name    string
+	path    string
+	render  func(zones []string) string
+	parse   func(content string) []string
+	restart []string
+}
+
+func NewResolver() (Resolver, error) {
+	if _, err := statResolverFn(resolvedRunDir); err == nil {
+		return &linuxResolver{
+			name:    "systemd-resolved",
+			path:    resolvedDropInPath,
+			render:  renderResolvedDropIn,
+			parse:   parseResolvedDropIn,
+			restart: []string{"systemctl", "restart", "systemd-resolved"},
+		}, nil
+	}
+	if _, err := statResolverFn(filepath.Dir(dnsmasqDropInPath)); err == nil {
+		return &linuxResolver{
+			name:    "NetworkManager dnsmasq",
+			path:    dnsmasqDropInPath,
+			render:  renderDnsmasqDropIn,
+			parse:   parseDnsmasqDropIn,
+			restart: []string{"systemctl", "reload", "NetworkManager"},
+		}, nil
+	}
+	return nil, fmt.Errorf("no supported system resolver found (need systemd-resolved or NetworkManager with dnsmasq)")
+}
+
+func (r *linuxResolver) Name() string {
+	return r.name
+}
+
+func (r *linuxResolver) Install(zones []string) error {
+	if output, err := runPrivilegedResolverFn("mkdir", "-p", filepath.Dir(r.path)); err != nil {
+		return fmt.Errorf("creating %s: %s: %w", filepath.Dir(r.path), strings.TrimSpace(string(output)), err)
+	}
+	if err := writeResolverFileFn(r.path, r.render(zones)); err != nil {
+		return fmt.Errorf("writing %s: %w", r.path, err)
+	}
+	return r.reload()
+}
+
+func (r *linuxResolver) Uninstall() error {
+	if _, err := statResolverFn(r.path); err != nil {
+		return nil
+	}
+	if output, err := runPrivilegedResolverFn("rm", "-f", r.path); err != nil {
+		return fmt.Errorf("removing %s: %s: %w", r.path, strings.TrimSpace(string(output)), err)
+	}
+	return r.reload()
+}
+
+func (r *linuxResolver) InstalledZones() []string {
+	data, err := readFileResolverFn(r.path)
+	if err != nil {
+		return nil
+	}
+	return r.parse(string(data))
+}
+
+func (r *linuxResolver) reload() error {
+	if output, err := runPrivilegedResolverFn(r.restart[0], r.restart[1:]...); err != nil {
+		return fmt.Errorf("reloading %s: %s: %w", r.name, strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func renderResolvedDropIn(zones []string) string {
+	routes := make([]string, len(zones))
+	for i, z := range zones {
+		routes[i] = "~" + z
+	}
+	return fmt.Sprintf("%s\n[Resolve]\nDNS=%s\nDomains=%s\n", marker, resolverAddr(), strings.Join(routes, " "))
+}
+
+func parseResolvedDropIn(content string) []string {
+	var zones []string
+	for _, line := range strings.Split(content, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "Domains=")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(value) {
+			zones = append(zones, strings.TrimPrefix(field, "~"))
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+func renderDnsmasqDropIn(zones []string) string {
+	var b strings.Builder
+	b.WriteString(marker + "\n")
+	for _, z := range zones {
+		fmt.Fprintf(&b, "server=/%s/%s#%d\n", z, resolverHost, resolverPort())
+	}
+	return b.String()
+}
+
+func parseDnsmasqDropIn(content string) []string {
+	var zones []string
+	for _, line := range strings.Split(content, "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "server=/")
+		if !ok {
+			continue
+		}
+		if zone, _, ok := strings.Cut(value, "/"); ok {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
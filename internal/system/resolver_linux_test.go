@@ -0,0 +1,148 @@
+//go:build linux
+
+package system
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func snapshotLinuxResolverHooks() func() {
+	prevStat := statResolverFn
+	prevRead := readFileResolverFn
+	prevWrite := writeResolverFileFn
+	prevRun := runPrivilegedResolverFn
+	return func() {
+		statResolverFn = prevStat
+		readFileResolverFn = prevRead
+		writeResolverFileFn = prevWrite
+		runPrivilegedResolverFn = prevRun
+	}
+}
+
+func TestNewResolverPicksBackend(t *testing.T) {
+	restore := snapshotLinuxResolverHooks()
+	defer restore()
+
+	tests := []struct {
+		name     string
+		existing []string
+		want     string
+		wantErr  bool
+	}{
+		{"systemd-resolved", []string{resolvedRunDir, "/etc/NetworkManager/dnsmasq.d"}, "systemd-resolved", false},
+		{"dnsmasq", []string{"/etc/NetworkManager/dnsmasq.d"}, "NetworkManager dnsmasq", false},
+		{"none", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statResolverFn = func(path string) (os.FileInfo, error) {
+				for _, e := range tt.existing {
+					if e == path {
+						return nil, nil
+					}
+				}
+				return nil, os.ErrNotExist
+			}
+			r, err := NewResolver()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewResolver: %v", err)
+			}
+			if r.Name() != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, r.Name())
+			}
+		})
+	}
+}
+
+func TestResolverDropIns(t *testing.T) {
+	tests := []struct {
+		name   string
+		render func([]string) string
+		parse  func(string) []string
+		want   string
+	}{
+		{"resolved", renderResolvedDropIn, parseResolvedDropIn, "# slim\n[Resolve]\nDNS=127.0.0.1:10053\nDomains=~loc ~test\n"},
+		{"dnsmasq", renderDnsmasqDropIn, parseDnsmasqDropIn, "# slim\nserver=/loc/127.0.0.1#10053\nserver=/test/127.0.0.1#10053\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := tt.render([]string{"loc", "test"})
+			if content != tt.want {
+				t.Fatalf("unexpected drop-in:\n%s", content)
+			}
+			if got := tt.parse(content); !reflect.DeepEqual(got, []string{"loc", "test"}) {
+				t.Fatalf("parsed zones %v", got)
+			}
+		})
+	}
+}
+
+func TestLinuxResolverInstallAndUninstall(t *testing.T) {
+	restore := snapshotLinuxResolverHooks()
+	defer restore()
+
+	files := map[string]string{}
+	var commands []string
+	statResolverFn = func(path string) (os.FileInfo, error) {
+		if path == resolvedRunDir {
+			return nil, nil
+		}
+		if _, ok := files[path]; ok {
+			return nil, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	readFileResolverFn = func(path string) ([]byte, error) {
+		if c, ok := files[path]; ok {
+			return []byte(c), nil
+		}
+		return nil, os.ErrNotExist
+	}
+	writeResolverFileFn = func(path, content string) error {
+		files[path] = content
+		return nil
+	}
+	runPrivilegedResolverFn = func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		if name == "rm" {
+			delete(files, args[len(args)-1])
+		}
+		return nil, nil
+	}
+
+	r, err := NewResolver()
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+	if err := r.Install([]string{"test"}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if got := r.InstalledZones(); !reflect.DeepEqual(got, []string{"test"}) {
+		t.Fatalf("expected installed zones [test], got %v", got)
+	}
+	if err := r.Uninstall(); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	if len(r.InstalledZones()) != 0 {
+		t.Fatal("expected drop-in to be removed")
+	}
+
+	want := []string{
+		"mkdir -p /etc/systemd/resolved.conf.d",
+		"systemctl restart systemd-resolved",
+		"rm -f " + resolvedDropInPath,
+		"systemctl restart systemd-resolved",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("unexpected commands %v", commands)
+	}
+}
@@ -0,0 +1,14 @@
+package system
+
+// Service runs the daemon under the init system, so it is restarted when
+// it fails and comes back after a reboot.
+type Service interface {
+	Name() string
+	Install(exe string) error
+	Uninstall() error
+	Start() error
+	Stop() error
+	Installed() bool
+	Enabled() bool
+	Active() bool
+}
@@ -0,0 +1,9 @@
+//go:build darwin
+
+package system
+
+import "errors"
+
+func NewService() (Service, error) {
+	return nil, errors.New("slim service needs systemd and is only available on Linux")
+}
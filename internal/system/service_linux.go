@@ -0,0 +1,123 @@
+//go:build linux
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kamranahmedse/slim/internal/osutil"
+)
+
+const serviceUnitName = "slim.service"
+
+var (
+	commandExistsServiceFn = osutil.CommandExists
+	userConfigDirServiceFn = os.UserConfigDir
+	systemctlServiceFn     = func(args ...string) ([]byte, error) {
+		return exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	}
+)
+
+// systemdService manages a systemd --user unit that runs the daemon in the
+// foreground. Its output goes to the journal.
+type systemdService struct {
+	unitPath string
+}
+
+func NewService() (Service, error) {
+	if !commandExistsServiceFn("systemctl") {
+		return nil, errors.New("systemctl not found (slim service needs systemd)")
+	}
+	dir, err := userConfigDirServiceFn()
+	if err != nil {
+		return nil, fmt.Errorf("finding user config dir: %w", err)
+	}
+	return &systemdService{unitPath: filepath.Join(dir, "systemd", "user", serviceUnitName)}, nil
+}
+
+func (s *systemdService) Name() string {
+	return serviceUnitName
+}
+
+func (s *systemdService) Install(exe string) error {
+	if err := os.MkdirAll(filepath.Dir(s.unitPath), 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(s.unitPath), err)
+	}
+	if err := os.WriteFile(s.unitPath, []byte(renderServiceUnit(exe)), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", s.unitPath, err)
+	}
+	if err := s.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return s.systemctl("enable", serviceUnitName)
+}
+
+func (s *systemdService) Uninstall() error {
+	if !s.Installed() {
+		return nil
+	}
+	if err := s.systemctl("disable", "--now", serviceUnitName); err != nil {
+		return err
+	}
+	if err := os.Remove(s.unitPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", s.unitPath, err)
+	}
+	return s.systemctl("daemon-reload")
+}
+
+func (s *systemdService) Start() error {
+	return s.systemctl("start", serviceUnitName)
+}
+
+func (s *systemdService) Stop() error {
+	return s.systemctl("stop", serviceUnitName)
+}
+
+func (s *systemdService) Installed() bool {
+	_, err := os.Stat(s.unitPath)
+	return err == nil
+}
+
+func (s *systemdService) Enabled() bool {
+	return s.Installed() && s.systemctl("is-enabled", "--quiet", serviceUnitName) == nil
+}
+
+func (s *systemdService) Active() bool {
+	return s.Installed() && s.systemctl("is-active", "--quiet", serviceUnitName) == nil
+}
+
+func (s *systemdService) systemctl(args ...string) error {
+	if output, err := systemctlServiceFn(args...); err != nil {
+		return fmt.Errorf("systemctl --user %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
+func renderServiceUnit(exe string) string {
+	return fmt.Sprintf(`[Unit]
+Description=slim local HTTPS proxy
+After=network.target
+
+[Service]
+Type=simple
+ExecStart=%s daemon
+Restart=on-failure
+RestartSec=2
+
+[Install]
+WantedBy=default.target
+`, systemdQuote(exe))
+}
+
+// systemdQuote quotes a path for an ExecStart line when it needs it.
+func systemdQuote(path string) string {
+	if !strings.ContainsAny(path, " \t\"'\\") {
+		return path
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(path) + `"`
+}
@@ -0,0 +1,117 @@
+//go:build linux
+
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func setupServiceTest(t *testing.T) (*[]string, string) {
+	t.Helper()
+	prevExists := commandExistsServiceFn
+	prevConfigDir := userConfigDirServiceFn
+	prevSystemctl := systemctlServiceFn
+	t.Cleanup(func() {
+		commandExistsServiceFn = prevExists
+		userConfigDirServiceFn = prevConfigDir
+		systemctlServiceFn = prevSystemctl
+	})
+
+	dir := t.TempDir()
+	var calls []string
+	commandExistsServiceFn = func(name string) bool { return name == "systemctl" }
+	userConfigDirServiceFn = func() (string, error) { return dir, nil }
+	systemctlServiceFn = func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return nil, nil
+	}
+	return &calls, filepath.Join(dir, "systemd", "user", "slim.service")
+}
+
+func TestSystemdServiceInstall(t *testing.T) {
+	calls, unitPath := setupServiceTest(t)
+
+	svc, err := NewService()
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if svc.Installed() {
+		t.Fatal("expected the service not to be installed yet")
+	}
+	if err := svc.Install("/opt/my tools/slim"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	unit, err := os.ReadFile(unitPath)
+	if err != nil {
+		t.Fatalf("reading unit: %v", err)
+	}
+	for _, want := range []string{`ExecStart="/opt/my tools/slim" daemon`, "Restart=on-failure", "WantedBy=default.target"} {
+		if !strings.Contains(string(unit), want) {
+			t.Fatalf("expected unit to contain %q, got:\n%s", want, unit)
+		}
+	}
+	if want := []string{"daemon-reload", "enable slim.service"}; !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("expected systemctl calls %v, got %v", want, *calls)
+	}
+	if !svc.Installed() {
+		t.Fatal("expected the service to be installed")
+	}
+}
+
+func TestSystemdServiceUninstall(t *testing.T) {
+	calls, unitPath := setupServiceTest(t)
+
+	svc, err := NewService()
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	if err := svc.Uninstall(); err != nil {
+		t.Fatalf("Uninstall when not installed: %v", err)
+	}
+	if len(*calls) != 0 {
+		t.Fatalf("expected no systemctl calls when not installed, got %v", *calls)
+	}
+
+	if err := svc.Install("/usr/bin/slim"); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	*calls = nil
+	if err := svc.Uninstall(); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	if _, err := os.Stat(unitPath); !os.IsNotExist(err) {
+		t.Fatalf("expected unit file to be removed, got %v", err)
+	}
+	if want := []string{"disable --now slim.service", "daemon-reload"}; !reflect.DeepEqual(*calls, want) {
+		t.Fatalf("expected systemctl calls %v, got %v", want, *calls)
+	}
+}
+
+func TestNewServiceRequiresSystemctl(t *testing.T) {
+	setupServiceTest(t)
+	commandExistsServiceFn = func(string) bool { return false }
+	if _, err := NewService(); err == nil {
+		t.Fatal("expected an error without systemctl")
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/usr/local/bin/slim", "/usr/local/bin/slim"},
+		{"/opt/my tools/slim", `"/opt/my tools/slim"`},
+		{`/opt/a"b/slim`, `"/opt/a\"b/slim"`},
+	}
+	for _, tt := range tests {
+		if got := systemdQuote(tt.path); got != tt.want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"charm.land/lipgloss/v2"
+	xterm "github.com/charmbracelet/x/term"
 )
 
 var (
@@ -33,6 +34,25 @@ func ConfirmPrompt(msg string) bool {
 	return answer == "y" || answer == "yes"
 }
 
+// PasswordPrompt asks for a secret on the terminal without echoing it.
+func PasswordPrompt(msg string) (string, error) {
+	// Prompt on stderr, so output written to stdout stays clean.
+	fmt.Fprintf(os.Stderr, "%s: ", msg)
+	p, err := xterm.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	return string(p), nil
+}
+
+// IsInteractive reports whether stdin is a terminal someone can answer
+// prompts on.
+func IsInteractive() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
 func StyleForStatus(code int) lipgloss.Style {
 	switch {
 	case code >= 500:
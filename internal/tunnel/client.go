@@ -24,6 +24,22 @@ type RequestEvent struct {
 	Duration time.Duration
 }
 
+// Tunnel states reported to OnState.
+const (
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+	StateExpired      = "expired"
+	StateDropped      = "dropped"
+	StateFailed       = "failed"
+	StateClosed       = "closed"
+)
+
+type StateChange struct {
+	State string
+	URL   string
+	Err   error
+}
+
 type ClientOptions struct {
 	ServerURL string
 	Token     string
@@ -33,10 +49,12 @@ type ClientOptions struct {
 	Password  string
 	TTL       time.Duration
 	OnRequest func(RequestEvent)
+	OnState   func(StateChange)
 }
 
 type Client struct {
 	opts      ClientOptions
+	url       string
 	domainURL string
 	conn      *websocket.Conn
 }
@@ -52,6 +70,7 @@ func (c *Client) Connect(ctx context.Context) (string, error) {
 	}
 
 	c.conn = conn
+	c.setState(StateConnected, nil)
 	go c.readLoop(ctx, conn)
 
 	return url, nil
@@ -60,6 +79,13 @@ func (c *Client) Connect(ctx context.Context) (string, error) {
 func (c *Client) Close() {
 	if c.conn != nil {
 		c.conn.Close(websocket.StatusNormalClosure, "client disconnected")
+		c.setState(StateClosed, nil)
+	}
+}
+
+func (c *Client) setState(state string, err error) {
+	if c.opts.OnState != nil {
+		c.opts.OnState(StateChange{State: state, URL: c.url, Err: err})
 	}
 }
 
@@ -101,6 +127,7 @@ func (c *Client) dial(ctx context.Context) (*websocket.Conn, string, error) {
 		c.opts.Subdomain = resp.Subdomain
 	}
 
+	c.url = resp.URL
 	if resp.Domain != "" {
 		c.domainURL = "https://" + resp.Domain
 	} else {
@@ -126,14 +153,17 @@ func (c *Client) readLoop(ctx context.Context, conn *websocket.Conn) {
 		switch websocket.CloseStatus(err) {
 		case 4000:
 			log.Info("tunnel expired (TTL reached)")
+			c.setState(StateExpired, nil)
 			return
 		case 4001:
 			log.Info("tunnel was dropped")
+			c.setState(StateDropped, nil)
 			return
 		}
 
 		_ = conn.CloseNow()
 		log.Error("tunnel connection lost: %v", err)
+		c.setState(StateDisconnected, err)
 
 		for {
 			if ctx.Err() != nil {
@@ -156,6 +186,7 @@ func (c *Client) readLoop(ctx context.Context, conn *websocket.Conn) {
 			if dialErr != nil {
 				if strings.Contains(dialErr.Error(), "registration failed:") {
 					log.Error("%v", dialErr)
+					c.setState(StateFailed, dialErr)
 					return
 				}
 				log.Error("reconnect failed: %v", dialErr)
@@ -163,6 +194,7 @@ func (c *Client) readLoop(ctx context.Context, conn *websocket.Conn) {
 			}
 
 			log.Info("reconnected to tunnel server")
+			c.setState(StateConnected, nil)
 			conn = newConn
 			backoff = time.Second
 			break